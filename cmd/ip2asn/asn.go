@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hink/ip2asn/internal/output"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+func runASN(args []string) {
+	var (
+		jsonFlag bool
+		cfg      configFlags
+		netFlags networkFlags
+		timeouts timeoutFlags
+	)
+
+	fs := flag.NewFlagSet("asn", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ip2asn asn [--json|-j] [--timeout d] [--resolver addr] [--proxy url] [--config path] [--profile name] ASN...\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  ip2asn asn 13335 AS15169\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&jsonFlag, "json", false, "output JSON")
+	fs.BoolVar(&jsonFlag, "j", false, "output JSON")
+	timeouts.register(fs, false)
+	netFlags.register(fs)
+	cfg.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	asns := make([]ip2asn.ASN, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		asn, err := ip2asn.ParseASN(arg)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		asns = append(asns, asn)
+	}
+
+	settings := cfg.settings()
+	if !anySet(flagsSet(fs), "json", "j") {
+		jsonFlag = settings.Format == "json"
+	}
+
+	client := ip2asn.New(append(netFlags.options(fs, settings), timeouts.options(fs, settings)...)...)
+	interrupted := interruptContext()
+	ctx, cancel := timeouts.context(interrupted, fs, settings)
+	defer cancel()
+	infos := make([]ip2asn.ASInfo, 0, len(asns))
+	for _, asn := range asns {
+		if ctx.Err() != nil {
+			break
+		}
+		info, err := client.LookupASN(ctx, asn)
+		if err != nil {
+			if ctx.Err() != nil && len(infos) > 0 {
+				break
+			}
+			if interrupted.Err() != nil {
+				os.Exit(exitInterrupted)
+			}
+			fatalf("AS%s lookup failed: %v", asn, err)
+		}
+		infos = append(infos, info)
+	}
+	partial := len(infos) < len(asns)
+	if partial {
+		fmt.Fprintf(os.Stderr, "Partial results: %d of %d ASNs looked up\n", len(infos), len(asns))
+	}
+
+	if jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(infos); err != nil {
+			fatalf("failed to write JSON: %v", err)
+		}
+	} else {
+		output.PrintASInfoTable(os.Stdout, infos)
+	}
+	if partial {
+		exitPartial(interrupted)
+	}
+}
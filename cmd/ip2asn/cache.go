@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hink/ip2asn/internal/cache"
+)
+
+func runCache(args []string) {
+	var (
+		ttl time.Duration
+		cfg configFlags
+	)
+
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ip2asn cache [--ttl duration] [--config path] [--profile name] path|stats|prune|clear\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "The cache is used by 'ip2asn lookup --cache'.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.DurationVar(&ttl, "ttl", cache.DefaultTTL, "age after which entries count as expired (default from the config profile)")
+	cfg.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	settings := cfg.settings()
+	if !anySet(flagsSet(fs), "ttl") && settings.CacheTTL > 0 {
+		ttl = settings.CacheTTL
+	}
+
+	store := openCache(settings.CachePath, ttl)
+	now := time.Now()
+	switch fs.Arg(0) {
+	case "path":
+		fmt.Println(store.Path())
+	case "stats":
+		stats := store.Stats(now)
+		fmt.Printf("Path:    %s\n", stats.Path)
+		fmt.Printf("Entries: %d (%d expired)\n", stats.Entries, stats.Expired)
+		fmt.Printf("Size:    %d bytes\n", stats.Bytes)
+	case "prune":
+		removed := store.Prune(now)
+		if err := store.Save(); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("Removed %d expired entries\n", removed)
+	case "clear":
+		if err := store.Clear(); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Println("Cache cleared")
+	default:
+		fatalf("unknown cache action %q (want path, stats, prune or clear)", fs.Arg(0))
+	}
+}
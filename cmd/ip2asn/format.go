@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hink/ip2asn/internal/output"
+	"github.com/hink/ip2asn/internal/parquetfile"
+	"github.com/hink/ip2asn/internal/sqlite"
+	"github.com/hink/ip2asn/internal/tui"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+// outputFlags are the result formatting flags shared by lookup and prefix.
+type outputFlags struct {
+	outPath    string
+	formatName string
+	jsonFlag   bool
+	csvFlag    bool
+	ndjsonFlag bool
+	tuiFlag    bool
+	fieldsSpec string
+	tmplText   string
+	tmplFile   string
+	// title heads the HTML report; only the report command sets it
+	title string
+	// mispURL, when set, uploads the MISP event to that instance
+	mispURL    string
+	mispInfo   string
+	mispIPType string
+	// schemaName and bulkIndex shape NDJSON for Elasticsearch and Splunk
+	schemaName string
+	bulkIndex  string
+	// httpClient makes uploads, through --proxy when it is set
+	httpClient *http.Client
+	// source names the input in run metadata
+	source string
+
+	// fields, tmpl and schema are parsed from fieldsSpec, the template
+	// flags and schemaName by format
+	fields []output.Field
+	tmpl   *template.Template
+	schema string
+}
+
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	// Flags + short aliases
+	fs.BoolVar(&o.jsonFlag, "json", false, "output JSON (mutually exclusive with --csv)")
+	fs.BoolVar(&o.jsonFlag, "j", false, "output JSON (mutually exclusive with -c)")
+	fs.BoolVar(&o.csvFlag, "csv", false, "output CSV (mutually exclusive with --json)")
+	fs.BoolVar(&o.csvFlag, "c", false, "output CSV (mutually exclusive with -j)")
+	fs.BoolVar(&o.ndjsonFlag, "ndjson", false, "stream one flat JSON object per result as it arrives; an IP recurring after 100,000 newer ones is written again (same as --format ndjson)")
+	fs.StringVar(&o.outPath, "output", "", "optional output file for every format but table; defaults to stdout")
+	fs.StringVar(&o.outPath, "o", "", "optional output file for every format but table; defaults to stdout")
+	fs.StringVar(&o.formatName, "format", "", "output format: table, csv, json, ndjson, template, markdown, html, asciidoc, stix, misp, sqlite, parquet or bulk (overrides the config profile)")
+	fs.StringVar(&o.fieldsSpec, "fields", "", "comma-separated columns to show, in order, for table/TUI/CSV/NDJSON/markup, e.g. asn,ip,prefix,cc,risk (see --fields help)")
+	fs.StringVar(&o.tmplText, "template", "", "Go text/template executed per result, e.g. '{{.IP}} AS{{.ASN}}{{\"\\n\"}}' (implies --format template)")
+	fs.StringVar(&o.tmplFile, "template-file", "", "read the --template from this file")
+	fs.StringVar(&o.mispURL, "misp-url", "", "upload the --format misp event to this MISP instance, with the key in $"+envMISPKey)
+	fs.StringVar(&o.mispInfo, "misp-info", "", "MISP event title (default \"ip2asn lookup of N IPs\")")
+	fs.StringVar(&o.mispIPType, "misp-ip-type", output.MISPIPSource, "MISP attribute type of the IPs: ip-src or ip-dst")
+	fs.StringVar(&o.schemaName, "schema", "", "field names of NDJSON output: flat, ecs (Elastic Common Schema) or cim (Splunk CIM); implies --format ndjson")
+	fs.StringVar(&o.bulkIndex, "bulk-index", "ip2asn", "Elasticsearch index or data stream of --format bulk")
+	fs.BoolVar(&o.tuiFlag, "tui", false, "open interactive table TUI mode")
+	fs.BoolVar(&o.tuiFlag, "t", false, "open interactive table TUI mode")
+}
+
+// format validates the flag combination and returns "table", "csv", "json",
+// "ndjson", "template", "stix", "misp", "sqlite", "parquet", "bulk" or a
+// markup format (see markupFormats). --json/--csv/--ndjson win over
+// --format, which wins over --template/--template-file, an ECS or CIM
+// --schema and then defaultFormat from the config profile or environment.
+func (o *outputFlags) format(defaultFormat string) (string, error) {
+	// Mutually exclusive format flags
+	if o.jsonFlag && o.csvFlag {
+		return "", fmt.Errorf("--json (-j) and --csv (-c) are mutually exclusive")
+	}
+	if o.ndjsonFlag && (o.jsonFlag || o.csvFlag) {
+		return "", fmt.Errorf("--ndjson is mutually exclusive with --json (-j) and --csv (-c)")
+	}
+	if o.tmplText != "" && o.tmplFile != "" {
+		return "", fmt.Errorf("--template and --template-file are mutually exclusive")
+	}
+	templated := o.tmplText != "" || o.tmplFile != ""
+
+	format := defaultFormat
+	if templated {
+		format = "template"
+	} else if o.schemaName != "" && o.schemaName != output.SchemaFlat {
+		format = "ndjson"
+	}
+	if o.formatName != "" {
+		format = o.formatName
+	}
+	if o.jsonFlag {
+		format = "json"
+	} else if o.csvFlag {
+		format = "csv"
+	} else if o.ndjsonFlag {
+		format = "ndjson"
+	}
+	if (o.jsonFlag || o.csvFlag || o.ndjsonFlag) && o.formatName != "" && o.formatName != format {
+		return "", fmt.Errorf("--format %s conflicts with --%s", o.formatName, format)
+	}
+
+	switch format {
+	case "table", "csv", "json", "ndjson", "template", "markdown", "html", "asciidoc", "stix", "misp", "sqlite", "parquet", "bulk":
+	default:
+		return "", fmt.Errorf("unknown format %q (want table, csv, json, ndjson, template, markdown, html, asciidoc, stix, misp, sqlite, parquet or bulk)", format)
+	}
+	if format == "sqlite" && o.outPath == "" {
+		return "", fmt.Errorf("--format sqlite needs the database path in --output (-o)")
+	}
+	if format == "parquet" && o.outPath == "" {
+		return "", fmt.Errorf("--format parquet needs a file or directory in --output (-o)")
+	}
+	if templated != (format == "template") {
+		if templated {
+			return "", fmt.Errorf("--template and --template-file only apply to --format template, not %s", format)
+		}
+		return "", fmt.Errorf("--format template needs --template or --template-file")
+	}
+	if templated {
+		if err := o.parseTemplate(); err != nil {
+			return "", err
+		}
+	}
+
+	if err := validateTUIOptions(o.tuiFlag, format, o.outPath, isTerminal(os.Stdin), isTerminal(os.Stdout)); err != nil {
+		return "", err
+	}
+
+	if err := o.parseFields(); err != nil {
+		return "", err
+	}
+	if len(o.fields) > 0 {
+		switch format {
+		case "json":
+			return "", fmt.Errorf("--fields does not apply to grouped JSON; use --ndjson for flat JSON")
+		case "template":
+			return "", fmt.Errorf("--fields does not apply to template output; pick fields in the template")
+		case "stix", "misp", "sqlite", "parquet":
+			return "", fmt.Errorf("--fields does not apply to %s output", format)
+		}
+	}
+	if err := o.checkMISP(format); err != nil {
+		return "", err
+	}
+	if err := o.parseSchema(format); err != nil {
+		return "", err
+	}
+	return format, nil
+}
+
+// parseSchema validates --schema for format. --format bulk writes ECS
+// documents, so it takes no other schema.
+func (o *outputFlags) parseSchema(format string) error {
+	switch o.schemaName {
+	case "", output.SchemaFlat, output.SchemaECS, output.SchemaCIM:
+	default:
+		return fmt.Errorf("unknown schema %q (want flat, ecs or cim)", o.schemaName)
+	}
+	o.schema = o.schemaName
+	if o.schema == "" {
+		o.schema = output.SchemaFlat
+	}
+	switch format {
+	case "ndjson":
+	case "bulk":
+		if o.schemaName != "" && o.schema != output.SchemaECS {
+			return fmt.Errorf("--format bulk writes ECS documents, not --schema %s", o.schemaName)
+		}
+		if o.bulkIndex == "" {
+			return fmt.Errorf("--format bulk needs an index in --bulk-index")
+		}
+		o.schema = output.SchemaECS
+	default:
+		if o.schema != output.SchemaFlat {
+			return fmt.Errorf("--schema %s only applies to --format ndjson and bulk", o.schemaName)
+		}
+		return nil
+	}
+	if o.schema != output.SchemaFlat && len(o.fields) > 0 {
+		return fmt.Errorf("--fields does not apply to %s output", strings.ToUpper(o.schema))
+	}
+	return nil
+}
+
+// ndjsonWriter returns the writer of --format ndjson or bulk.
+func (o outputFlags) ndjsonWriter(w io.Writer, enrich bool, format string) *output.NDJSONWriter {
+	nw := output.NewNDJSONWriter(w, enrich)
+	nw.SetFields(o.fields)
+	nw.SetSchema(o.schema)
+	if format == "bulk" {
+		nw.SetBulkIndex(o.bulkIndex)
+	}
+	return nw
+}
+
+// parseFields parses --fields, printing the field list and exiting for
+// "--fields help".
+func (o *outputFlags) parseFields() error {
+	if o.fieldsSpec == "help" {
+		printFieldsHelp(os.Stdout)
+		os.Exit(0)
+	}
+	if o.fieldsSpec == "" {
+		return nil
+	}
+	fields, err := output.ParseFields(o.fieldsSpec)
+	if err != nil {
+		return fmt.Errorf("--fields: %w", err)
+	}
+	o.fields = fields
+	return nil
+}
+
+// parseTemplate parses --template, or the file named by --template-file.
+func (o *outputFlags) parseTemplate() error {
+	name, text := "template", o.tmplText
+	if o.tmplFile != "" {
+		data, err := os.ReadFile(o.tmplFile)
+		if err != nil {
+			return fmt.Errorf("--template-file: %w", err)
+		}
+		name, text = filepath.Base(o.tmplFile), string(data)
+	}
+	tmpl, err := output.ParseTemplate(name, text)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	o.tmpl = tmpl
+	return nil
+}
+
+// checkFieldEnrichment rejects proxycheck.io fields when enrichment is off,
+// since their columns would always be empty.
+func (o outputFlags) checkFieldEnrichment(enrich bool) error {
+	if enrich {
+		return nil
+	}
+	var names []string
+	for _, field := range o.fields {
+		if field.Enrichment {
+			names = append(names, field.Name)
+		}
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("--fields %s need proxycheck.io data; add --enrich (-e)", strings.Join(names, ","))
+	}
+	return nil
+}
+
+// printFieldsHelp lists the fields --fields accepts.
+func printFieldsHelp(w io.Writer) {
+	fmt.Fprintf(w, "Fields for --fields (comma-separated, in output order):\n\n")
+	for _, field := range output.Fields() {
+		note := ""
+		if field.Enrichment {
+			note = "needs --enrich"
+		}
+		fmt.Fprintf(w, "  %-13s %-13s%s\n", field.Name, field.Header, note)
+	}
+}
+
+// writeResults renders results in format. enrichmentError is the proxycheck
+// failure, if any, shown as a table footer or reported on stderr. A non-empty
+// partial says why results are incomplete; it is shown as a table footer, a
+// CSV "Partial" column or a JSON "partial" field on every group, and only on
+// stderr for NDJSON.
+func writeResults(results []ip2asn.Result, format string, o outputFlags, enrich bool, enrichmentError, partial string) {
+	if o.outPath != "" && format == "table" {
+		// Table only goes to stdout
+		fmt.Fprintln(os.Stderr, "--output is ignored for table format; printing to stdout")
+	}
+
+	tableOpts := output.TableOptions{
+		Mode:            chooseTableMode(enrich),
+		Fields:          o.fields,
+		EnrichmentError: enrichmentError,
+		Partial:         partial,
+	}
+	switch format {
+	case "table", "markdown", "html", "asciidoc", "report":
+		// These show enrichment failures and partial results themselves
+	default:
+		if enrichmentError != "" {
+			fmt.Fprintf(os.Stderr, "Proxycheck enrichment failed: %s\n", enrichmentError)
+		}
+		if partial != "" {
+			fmt.Fprintf(os.Stderr, "Partial results: %s\n", partial)
+		}
+	}
+
+	switch format {
+	case "table":
+		if o.tuiFlag {
+			if err := tui.Run(os.Stdin, os.Stdout, results, tableOpts); err != nil {
+				fatalf("failed to start TUI: %v", err)
+			}
+			return
+		}
+		output.PrintTable(os.Stdout, results, tableOpts)
+	case "csv":
+		w, closeOutput := openOutput(o.outPath)
+		defer closeOutput()
+		cw := csv.NewWriter(w)
+		if len(o.fields) > 0 {
+			output.WriteCSVFields(cw, results, o.fields, partial != "")
+		} else {
+			output.WriteCSV(cw, results, enrich, partial != "")
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			fatalf("failed to write CSV: %v", err)
+		}
+	case "json":
+		w, closeOutput := openOutput(o.outPath)
+		defer closeOutput()
+		groups := ip2asn.GroupByASN(results, enrich)
+		for i := range groups {
+			groups[i].Partial = partial != ""
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(groups); err != nil {
+			fatalf("failed to write JSON: %v", err)
+		}
+	case "markdown", "html", "asciidoc":
+		w, closeOutput := openOutput(o.outPath)
+		defer closeOutput()
+		if _, err := io.WriteString(w, output.RenderMarkup(results, tableOpts, markupFormats[format])); err != nil {
+			fatalf("failed to write %s: %v", format, err)
+		}
+	case "template":
+		w, closeOutput := openOutput(o.outPath)
+		defer closeOutput()
+		run := o.runInfo(enrich, enrichmentError, partial)
+		if err := output.WriteTemplate(w, o.tmpl, results, run); err != nil {
+			fatalf("failed to execute template: %v", err)
+		}
+	case "stix":
+		w, closeOutput := openOutput(o.outPath)
+		defer closeOutput()
+		if err := output.WriteSTIX(w, results, enrich, o.runInfo(enrich, enrichmentError, partial)); err != nil {
+			fatalf("failed to write STIX: %v", err)
+		}
+	case "misp":
+		run := o.runInfo(enrich, enrichmentError, partial)
+		opts := output.MISPOptions{Info: o.mispInfo, IPType: o.mispIPType}
+		// An upload only goes to stdout as well when asked to with --output
+		if o.mispURL == "" || o.outPath != "" {
+			w, closeOutput := openOutput(o.outPath)
+			defer closeOutput()
+			if err := output.WriteMISP(w, results, enrich, run, opts); err != nil {
+				fatalf("failed to write MISP event: %v", err)
+			}
+		}
+		if o.mispURL != "" {
+			// The event is derived from the run, so it matches the one written
+			uploadMISP(o, output.NewMISPEvent(results, enrich, run, opts))
+		}
+	case "sqlite":
+		runID, err := sqlite.Append(context.Background(), o.outPath, results, o.runInfo(enrich, enrichmentError, partial))
+		if err != nil {
+			fatalf("failed to write SQLite database: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Saved run %d to %s\n", runID, o.outPath)
+	case "parquet":
+		run := o.runInfo(enrich, enrichmentError, partial)
+		runID, err := parquetfile.NewRunID(run.Generated)
+		if err != nil {
+			fatalf("failed to write Parquet: %v", err)
+		}
+		path, err := writeParquet(o.outPath, results, run, runID)
+		if err != nil {
+			fatalf("failed to write Parquet: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Saved run %s to %s\n", runID, path)
+	case "report":
+		w, closeOutput := openOutput(o.outPath)
+		defer closeOutput()
+		opts := output.ReportOptions{Title: o.title, Fields: o.fields, Run: o.runInfo(enrich, enrichmentError, partial)}
+		if err := output.WriteHTMLReport(w, results, opts); err != nil {
+			fatalf("failed to write report: %v", err)
+		}
+	case "ndjson", "bulk":
+		w, closeOutput := openOutput(o.outPath)
+		defer closeOutput()
+		nw := o.ndjsonWriter(w, enrich, format)
+		for _, result := range results {
+			if err := nw.Write(result); err != nil {
+				fatalf("failed to write NDJSON: %v", err)
+			}
+		}
+	default:
+		fatalf("unknown format: %s", format)
+	}
+}
+
+// runInfo describes this run for templates, reports and exports.
+func (o outputFlags) runInfo(enrich bool, enrichmentError, partial string) output.RunInfo {
+	return output.RunInfo{
+		Version:         version,
+		Generated:       time.Now().UTC(),
+		Enriched:        enrich,
+		Source:          o.source,
+		Partial:         partial,
+		EnrichmentError: enrichmentError,
+	}
+}
+
+// markupFormats maps the document table formats to their markup.
+var markupFormats = map[string]output.MarkupFormat{
+	"markdown": output.MarkupMarkdown,
+	"html":     output.MarkupHTML,
+	"asciidoc": output.MarkupAsciiDoc,
+}
+
+// openOutput returns stdout, or the created file at path when set.
+func openOutput(path string) (io.Writer, func()) {
+	if path == "" {
+		return os.Stdout, func() {}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		fatalf("failed to create output file: %v", err)
+	}
+	return f, func() { f.Close() }
+}
+
+func chooseTableMode(enrichEnabled bool) output.TableMode {
+	if enrichEnabled {
+		return output.TableModeProxycheck
+	}
+	return output.TableModeBasic
+}
+
+func validateTUIOptions(enabled bool, format, outPath string, stdinTTY, stdoutTTY bool) error {
+	if !enabled {
+		return nil
+	}
+	if format != "table" {
+		return fmt.Errorf("--tui (-t) is only supported with table output")
+	}
+	if outPath != "" {
+		return fmt.Errorf("--output (-o) cannot be used with --tui (-t)")
+	}
+	if !stdinTTY || !stdoutTTY {
+		return fmt.Errorf("--tui (-t) requires interactive stdin and stdout")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// exitInterrupted is the conventional status for a process stopped by SIGINT.
+const exitInterrupted = 130
+
+// interruptContext returns a context cancelled by the first SIGINT or
+// SIGTERM, so lookups stop and the results so far can be written. The
+// handler is then removed: a second interrupt terminates the process.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		fmt.Fprintln(os.Stderr, "Interrupted; stopping lookups (press Ctrl+C again to quit now)")
+	}()
+	return ctx
+}
+
+// partialReason explains why a lookup that failed with err but returned
+// some results is incomplete. ctx is the interrupt context.
+func partialReason(ctx context.Context, err error) string {
+	switch {
+	case ctx.Err() != nil:
+		return "interrupted before every lookup finished"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out before every lookup finished"
+	default:
+		return err.Error()
+	}
+}
+
+// exitPartial exits after partial results were written: 130 when
+// interrupted, 1 when a lookup failed.
+func exitPartial(ctx context.Context) {
+	if ctx.Err() != nil {
+		os.Exit(exitInterrupted)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hink/ip2asn/internal/cache"
+	"github.com/hink/ip2asn/internal/config"
+	"github.com/hink/ip2asn/internal/parser"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+// lookupFlags are the flags that select and run the lookup, shared by the
+// lookup and report commands.
+type lookupFlags struct {
+	cfg         configFlags
+	netFlags    networkFlags
+	timeouts    timeoutFlags
+	progressOut progressFlags
+	singleIP    string
+	backendName string
+	enrichFlag  bool
+	cacheFlag   bool
+}
+
+func (l *lookupFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&l.singleIP, "ip", "", "single IP lookup (uses DNS interface)")
+	fs.StringVar(&l.singleIP, "i", "", "single IP lookup (uses DNS interface)")
+	fs.BoolVar(&l.enrichFlag, "enrich", false, "use proxycheck.io data (proxycheck-focused table/TUI; additive CSV/JSON)")
+	fs.BoolVar(&l.enrichFlag, "e", false, "use proxycheck.io data (proxycheck-focused table/TUI; additive CSV/JSON)")
+	fs.BoolVar(&l.cacheFlag, "cache", false, "answer from and update the local lookup cache")
+	fs.StringVar(&l.backendName, "backend", "", "lookup backend: auto, dns or whois (default auto)")
+	l.timeouts.register(fs, true)
+	l.progressOut.register(fs)
+	l.netFlags.register(fs)
+	l.cfg.register(fs)
+}
+
+// settings resolves the configuration and applies it to the flags that were
+// not set. Precedence: flags > environment > config profile > defaults.
+func (l *lookupFlags) settings(fs *flag.FlagSet) config.Settings {
+	settings := l.cfg.settings()
+	set := flagsSet(fs)
+	if !anySet(set, "enrich", "e") {
+		l.enrichFlag = settings.Enrich
+	}
+	if !anySet(set, "cache") {
+		l.cacheFlag = settings.CacheEnabled
+	}
+	if l.backendName == "" {
+		l.backendName = settings.Backend
+	}
+	return settings
+}
+
+func runLookup(args []string) {
+	var (
+		out outputFlags
+		l   lookupFlags
+	)
+
+	fs := flag.NewFlagSet("lookup", flag.ExitOnError)
+	fs.Usage = func() { lookupUsage(fs) }
+	out.register(fs)
+	l.register(fs)
+	_ = fs.Parse(args)
+
+	settings := l.settings(fs)
+	format, err := out.format(settings.Format)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := out.checkFieldEnrichment(l.enrichFlag); err != nil {
+		fatalf("%v", err)
+	}
+	l.run(fs, settings, format, out)
+}
+
+// run looks up the IPs from --ip, the input file or stdin and writes them in
+// format, exiting with the lookup's status.
+func (l lookupFlags) run(fs *flag.FlagSet, settings config.Settings, format string, out outputFlags) {
+	backend, err := parseBackend(l.backendName)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	proxyCheckAPIKey := ""
+	if l.enrichFlag {
+		proxyCheckAPIKey, err = settings.Proxycheck.Key(context.Background())
+		if err != nil {
+			fatalf("proxycheck API key: %v", err)
+		}
+		if proxyCheckAPIKey == "" {
+			fatalf("--enrich (-e) requires PROXYCHECK_API_KEY in the environment or a proxycheck provider in the config profile")
+		}
+	}
+
+	reporter := l.progressOut.reporter()
+
+	// Determine input mode
+	var ips []string
+	var input io.Reader // Read as the lookup runs, for NDJSON and _bulk
+	if l.singleIP != "" {
+		// Single IP flag path
+		ips, err = parser.ParseIPsFromString(l.singleIP)
+		if err != nil || len(ips) == 0 {
+			fatalf("--ip is not a valid IPv4/IPv6 address: %v", l.singleIP)
+		}
+		input = strings.NewReader(ips[0])
+		out.source = "--ip"
+	} else {
+		out.source = "stdin"
+		if fs.NArg() > 0 {
+			out.source = fs.Arg(0)
+		}
+		r, closeInput := openLookupInput(fs)
+		defer closeInput()
+		counter := &countingReader{r: r, reporter: reporter}
+		if streamed(format) {
+			input = counter
+		} else {
+			ips, err = parser.ParseIPsProgress(counter, counter.found)
+			reporter.Clear()
+			if err != nil {
+				fatalf("failed to parse IPs: %v", err)
+			}
+			if len(ips) == 0 {
+				fatalf("no IPv4/IPv6 addresses were found in the input")
+			}
+			reporter.Complete(counter.event())
+		}
+	}
+
+	// Decide query method per guidelines: DNS for one IP, bulk WHOIS for 2+
+	clientOpts := append(l.netFlags.options(fs, settings), l.timeouts.options(fs, settings)...)
+	clientOpts = append(clientOpts,
+		ip2asn.WithBackend(backend),
+		clientProgress(reporter),
+		ip2asn.WithFallbackHandler(func(err error) {
+			reporter.Clear()
+			fmt.Fprintf(os.Stderr, "DNS lookup failed (%v). Falling back to WHOIS.\n", err)
+		}),
+	)
+	if l.enrichFlag {
+		clientOpts = append(clientOpts, ip2asn.WithProxycheck(proxyCheckAPIKey))
+	}
+	var store *cache.Store
+	if l.cacheFlag {
+		store = openCache(settings.CachePath, settings.CacheTTL)
+		clientOpts = append(clientOpts, ip2asn.WithCache(store))
+	}
+	client := ip2asn.New(clientOpts...)
+	out.httpClient = l.netFlags.httpClient(fs, settings)
+
+	// Ctrl+C from here on keeps what has arrived instead of losing it
+	interrupted := interruptContext()
+	ctx, cancel := l.timeouts.context(interrupted, fs, settings)
+	defer cancel()
+	if streamed(format) {
+		streamLookup(ctx, interrupted, client, input, out, format, l.enrichFlag, reporter, store)
+		return
+	}
+	var partial string
+	results, err := client.Lookup(ctx, ips)
+	reporter.Clear()
+	if err != nil {
+		if len(results) == 0 {
+			if interrupted.Err() != nil {
+				os.Exit(exitInterrupted)
+			}
+			fatalf("%v", err)
+		}
+		partial = partialReason(interrupted, err)
+		if interrupted.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Lookup incomplete: %v\n", err)
+		}
+	}
+	if store != nil {
+		if err := store.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cache update failed: %v\n", err)
+		}
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "No results returned from Team Cymru.")
+		os.Exit(1)
+	}
+
+	var enrichmentError string
+	if l.enrichFlag {
+		warningMessage, err := client.Enrich(ctx, results)
+		reporter.Clear()
+		switch {
+		case interrupted.Err() != nil:
+			enrichmentError = "interrupted"
+			partial = partialReason(interrupted, err)
+		case err != nil:
+			enrichmentError = err.Error()
+		case warningMessage != "":
+			fmt.Fprintf(os.Stderr, "Proxycheck enrichment warning: %s\n", warningMessage)
+		}
+	}
+
+	reporter.Finish()
+	writeResults(results, format, out, l.enrichFlag, enrichmentError, partial)
+	if partial != "" {
+		exitPartial(interrupted)
+	}
+}
+
+// openLookupInput opens the positional input file, or stdin when it is not
+// a terminal, printing usage and exiting when there is no input.
+func openLookupInput(fs *flag.FlagSet) (io.Reader, func()) {
+	args := fs.Args()
+	if len(args) > 1 {
+		fatalf("expected at most one input file, got %d", len(args))
+	}
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fatalf("failed to open input file: %v", err)
+		}
+		return bufio.NewReader(f), func() { f.Close() }
+	}
+	// If stdin is not a terminal, read from stdin
+	stat, _ := os.Stdin.Stat()
+	if (stat.Mode() & os.ModeCharDevice) != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return bufio.NewReader(os.Stdin), func() {}
+}
+
+func lookupUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage: ip2asn [lookup] [--json|-j | --csv|-c | --ndjson | --template text | --format name] [--schema name] [--fields list] [--output|-o path] [--enrich|-e] [--tui|-t] [--cache] [--backend name] [--timeout d] [--resolver addr] [--proxy url] [--config path] [--profile name] [--ip|-i IP] [file]\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Examples:\n")
+	fmt.Fprintf(os.Stderr, "  echo 'IPs: 8.8.8.8 and 1.1.1.1' | ip2asn\n")
+	fmt.Fprintf(os.Stderr, "  ip2asn --ip 2001:4860:4860::8888 --json\n")
+	fmt.Fprintf(os.Stderr, "  ip2asn --tui input.txt\n")
+	fmt.Fprintf(os.Stderr, "  PROXYCHECK_API_KEY=... ip2asn --enrich input.txt  # proxycheck-focused table view\n")
+	fmt.Fprintf(os.Stderr, "  PROXYCHECK_API_KEY=... ip2asn --tui --enrich input.txt\n")
+	fmt.Fprintf(os.Stderr, "  ip2asn --csv --output out.csv input.txt\n")
+	fmt.Fprintf(os.Stderr, "  tail -f access.log | ip2asn --ndjson | jq .asn\n")
+	fmt.Fprintf(os.Stderr, "  ip2asn --format bulk input.txt | curl -H 'Content-Type: application/x-ndjson' --data-binary @- http://localhost:9200/_bulk\n")
+	fmt.Fprintf(os.Stderr, "  ip2asn --template '{{.IP}} AS{{.ASN}} {{.ASName}}{{\"\\n\"}}' input.txt\n")
+	fmt.Fprintf(os.Stderr, "  ip2asn --profile work input.txt\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	fs.PrintDefaults()
+}
+
+// openCache opens the cache file at path (or the default location), exiting
+// on failure.
+func openCache(path string, ttl time.Duration) *cache.Store {
+	if path == "" {
+		var err error
+		if path, err = cache.DefaultPath(); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	store, err := cache.Open(path, ttl)
+	if err != nil {
+		fatalf("failed to open cache: %v", err)
+	}
+	return store
+}
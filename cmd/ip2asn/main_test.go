@@ -1,9 +1,10 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
-	"ip2asn/internal/output"
+	"github.com/hink/ip2asn/internal/output"
 )
 
 func TestValidateTUIOptions(t *testing.T) {
@@ -111,3 +112,130 @@ func TestChooseTableMode(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCmd  string
+		wantArgs []string
+	}{
+		{name: "no args defaults to lookup", args: nil, wantCmd: "lookup"},
+		{name: "file arg defaults to lookup", args: []string{"input.txt"}, wantCmd: "lookup", wantArgs: []string{"input.txt"}},
+		{name: "flags default to lookup", args: []string{"--json", "input.txt"}, wantCmd: "lookup", wantArgs: []string{"--json", "input.txt"}},
+		{name: "explicit lookup", args: []string{"lookup", "--csv"}, wantCmd: "lookup", wantArgs: []string{"--csv"}},
+		{name: "asn subcommand", args: []string{"asn", "13335"}, wantCmd: "asn", wantArgs: []string{"13335"}},
+		{name: "version subcommand", args: []string{"version"}, wantCmd: "version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, args := resolveCommand(tt.args)
+			if cmd.name != tt.wantCmd {
+				t.Fatalf("resolveCommand() command = %q, want %q", cmd.name, tt.wantCmd)
+			}
+			if strings.Join(args, " ") != strings.Join(tt.wantArgs, " ") {
+				t.Fatalf("resolveCommand() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestPrefixAddrs(t *testing.T) {
+	got, err := prefixAddrs([]string{"1.1.1.7/24", "1.1.1.0/24", "2001:db8::1/32"})
+	if err != nil {
+		t.Fatalf("prefixAddrs() error = %v", err)
+	}
+	if strings.Join(got, " ") != "1.1.1.0 2001:db8::" {
+		t.Fatalf("prefixAddrs() = %v, want masked, de-duplicated network addresses", got)
+	}
+
+	if _, err := prefixAddrs([]string{"1.1.1.1"}); err == nil {
+		t.Fatal("expected bare IP to be rejected as a prefix")
+	}
+}
+
+func TestOutputFlagsFormatPrecedence(t *testing.T) {
+	tests := []struct {
+		name          string
+		flags         outputFlags
+		defaultFormat string
+		want          string
+		wantErr       bool
+	}{
+		{name: "profile default", defaultFormat: "json", want: "json"},
+		{name: "format flag over profile", flags: outputFlags{formatName: "table"}, defaultFormat: "json", want: "table"},
+		{name: "csv flag over profile", flags: outputFlags{csvFlag: true}, defaultFormat: "json", want: "csv"},
+		{name: "matching format and csv flags", flags: outputFlags{csvFlag: true, formatName: "csv"}, defaultFormat: "table", want: "csv"},
+		{name: "conflicting format and json flags", flags: outputFlags{jsonFlag: true, formatName: "csv"}, defaultFormat: "table", wantErr: true},
+		{name: "ndjson flag over profile", flags: outputFlags{ndjsonFlag: true}, defaultFormat: "json", want: "ndjson"},
+		{name: "conflicting ndjson and csv flags", flags: outputFlags{ndjsonFlag: true, csvFlag: true}, defaultFormat: "table", wantErr: true},
+		{name: "template flag implies template format", flags: outputFlags{tmplText: "{{.IP}}"}, defaultFormat: "json", want: "template"},
+		{name: "template with another format", flags: outputFlags{tmplText: "{{.IP}}", csvFlag: true}, defaultFormat: "table", wantErr: true},
+		{name: "template format without a template", flags: outputFlags{formatName: "template"}, defaultFormat: "table", wantErr: true},
+		{name: "invalid template", flags: outputFlags{tmplText: "{{.IP"}, defaultFormat: "table", wantErr: true},
+		{name: "missing template file", flags: outputFlags{tmplFile: "/nonexistent/ip2asn.tmpl"}, defaultFormat: "table", wantErr: true},
+		{name: "markup format", flags: outputFlags{formatName: "asciidoc"}, defaultFormat: "table", want: "asciidoc"},
+		{name: "stix format", flags: outputFlags{formatName: "stix"}, defaultFormat: "table", want: "stix"},
+		{name: "misp format", flags: outputFlags{formatName: "misp", mispIPType: "ip-dst"}, defaultFormat: "table", want: "misp"},
+		{name: "sqlite format", flags: outputFlags{formatName: "sqlite", outPath: "run.db"}, defaultFormat: "table", want: "sqlite"},
+		{name: "sqlite format without a database", flags: outputFlags{formatName: "sqlite"}, defaultFormat: "table", wantErr: true},
+		{name: "parquet format", flags: outputFlags{formatName: "parquet", outPath: "lake/"}, defaultFormat: "table", want: "parquet"},
+		{name: "parquet format without an output", flags: outputFlags{formatName: "parquet"}, defaultFormat: "table", wantErr: true},
+		{name: "misp flags with another format", flags: outputFlags{mispInfo: "Incident 42"}, defaultFormat: "json", wantErr: true},
+		{name: "ecs schema implies ndjson", flags: outputFlags{schemaName: "ecs"}, defaultFormat: "table", want: "ndjson"},
+		{name: "flat schema keeps the profile format", flags: outputFlags{schemaName: "flat"}, defaultFormat: "csv", want: "csv"},
+		{name: "cim schema with ndjson flag", flags: outputFlags{schemaName: "cim", ndjsonFlag: true}, defaultFormat: "table", want: "ndjson"},
+		{name: "cim schema with another format", flags: outputFlags{schemaName: "cim", csvFlag: true}, defaultFormat: "table", wantErr: true},
+		{name: "unknown schema", flags: outputFlags{schemaName: "ocsf"}, defaultFormat: "table", wantErr: true},
+		{name: "bulk format", flags: outputFlags{formatName: "bulk", bulkIndex: "ip2asn"}, defaultFormat: "table", want: "bulk"},
+		{name: "bulk format with cim schema", flags: outputFlags{formatName: "bulk", bulkIndex: "ip2asn", schemaName: "cim"}, defaultFormat: "table", wantErr: true},
+		{name: "bulk format without an index", flags: outputFlags{formatName: "bulk"}, defaultFormat: "table", wantErr: true},
+		{name: "unknown profile format", defaultFormat: "yaml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.flags.format(tt.defaultFormat)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("format() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputFlagsFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      outputFlags
+		enrich     bool
+		wantFields int
+		wantErr    bool
+	}{
+		{name: "mixed Cymru and proxycheck fields", flags: outputFlags{fieldsSpec: "asn,ip,risk"}, enrich: true, wantFields: 3},
+		{name: "flat JSON", flags: outputFlags{fieldsSpec: "asn,ip", ndjsonFlag: true}, wantFields: 2},
+		{name: "grouped JSON", flags: outputFlags{fieldsSpec: "asn,ip", jsonFlag: true}, wantErr: true},
+		{name: "STIX bundle", flags: outputFlags{fieldsSpec: "asn,ip", formatName: "stix"}, wantErr: true},
+		{name: "ECS documents", flags: outputFlags{fieldsSpec: "asn,ip", schemaName: "ecs"}, wantErr: true},
+		{name: "unknown field", flags: outputFlags{fieldsSpec: "asn,bogus"}, wantErr: true},
+		{name: "proxycheck field without enrichment", flags: outputFlags{fieldsSpec: "asn,risk"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.flags.format("table")
+			if err == nil {
+				err = tt.flags.checkFieldEnrichment(tt.enrich)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(tt.flags.fields) != tt.wantFields {
+				t.Fatalf("got %d fields, want %d", len(tt.flags.fields), tt.wantFields)
+			}
+		})
+	}
+}
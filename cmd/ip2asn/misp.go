@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/hink/ip2asn/internal/misp"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+const (
+	// envMISPKey holds the MISP automation key for --misp-url
+	envMISPKey = "MISP_API_KEY"
+	// mispUploadTimeout bounds the --misp-url request
+	mispUploadTimeout = 30 * time.Second
+)
+
+// checkMISP validates the MISP flags for format.
+func (o outputFlags) checkMISP(format string) error {
+	if format != "misp" {
+		if o.mispURL != "" || o.mispInfo != "" {
+			return fmt.Errorf("--misp-url and --misp-info only apply to --format misp")
+		}
+		return nil
+	}
+	switch o.mispIPType {
+	case "", output.MISPIPSource, output.MISPIPDestination:
+	default:
+		return fmt.Errorf("--misp-ip-type must be %s or %s, not %q", output.MISPIPSource, output.MISPIPDestination, o.mispIPType)
+	}
+	if o.mispURL == "" {
+		return nil
+	}
+	u, err := url.Parse(o.mispURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("--misp-url must be an http:// or https:// URL, not %q", o.mispURL)
+	}
+	if os.Getenv(envMISPKey) == "" {
+		return fmt.Errorf("--misp-url requires %s in the environment", envMISPKey)
+	}
+	return nil
+}
+
+// uploadMISP adds event to the MISP instance at --misp-url, exiting on
+// failure.
+func uploadMISP(o outputFlags, event output.MISPEvent) {
+	client := misp.NewClient(o.mispURL, os.Getenv(envMISPKey))
+	if o.httpClient != nil {
+		client.HTTPClient = o.httpClient
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mispUploadTimeout)
+	defer cancel()
+	created, err := client.AddEvent(ctx, event)
+	if err != nil {
+		fatalf("MISP upload failed: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Created MISP event %s (%s)\n", created.ID, created.UUID)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/output"
+)
+
+func TestCheckMISP(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   outputFlags
+		format  string
+		key     string
+		wantErr bool
+	}{
+		{name: "file only", flags: outputFlags{mispIPType: "ip-dst"}, format: "misp"},
+		{name: "upload", flags: outputFlags{mispURL: "https://misp.example.org"}, format: "misp", key: "k"},
+		{name: "upload without a key", flags: outputFlags{mispURL: "https://misp.example.org"}, format: "misp", wantErr: true},
+		{name: "upload URL without a scheme", flags: outputFlags{mispURL: "misp.example.org"}, format: "misp", key: "k", wantErr: true},
+		{name: "unknown IP type", flags: outputFlags{mispIPType: "ip"}, format: "misp", wantErr: true},
+		{name: "upload with another format", flags: outputFlags{mispURL: "https://misp.example.org"}, format: "json", key: "k", wantErr: true},
+		{name: "other format ignores the IP type default", flags: outputFlags{mispIPType: "ip-src"}, format: "csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envMISPKey, tt.key)
+			if err := tt.flags.checkMISP(tt.format); (err != nil) != tt.wantErr {
+				t.Fatalf("checkMISP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadMISP(t *testing.T) {
+	// A stand-in for a MISP instance's /events/add
+	var received output.MISPEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/add" || r.Header.Get("Authorization") != "test-key" {
+			http.Error(w, `{"message":"Authentication failed."}`, http.StatusForbidden)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, `{"message":"Invalid JSON"}`, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Event":{"id":"7","uuid":"` + received.Event.UUID + `"}}`))
+	}))
+	defer server.Close()
+	t.Setenv(envMISPKey, "test-key")
+
+	run := output.RunInfo{Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	event := output.NewMISPEvent(nil, false, run, output.MISPOptions{Info: "Incident 42"})
+	uploadMISP(outputFlags{mispURL: server.URL, httpClient: server.Client()}, event)
+	if received.Event.Info != "Incident 42" || received.Event.UUID != event.Event.UUID {
+		t.Fatalf("unexpected uploaded event %+v", received.Event)
+	}
+}
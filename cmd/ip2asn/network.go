@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hink/ip2asn/internal/config"
+	"github.com/hink/ip2asn/internal/netproxy"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+const (
+	defaultDNSTimeout = 3 * time.Second
+	defaultDNSRetries = 2
+)
+
+// networkFlags select how ip2asn reaches Team Cymru.
+type networkFlags struct {
+	resolver   string
+	dnsTimeout time.Duration
+	dnsRetries int
+	proxy      string
+}
+
+func (n *networkFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&n.resolver, "resolver", "", "DNS resolver: host[:port], tcp://host[:port], tls://host[:port] or https://host/dns-query (default $"+config.EnvResolver+", else the system resolver; required with --proxy)")
+	fs.DurationVar(&n.dnsTimeout, "dns-timeout", defaultDNSTimeout, "timeout per DNS query attempt")
+	fs.IntVar(&n.dnsRetries, "dns-retries", defaultDNSRetries, "retries after a failed DNS query")
+	fs.StringVar(&n.proxy, "proxy", "", "outbound proxy: socks5://[user:pass@]host:port or http://host:port (default $"+config.EnvProxy+")")
+}
+
+// options returns the client options for settings with the network flags
+// given on fs applied on top, exiting on invalid values.
+func (n networkFlags) options(fs *flag.FlagSet, settings config.Settings) []ip2asn.Option {
+	opts, err := n.buildOptions(fs, settings)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return opts
+}
+
+func (n networkFlags) buildOptions(fs *flag.FlagSet, settings config.Settings) ([]ip2asn.Option, error) {
+	set := flagsSet(fs)
+	if set["resolver"] {
+		settings.Resolver = n.resolver
+	}
+	if set["dns-timeout"] {
+		settings.DNSTimeout = n.dnsTimeout
+	}
+	if set["dns-retries"] {
+		settings.DNSRetries = n.dnsRetries
+	}
+	if settings.DNSRetries < 0 {
+		return nil, errors.New("--dns-retries must not be negative")
+	}
+
+	resolverCfg := ip2asn.ResolverConfig{
+		Timeout: settings.DNSTimeout,
+		Retries: settings.DNSRetries,
+	}
+	var opts []ip2asn.Option
+	proxyURL, err := n.proxyURL(fs, settings)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL != nil {
+		// The system resolver cannot be proxied and would send DNS queries
+		// around the proxy
+		if settings.Resolver == "" {
+			return nil, fmt.Errorf("--proxy needs a DNS server to reach through it; set --resolver, $%s or resolver in the config profile", config.EnvResolver)
+		}
+		dialer, err := netproxy.NewDialer(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		httpClient := netproxy.NewHTTPClient(proxyURL)
+		// Proxies carry TCP only, so plain DNS switches to TCP
+		resolverCfg.Dialer = dialer
+		resolverCfg.TCPOnly = true
+		resolverCfg.HTTPClient = httpClient
+		opts = append(opts, ip2asn.WithDialer(dialer), ip2asn.WithHTTPClient(httpClient))
+	}
+
+	resolver, err := ip2asn.NewResolver(settings.Resolver, resolverCfg)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, ip2asn.WithResolver(resolver)), nil
+}
+
+// httpClient returns the HTTP client for other requests, such as uploads:
+// one through the proxy when there is one, otherwise nil for the default.
+func (n networkFlags) httpClient(fs *flag.FlagSet, settings config.Settings) *http.Client {
+	proxyURL, err := n.proxyURL(fs, settings)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if proxyURL == nil {
+		return nil
+	}
+	return netproxy.NewHTTPClient(proxyURL)
+}
+
+// proxyURL returns --proxy, or the proxy of settings, or nil when there is
+// none.
+func (n networkFlags) proxyURL(fs *flag.FlagSet, settings config.Settings) (*url.URL, error) {
+	proxy := settings.Proxy
+	if flagsSet(fs)["proxy"] {
+		proxy = n.proxy
+	}
+	if proxy == "" {
+		return nil, nil
+	}
+	return netproxy.Parse(proxy)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/config"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+func TestProxyCarriesDNSQueries(t *testing.T) {
+	// An HTTP CONNECT proxy that records each tunnel and refuses it
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	tunnels := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err == nil {
+				tunnels <- req.Host
+			}
+			conn.Write([]byte("HTTP/1.1 403 Forbidden\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+
+	// Queries that bypass the proxy would go through the system resolver
+	direct := make(chan string, 10)
+	system := net.DefaultResolver
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(_ context.Context, network, address string) (net.Conn, error) {
+			direct <- network + " " + address
+			return nil, errors.New("direct DNS query")
+		},
+	}
+	defer func() { net.DefaultResolver = system }()
+
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	var n networkFlags
+	n.register(fs)
+	if err := fs.Parse([]string{"--proxy", "http://" + ln.Addr().String(), "--resolver", "tls://192.0.2.53:853", "--dns-retries", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := n.buildOptions(fs, config.Settings{DNSTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append(opts, ip2asn.WithBackend(ip2asn.BackendDNS))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = ip2asn.New(opts...).Lookup(ctx, []string{"192.0.2.1"})
+
+	select {
+	case host := <-tunnels:
+		if host != "192.0.2.53:853" {
+			t.Fatalf("expected a tunnel to the resolver, got %s", host)
+		}
+	default:
+		t.Fatal("expected the DNS lookup to go through the proxy")
+	}
+	select {
+	case query := <-direct:
+		t.Fatalf("expected no direct DNS query, got %s", query)
+	default:
+	}
+}
+
+func TestProxyRequiresResolver(t *testing.T) {
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	var n networkFlags
+	n.register(fs)
+	if err := fs.Parse([]string{"--proxy", "socks5://127.0.0.1:1080"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.buildOptions(fs, config.Settings{}); err == nil || !strings.Contains(err.Error(), "set --resolver") {
+		t.Fatalf("expected --proxy without a resolver to fail, got %v", err)
+	}
+
+	// A resolver from the profile is enough
+	if _, err := n.buildOptions(fs, config.Settings{Resolver: "tls://192.0.2.53:853"}); err != nil {
+		t.Fatalf("expected the profile resolver to be used, got %v", err)
+	}
+}
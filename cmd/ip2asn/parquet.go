@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
+	"github.com/hink/ip2asn/internal/parquetfile"
+)
+
+// writeParquet writes results to the file --output names once its
+// placeholders are expanded, creating its directories, and returns the path.
+// The file is written under a temporary name and renamed into place, so
+// readers scanning the directory never see a partial file.
+func writeParquet(pattern string, results []model.Result, run output.RunInfo, runID string) (string, error) {
+	info, err := os.Stat(pattern)
+	isDir := err == nil && info.IsDir()
+	path := parquetfile.Path(pattern, isDir, run.Generated, runID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if err := parquetfile.Write(f, results, run, runID); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	// CreateTemp makes the file 0600; match os.Create
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return "", err
+	}
+	return path, os.Rename(f.Name(), path)
+}
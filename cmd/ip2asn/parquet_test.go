@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+func TestWriteParquet(t *testing.T) {
+	dir := t.TempDir()
+	results := []model.Result{{ASN: 64500, IP: netip.MustParseAddr("192.0.2.1")}}
+	run := output.RunInfo{Version: "9.9", Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{filepath.Join(dir, "run.parquet"), filepath.Join(dir, "run.parquet")},
+		{dir, filepath.Join(dir, "date=2026-10-18", "ip2asn-r1.parquet")},
+		{filepath.Join(dir, "lake", "h={hour}", "{run}.parquet"), filepath.Join(dir, "lake", "h=12", "r1.parquet")},
+	}
+	for _, tt := range tests {
+		path, err := writeParquet(tt.pattern, results, run, "r1")
+		if err != nil {
+			t.Fatalf("writeParquet(%q): %v", tt.pattern, err)
+		}
+		if path != tt.want {
+			t.Fatalf("writeParquet(%q) wrote %q, want %q", tt.pattern, path, tt.want)
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == 0 || info.Mode().Perm() != 0o644 {
+			t.Fatalf("expected a 0644 Parquet file at %s: %v %v", path, info, err)
+		}
+		entries, _ := os.ReadDir(filepath.Dir(path))
+		for _, e := range entries {
+			if filepath.Ext(e.Name()) == ".tmp" {
+				t.Fatalf("left temporary file %s behind", e.Name())
+			}
+		}
+	}
+}
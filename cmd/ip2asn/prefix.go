@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+func runPrefix(args []string) {
+	var (
+		out      outputFlags
+		cfg      configFlags
+		netFlags networkFlags
+		timeouts timeoutFlags
+	)
+
+	fs := flag.NewFlagSet("prefix", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ip2asn prefix [--json|-j | --csv|-c | --format name] [--output|-o path] [--tui|-t] [--timeout d] [--resolver addr] [--proxy url] [--config path] [--profile name] PREFIX...\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Looks up the network address of each prefix over DNS and reports the\n")
+		fmt.Fprintf(os.Stderr, "announced BGP prefix covering it.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  ip2asn prefix 1.1.1.0/24 2001:4860::/32\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	out.register(fs)
+	timeouts.register(fs, false)
+	netFlags.register(fs)
+	cfg.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	settings := cfg.settings()
+	format, err := out.format(settings.Format)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := out.checkFieldEnrichment(false); err != nil {
+		fatalf("%v", err)
+	}
+
+	ips, err := prefixAddrs(fs.Args())
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	clientOpts := append(netFlags.options(fs, settings), timeouts.options(fs, settings)...)
+	client := ip2asn.New(append(clientOpts, ip2asn.WithBackend(ip2asn.BackendDNS))...)
+	out.httpClient = netFlags.httpClient(fs, settings)
+	interrupted := interruptContext()
+	ctx, cancel := timeouts.context(interrupted, fs, settings)
+	defer cancel()
+	var partial string
+	results, err := client.Lookup(ctx, ips)
+	if err != nil {
+		if len(results) == 0 {
+			if interrupted.Err() != nil {
+				os.Exit(exitInterrupted)
+			}
+			fatalf("%v", err)
+		}
+		partial = partialReason(interrupted, err)
+	}
+	writeResults(results, format, out, false, "", partial)
+	if partial != "" {
+		exitPartial(interrupted)
+	}
+}
+
+// prefixAddrs returns the de-duplicated network address of each prefix.
+func prefixAddrs(args []string) ([]string, error) {
+	seen := make(map[netip.Addr]struct{}, len(args))
+	ips := make([]string, 0, len(args))
+	for _, arg := range args {
+		prefix, err := netip.ParsePrefix(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q", arg)
+		}
+		addr := prefix.Masked().Addr()
+		if _, exists := seen[addr]; exists {
+			continue
+		}
+		seen[addr] = struct{}{}
+		ips = append(ips, addr.String())
+	}
+	return ips, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/hink/ip2asn/internal/progress"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+// progressFlags select where run progress is reported.
+type progressFlags struct {
+	show bool
+	fd   int
+}
+
+func (p *progressFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&p.show, "progress", true, "show progress on stderr when it is a terminal")
+	fs.IntVar(&p.fd, "progress-fd", 0, "also write progress events as JSON lines to this file descriptor, e.g. 3")
+}
+
+// reporter returns the progress reporter for the flags, or nil when progress
+// is off.
+func (p progressFlags) reporter() *progress.Reporter {
+	var term, events io.Writer
+	if p.show && isTerminal(os.Stderr) {
+		term = os.Stderr
+	}
+	if p.fd > 0 {
+		f := os.NewFile(uintptr(p.fd), "progress")
+		if f == nil {
+			fatalf("--progress-fd %d is not a valid file descriptor", p.fd)
+		}
+		if _, err := f.Stat(); err != nil {
+			fatalf("--progress-fd %d: %v", p.fd, err)
+		}
+		events = f
+	}
+	return progress.New(term, events)
+}
+
+// clientProgress forwards client progress to r.
+func clientProgress(r *progress.Reporter) ip2asn.Option {
+	return ip2asn.WithProgress(func(p ip2asn.Progress) {
+		r.Update(progress.Event{Stage: string(p.Stage), Done: p.Done, Total: p.Total, Session: p.Session, Sessions: p.Sessions})
+	})
+}
+
+// countingReader reports the bytes read through it as parse progress, along
+// with the unique IPs its parser has found so far.
+type countingReader struct {
+	r        io.Reader
+	n        int64
+	ips      int // Set by the parser reading through it
+	reporter *progress.Reporter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	c.reporter.Update(c.event())
+	return n, err
+}
+
+// found records that the parser has found ips unique IPs.
+func (c *countingReader) found(ips int) {
+	c.ips = ips
+	c.reporter.Update(c.event())
+}
+
+func (c *countingReader) event() progress.Event {
+	return progress.Event{Stage: progress.StageParse, Bytes: c.n, IPs: c.ips}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runReport(args []string) {
+	var (
+		out      outputFlags
+		l        lookupFlags
+		htmlPath string
+	)
+
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ip2asn report --html path [--title text] [--fields list] [--enrich|-e] [--cache] [--backend name] [--timeout d] [--resolver addr] [--proxy url] [--config path] [--profile name] [--ip|-i IP] [file]\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Looks up the IPs found in a file or stdin and writes a self-contained HTML\n")
+		fmt.Fprintf(os.Stderr, "report: summary counts, country and risk breakdowns, a section per origin\n")
+		fmt.Fprintf(os.Stderr, "AS and sortable, filterable tables. It needs no network access to view.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  ip2asn report --html report.html access.log\n")
+		fmt.Fprintf(os.Stderr, "  PROXYCHECK_API_KEY=... ip2asn report --enrich --title 'Incident 42' --html report.html iocs.txt\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&htmlPath, "html", "", "write the HTML report to this file, or - for stdout (required)")
+	fs.StringVar(&out.title, "title", "", "report heading (default \"ip2asn report\")")
+	fs.StringVar(&out.fieldsSpec, "fields", "", "comma-separated result table columns, in order (see --fields help)")
+	l.register(fs)
+	_ = fs.Parse(args)
+
+	if err := out.parseFields(); err != nil {
+		fatalf("%v", err)
+	}
+	switch htmlPath {
+	case "":
+		fatalf("--html is required, e.g. ip2asn report --html report.html input.txt")
+	case "-":
+	default:
+		out.outPath = htmlPath
+	}
+
+	settings := l.settings(fs)
+	if err := out.checkFieldEnrichment(l.enrichFlag); err != nil {
+		fatalf("%v", err)
+	}
+	l.run(fs, settings, "report", out)
+}
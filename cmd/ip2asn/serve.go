@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hink/ip2asn/internal/config"
+	"github.com/hink/ip2asn/internal/server"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+const cacheSaveInterval = time.Minute
+
+// serverFlags are the listener, limit and upstream flags shared by serve and
+// serve-whois.
+type serverFlags struct {
+	network         networkFlags
+	listen          string
+	backendName     string
+	requestTimeout  time.Duration
+	shutdownTimeout time.Duration
+	maxIPs          int
+	coalesceWindow  time.Duration
+	maxBatch        int
+	upstreamRate    float64
+	upstreamBurst   int
+}
+
+func (f *serverFlags) register(fs *flag.FlagSet, listen string) {
+	fs.StringVar(&f.listen, "listen", listen, "address to listen on")
+	f.network.register(fs)
+	fs.StringVar(&f.backendName, "backend", "", "upstream backend: whois (the default; auto means whois) or dns, which queries and is rate limited per IP")
+	fs.DurationVar(&f.requestTimeout, "request-timeout", 15*time.Second, "lookup budget per request")
+	fs.DurationVar(&f.shutdownTimeout, "shutdown-timeout", 10*time.Second, "grace period for in-flight requests on shutdown")
+	fs.IntVar(&f.maxIPs, "max-ips", 10000, "maximum IPs per request")
+	fs.DurationVar(&f.coalesceWindow, "coalesce-window", 50*time.Millisecond, "how long lookups wait to share an upstream WHOIS session")
+	fs.IntVar(&f.maxBatch, "max-batch", 1000, "maximum IPs per upstream WHOIS session")
+	fs.Float64Var(&f.upstreamRate, "upstream-rate", 0, "maximum upstream queries per second (0 = unlimited)")
+	fs.IntVar(&f.upstreamBurst, "upstream-burst", 1, "upstream queries allowed in a burst above --upstream-rate")
+}
+
+// backend resolves --backend over the profile's backend. Coalesced batches
+// are bulk queries, so auto means WHOIS rather than DNS per batch.
+func (f serverFlags) backend(backendName string) ip2asn.Backend {
+	if f.backendName != "" {
+		backendName = f.backendName
+	}
+	backend, err := parseBackend(backendName)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if backend == ip2asn.BackendAuto {
+		return ip2asn.BackendWhois
+	}
+	return backend
+}
+
+func (f serverFlags) config(backendName string) server.Config {
+	return server.Config{
+		Addr:            f.listen,
+		RequestTimeout:  f.requestTimeout,
+		ShutdownTimeout: f.shutdownTimeout,
+		MaxIPs:          f.maxIPs,
+		CoalesceWindow:  f.coalesceWindow,
+		MaxBatch:        f.maxBatch,
+		UpstreamRate:    f.upstreamRate,
+		UpstreamBurst:   f.upstreamBurst,
+		UpstreamPerIP:   f.backend(backendName) == ip2asn.BackendDNS,
+	}
+}
+
+// clientOptions returns the lookup client options for a server. The
+// per-request timeout is the budget, so the client itself has none.
+func (f serverFlags) clientOptions(fs *flag.FlagSet, settings config.Settings) []ip2asn.Option {
+	opts := append(f.network.options(fs, settings),
+		ip2asn.WithBackend(f.backend(settings.Backend)),
+		ip2asn.WithTimeout(0),
+	)
+	if settings.EnrichTimeout > 0 {
+		opts = append(opts, ip2asn.WithEnrichTimeout(settings.EnrichTimeout))
+	}
+	return opts
+}
+
+// serverSettings resolves the settings of serve-whois and serve-dns, which
+// use the cache unless the profile, $IP2ASN_CACHE or --cache turn it off.
+func serverSettings(fs *flag.FlagSet, cfg configFlags, cacheFlag *bool) config.Settings {
+	defaults := defaultSettings()
+	defaults.CacheEnabled = true
+	settings := cfg.resolve(defaults)
+	if !anySet(flagsSet(fs), "cache") {
+		*cacheFlag = settings.CacheEnabled
+	}
+	return settings
+}
+
+// serverCache opens the lookup cache for a long-running server and saves it
+// every cacheSaveInterval and once more when ctx is done. The returned wait
+// function blocks until that final save has finished.
+func serverCache(ctx context.Context, settings config.Settings) (ip2asn.Option, func()) {
+	store := openCache(settings.CachePath, settings.CacheTTL)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cacheSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+			if err := store.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "Cache update failed: %v\n", err)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return ip2asn.WithCache(store), func() { <-done }
+}
+
+func runServe(args []string) {
+	var (
+		cfg        configFlags
+		srvFlags   serverFlags
+		enrichFlag bool
+	)
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ip2asn serve [--listen addr] [--enrich|-e] [--backend name] [--resolver addr] [--proxy url] [--request-timeout d] [--shutdown-timeout d] [--max-ips n] [--coalesce-window d] [--max-batch n] [--upstream-rate n] [--config path] [--profile name]\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Endpoints:\n")
+		fmt.Fprintf(os.Stderr, "  GET  /v1/ip/{ip}     look up one IP\n")
+		fmt.Fprintf(os.Stderr, "  POST /v1/lookup      look up IPs from a text or JSON body\n")
+		fmt.Fprintf(os.Stderr, "  GET  /v1/asn/{asn}   AS registration data\n")
+		fmt.Fprintf(os.Stderr, "  GET  /healthz, /readyz\n")
+		fmt.Fprintf(os.Stderr, "Lookup endpoints accept ?format=flat and ?enrich=true.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	srvFlags.register(fs, ":8080")
+	fs.BoolVar(&enrichFlag, "enrich", false, "allow ?enrich=true using proxycheck.io")
+	fs.BoolVar(&enrichFlag, "e", false, "allow ?enrich=true using proxycheck.io")
+	cfg.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fatalf("unexpected arguments: %v", fs.Args())
+	}
+
+	settings := cfg.settings()
+	if !anySet(flagsSet(fs), "enrich", "e") {
+		enrichFlag = settings.Enrich
+	}
+
+	clientOpts := srvFlags.clientOptions(fs, settings)
+	if enrichFlag {
+		apiKey, err := settings.Proxycheck.Key(context.Background())
+		if err != nil {
+			fatalf("proxycheck API key: %v", err)
+		}
+		if apiKey == "" {
+			fatalf("--enrich (-e) requires PROXYCHECK_API_KEY in the environment or a proxycheck provider in the config profile")
+		}
+		clientOpts = append(clientOpts, ip2asn.WithProxycheck(apiKey))
+	}
+
+	serverCfg := srvFlags.config(settings.Backend)
+	serverCfg.Enrich = enrichFlag
+	srv := server.New(ip2asn.New(clientOpts...), serverCfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "ip2asn %s listening on %s\n", version, srvFlags.listen)
+	if err := srv.ListenAndServe(ctx); err != nil {
+		fatalf("serve: %v", err)
+	}
+}
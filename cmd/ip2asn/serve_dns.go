@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hink/ip2asn/internal/server"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+func runServeDNS(args []string) {
+	var (
+		cfg       configFlags
+		srvFlags  serverFlags
+		cacheFlag bool
+	)
+
+	fs := flag.NewFlagSet("serve-dns", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ip2asn serve-dns [--listen addr] [--cache=false] [--backend name] [--resolver addr] [--proxy url] [--request-timeout d] [--shutdown-timeout d] [--coalesce-window d] [--max-batch n] [--upstream-rate n] [--config path] [--profile name]\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Answers TXT queries for *.origin.asn.cymru.com, *.origin6.asn.cymru.com and\n")
+		fmt.Fprintf(os.Stderr, "AS*.asn.cymru.com over UDP and TCP, from the lookup cache and upstream.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  ip2asn serve-dns --listen 127.0.0.1:5353\n")
+		fmt.Fprintf(os.Stderr, "  dig +short -p 5353 @127.0.0.1 TXT 1.1.1.1.origin.asn.cymru.com\n")
+		fmt.Fprintf(os.Stderr, "  dig +short -p 5353 @127.0.0.1 TXT AS13335.asn.cymru.com\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	srvFlags.register(fs, ":53")
+	fs.BoolVar(&cacheFlag, "cache", true, "answer from and update the local lookup cache")
+	cfg.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fatalf("unexpected arguments: %v", fs.Args())
+	}
+
+	settings := serverSettings(fs, cfg, &cacheFlag)
+	clientOpts := srvFlags.clientOptions(fs, settings)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	waitCache := func() {}
+	if cacheFlag {
+		var cacheOpt ip2asn.Option
+		cacheOpt, waitCache = serverCache(ctx, settings)
+		clientOpts = append(clientOpts, cacheOpt)
+	}
+
+	srv := server.NewDNS(ip2asn.New(clientOpts...), srvFlags.config(settings.Backend))
+
+	fmt.Fprintf(os.Stderr, "ip2asn %s DNS responder listening on %s\n", version, srvFlags.listen)
+	err := srv.ListenAndServe(ctx)
+	// Save the cache before exiting, also when the server failed
+	stop()
+	waitCache()
+	if err != nil {
+		fatalf("serve-dns: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hink/ip2asn/internal/config"
+)
+
+func TestServerSettingsCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[profiles.nocache.cache]\nenabled = false\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.EnvCache, "")
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"--config", path}, true},
+		{[]string{"--config", path, "--profile", "nocache"}, false},
+		{[]string{"--config", path, "--profile", "nocache", "--cache"}, true},
+		{[]string{"--config", path, "--cache=false"}, false},
+	}
+	for _, tt := range tests {
+		var (
+			cfg       configFlags
+			cacheFlag bool
+		)
+		fs := flag.NewFlagSet("serve-whois", flag.ContinueOnError)
+		fs.BoolVar(&cacheFlag, "cache", true, "")
+		cfg.register(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		serverSettings(fs, cfg, &cacheFlag)
+		if cacheFlag != tt.want {
+			t.Fatalf("%v: expected cache %v, got %v", tt.args, tt.want, cacheFlag)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hink/ip2asn/internal/server"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+func runServeWhois(args []string) {
+	var (
+		cfg       configFlags
+		srvFlags  serverFlags
+		cacheFlag bool
+	)
+
+	fs := flag.NewFlagSet("serve-whois", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ip2asn serve-whois [--listen addr] [--cache=false] [--backend name] [--resolver addr] [--proxy url] [--request-timeout d] [--shutdown-timeout d] [--max-ips n] [--coalesce-window d] [--max-batch n] [--upstream-rate n] [--config path] [--profile name]\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Speaks the Team Cymru WHOIS protocol, answering from the lookup cache and\n")
+		fmt.Fprintf(os.Stderr, "forwarding misses to Team Cymru; there is no offline dataset.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  ip2asn serve-whois --listen :4343\n")
+		fmt.Fprintf(os.Stderr, "  printf 'begin\\nverbose\\n1.1.1.1\\nend\\n' | nc localhost 4343\n")
+		fmt.Fprintf(os.Stderr, "  whois -h localhost -p 4343 ' -v 1.1.1.1'\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	srvFlags.register(fs, ":43")
+	fs.BoolVar(&cacheFlag, "cache", true, "answer from and update the local lookup cache")
+	cfg.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fatalf("unexpected arguments: %v", fs.Args())
+	}
+
+	settings := serverSettings(fs, cfg, &cacheFlag)
+	clientOpts := srvFlags.clientOptions(fs, settings)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	waitCache := func() {}
+	if cacheFlag {
+		var cacheOpt ip2asn.Option
+		cacheOpt, waitCache = serverCache(ctx, settings)
+		clientOpts = append(clientOpts, cacheOpt)
+	}
+
+	srv := server.NewWhois(ip2asn.New(clientOpts...), srvFlags.config(settings.Backend))
+
+	fmt.Fprintf(os.Stderr, "ip2asn %s WHOIS mirror listening on %s\n", version, srvFlags.listen)
+	err := srv.ListenAndServe(ctx)
+	// Save the cache before exiting, also when the server failed
+	stop()
+	waitCache()
+	if err != nil {
+		fatalf("serve-whois: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hink/ip2asn/internal/config"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+const (
+	envConfig  = "IP2ASN_CONFIG"
+	envProfile = "IP2ASN_PROFILE"
+)
+
+// configFlags select the config file and profile.
+type configFlags struct {
+	path    string
+	profile string
+}
+
+func (c *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "config", "", "config file (default ~/.config/ip2asn/config.toml, or $"+envConfig+")")
+	fs.StringVar(&c.profile, "profile", "", "config profile to use (default: default_profile, or $"+envProfile+")")
+}
+
+// defaultSettings are the built-in defaults under every profile.
+func defaultSettings() config.Settings {
+	return config.Settings{
+		Format:     "table",
+		Backend:    "auto",
+		DNSTimeout: defaultDNSTimeout,
+		DNSRetries: defaultDNSRetries,
+	}
+}
+
+// settings returns defaultSettings overlaid with the selected profile and
+// the environment, exiting on configuration errors. Flags are applied by the
+// caller.
+func (c configFlags) settings() config.Settings {
+	return c.resolve(defaultSettings())
+}
+
+// resolve is settings over other built-in defaults, for commands whose
+// defaults differ from defaultSettings.
+func (c configFlags) resolve(defaults config.Settings) config.Settings {
+	path, optional := c.path, false
+	if path == "" {
+		path = os.Getenv(envConfig)
+	}
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			fatalf("%v", err)
+		}
+		optional = true
+	}
+
+	file, err := config.Load(path, optional)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	name := c.profile
+	if name == "" {
+		name = os.Getenv(envProfile)
+	}
+	profile, err := file.Profile(name)
+	if err != nil {
+		fatalf("%s: %v", path, err)
+	}
+
+	settings, err := config.Resolve(defaults, profile, os.Getenv)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return settings
+}
+
+// flagsSet reports which flags were given on the command line.
+func flagsSet(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// anySet reports whether any of the named flags (typically a flag and its
+// short alias) was given.
+func anySet(set map[string]bool, names ...string) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func parseBackend(name string) (ip2asn.Backend, error) {
+	switch name {
+	case "", "auto":
+		return ip2asn.BackendAuto, nil
+	case "dns":
+		return ip2asn.BackendDNS, nil
+	case "whois":
+		return ip2asn.BackendWhois, nil
+	default:
+		return ip2asn.BackendAuto, fmt.Errorf("unknown backend %q (want auto, dns or whois)", name)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hink/ip2asn/internal/cache"
+	"github.com/hink/ip2asn/internal/progress"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+// streamLookup looks up the IPs in r while it is still being read, writing
+// each result as an NDJSON line as soon as it arrives. There is no trailer:
+// an incomplete run is reported on stderr and by the exit status. ctx bounds
+// the run; interrupted is the interrupt context. format is "ndjson" or
+// "bulk".
+func streamLookup(ctx, interrupted context.Context, client *ip2asn.Client, r io.Reader, o outputFlags, format string, enrich bool, reporter *progress.Reporter, store *cache.Store) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The parser feeds the lookup one IP at a time; the buffer lets it read
+	// ahead while a session runs
+	ips := make(chan string, 1000)
+	parsed := make(chan error, 1)
+	var found int
+	report := func(int) {}
+	if counter, ok := r.(*countingReader); ok {
+		report = counter.found
+	}
+	go func() {
+		defer close(ips)
+		parsed <- ip2asn.ScanIPs(r, func(ip string) error {
+			select {
+			case ips <- ip:
+				found++
+				report(found)
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	w, closeOutput := openOutput(o.outPath)
+	defer closeOutput()
+	nw := o.ndjsonWriter(w, enrich, format)
+	var written int
+	opts := ip2asn.StreamOptions{
+		Enrich: enrich,
+		OnEnrichError: func(err error) {
+			reporter.Clear()
+			fmt.Fprintf(os.Stderr, "Proxycheck enrichment failed: %v\n", err)
+		},
+	}
+	err := client.LookupStream(ctx, ips, opts, func(result ip2asn.Result) error {
+		written++
+		return nw.Write(result)
+	})
+	reporter.Clear()
+	if store != nil {
+		if err := store.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cache update failed: %v\n", err)
+		}
+	}
+
+	if err != nil {
+		// The parser may be blocked reading input that never ends
+		if written == 0 {
+			if interrupted.Err() != nil {
+				os.Exit(exitInterrupted)
+			}
+			fatalf("%v", err)
+		}
+		if interrupted.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Lookup incomplete: %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Partial results: %s\n", partialReason(interrupted, err))
+		exitPartial(interrupted)
+	}
+
+	// ips is closed, so the parser has finished
+	if err := <-parsed; err != nil {
+		fatalf("failed to parse IPs: %v", err)
+	}
+	if found == 0 {
+		fatalf("no IPv4/IPv6 addresses were found in the input")
+	}
+	reporter.Finish()
+}
+
+// streamed reports whether format is written by streamLookup, a line per
+// result as it arrives.
+func streamed(format string) bool {
+	return format == "ndjson" || format == "bulk"
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hink/ip2asn/internal/cymru/cymrutest"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+func TestStreamLookupRepeatsIPsBeyondWindow(t *testing.T) {
+	whois := cymrutest.NewWhoisServer(t, func(ips []string) cymrutest.WhoisReply {
+		rows := make([]cymrutest.Row, 0, len(ips))
+		for _, ip := range ips {
+			rows = append(rows, cymrutest.Row{ASN: "64500", IP: ip, Prefix: "10.0.0.0/8", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "TEST-NET"})
+		}
+		return cymrutest.WhoisReply{Body: cymrutest.BulkResponse(rows...)}
+	})
+
+	// A repeat within the window is dropped; after 100,000 newer IPs the
+	// first one has dropped out of it and is written again
+	var input strings.Builder
+	input.WriteString("10.0.0.0\n10.0.0.0\n")
+	for i := 1; i <= 100000; i++ {
+		fmt.Fprintf(&input, "10.%d.%d.%d\n", i>>16, i>>8&0xff, i&0xff)
+	}
+	input.WriteString("10.0.0.0\n")
+
+	outPath := filepath.Join(t.TempDir(), "out.ndjson")
+	client := ip2asn.New(ip2asn.WithWhoisServer(whois.Addr))
+	ctx := context.Background()
+	streamLookup(ctx, ctx, client, strings.NewReader(input.String()), outputFlags{outPath: outPath}, "ndjson", false, nil, nil)
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 100002 {
+		t.Fatalf("expected 100002 lines, got %d", len(lines))
+	}
+	var repeats int
+	for _, line := range lines {
+		if strings.Contains(line, `"ip":"10.0.0.0"`) {
+			repeats++
+		}
+	}
+	if repeats != 2 {
+		t.Fatalf("expected 10.0.0.0 on 2 lines, got %d", repeats)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/hink/ip2asn/internal/config"
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+// timeoutFlags bound a run as a whole and each of its stages. Unset stage
+// timeouts scale with the number of IPs.
+type timeoutFlags struct {
+	total  time.Duration
+	lookup time.Duration
+	enrich time.Duration
+}
+
+// register adds --timeout and --lookup-timeout, plus --enrich-timeout when
+// the command can enrich.
+func (t *timeoutFlags) register(fs *flag.FlagSet, enrich bool) {
+	fs.DurationVar(&t.total, "timeout", 0, "time limit for the whole run (default none, or $"+config.EnvTimeout+")")
+	fs.DurationVar(&t.lookup, "lookup-timeout", 0, "time limit for Team Cymru lookups (default scales with the number of IPs, or $"+config.EnvLookupTimeout+")")
+	if enrich {
+		fs.DurationVar(&t.enrich, "enrich-timeout", 0, "time limit for proxycheck.io enrichment (default scales with the number of IPs, or $"+config.EnvEnrichTimeout+")")
+	}
+}
+
+// resolve returns settings with the timeout flags given on fs applied on
+// top, exiting on negative values.
+func (t timeoutFlags) resolve(fs *flag.FlagSet, settings config.Settings) config.Settings {
+	set := flagsSet(fs)
+	if set["timeout"] {
+		settings.Timeout = t.total
+	}
+	if set["lookup-timeout"] {
+		settings.LookupTimeout = t.lookup
+	}
+	if set["enrich-timeout"] {
+		settings.EnrichTimeout = t.enrich
+	}
+	if settings.Timeout < 0 || settings.LookupTimeout < 0 || settings.EnrichTimeout < 0 {
+		fatalf("timeouts must not be negative")
+	}
+	return settings
+}
+
+// options returns the client options for the stage timeouts. Unset ones are
+// left to the client, which scales them with the work.
+func (t timeoutFlags) options(fs *flag.FlagSet, settings config.Settings) []ip2asn.Option {
+	settings = t.resolve(fs, settings)
+	var opts []ip2asn.Option
+	if settings.LookupTimeout > 0 {
+		opts = append(opts, ip2asn.WithTimeout(settings.LookupTimeout))
+	}
+	if settings.EnrichTimeout > 0 {
+		opts = append(opts, ip2asn.WithEnrichTimeout(settings.EnrichTimeout))
+	}
+	return opts
+}
+
+// context bounds ctx by the overall timeout, if any.
+func (t timeoutFlags) context(ctx context.Context, fs *flag.FlagSet, settings config.Settings) (context.Context, context.CancelFunc) {
+	settings = t.resolve(fs, settings)
+	if settings.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, settings.Timeout)
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// version is overridden at release build time via -ldflags "-X main.version=...".
+var version = "2.2"
+
+func runVersion(_ []string) {
+	fmt.Printf("ip2asn %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+const (
+	// DefaultTTL is how long cached lookups stay fresh. Origin data changes
+	// slowly, but not so slowly that week-old answers are safe.
+	DefaultTTL = 24 * time.Hour
+
+	fileName = "results.json"
+)
+
+// Store is an on-disk cache of lookup results keyed by IP.
+//
+// The whole cache is a single JSON file that is loaded on Open and rewritten
+// atomically on Save. A Store is safe for concurrent use.
+type Store struct {
+	path string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]entry
+	dirty   bool
+}
+
+type entry struct {
+	Stored  time.Time      `json:"stored"`
+	Results []model.Result `json:"results"`
+}
+
+// Stats summarizes the cache contents.
+type Stats struct {
+	Path    string
+	Entries int
+	Expired int
+	Bytes   int64
+}
+
+// DefaultPath returns the cache file under the user's cache directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "ip2asn", fileName), nil
+}
+
+// Open loads the cache at path. A missing file yields an empty cache; a
+// corrupt one is treated as empty and replaced on the next Save.
+func Open(path string, ttl time.Duration) (*Store, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	store := &Store{
+		path:    path,
+		ttl:     ttl,
+		entries: make(map[string]entry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.entries); err != nil {
+		store.entries = make(map[string]entry)
+		store.dirty = true
+	}
+	return store, nil
+}
+
+// Path returns the cache file location.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get returns the fresh cached results for ip.
+func (s *Store) Get(ip string, now time.Time) ([]model.Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[ip]
+	if !ok || s.expired(e, now) {
+		return nil, false
+	}
+	return append([]model.Result(nil), e.Results...), true
+}
+
+// Put stores results, grouped by IP, replacing older entries. Enrichment is
+// not cached since it is fetched per run.
+func (s *Store) Put(results []model.Result, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fresh := make(map[string][]model.Result)
+	for _, result := range results {
+		result.ProxyCheck = nil
+		ip := result.IPString()
+		fresh[ip] = append(fresh[ip], result)
+	}
+	for ip, ipResults := range fresh {
+		s.entries[ip] = entry{Stored: now, Results: ipResults}
+	}
+	if len(fresh) > 0 {
+		s.dirty = true
+	}
+}
+
+// Prune drops expired entries and reports how many were removed.
+func (s *Store) Prune(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for ip, e := range s.entries {
+		if s.expired(e, now) {
+			delete(s.entries, ip)
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.dirty = true
+	}
+	return removed
+}
+
+// Clear removes every entry and deletes the cache file.
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]entry)
+	s.dirty = false
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove cache: %w", err)
+	}
+	return nil
+}
+
+// Stats reports the number of entries and the on-disk size.
+func (s *Store) Stats(now time.Time) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{Path: s.path, Entries: len(s.entries)}
+	for _, e := range s.entries {
+		if s.expired(e, now) {
+			stats.Expired++
+		}
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		stats.Bytes = info.Size()
+	}
+	return stats
+}
+
+// Save writes the cache if it changed since Open.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.entries)
+	if err != nil {
+		return fmt.Errorf("encode cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), fileName+".*")
+	if err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+func (s *Store) expired(e entry, now time.Time) bool {
+	return now.Sub(e.Stored) > s.ttl
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestStoreRoundTripAndExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "results.json")
+	now := time.Date(2024, 3, 14, 15, 0, 0, 0, time.UTC)
+	risk := 90
+
+	store, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	store.Put([]model.Result{
+		{
+			ASN:        64500,
+			IP:         netip.MustParseAddr("203.0.113.7"),
+			BGPPrefix:  netip.MustParsePrefix("203.0.113.0/24"),
+			Allocated:  model.MustParseDate("2020-01-01"),
+			Method:     model.MethodWhois,
+			ProxyCheck: &model.ProxyCheck{Risk: &risk},
+		},
+	}, now)
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reopened, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	results, ok := reopened.Get("203.0.113.7", now.Add(30*time.Minute))
+	if !ok || len(results) != 1 {
+		t.Fatalf("expected cached result, got %v (%v)", results, ok)
+	}
+	if results[0].ASN != 64500 || results[0].BGPPrefix.String() != "203.0.113.0/24" || results[0].Method != model.MethodWhois {
+		t.Fatalf("unexpected cached result: %+v", results[0])
+	}
+	if results[0].ProxyCheck != nil {
+		t.Fatal("did not expect enrichment to be cached")
+	}
+	if _, ok := reopened.Get("203.0.113.7", now.Add(2*time.Hour)); ok {
+		t.Fatal("expected entry to expire after the TTL")
+	}
+
+	stats := reopened.Stats(now.Add(2 * time.Hour))
+	if stats.Entries != 1 || stats.Expired != 1 || stats.Bytes == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if removed := reopened.Prune(now.Add(2 * time.Hour)); removed != 1 {
+		t.Fatalf("expected 1 pruned entry, got %d", removed)
+	}
+}
+
+func TestOpenTreatsCorruptFileAsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if stats := store.Stats(time.Now()); stats.Entries != 0 {
+		t.Fatalf("expected empty cache, got %+v", stats)
+	}
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected cache file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// File is a parsed ip2asn configuration file.
+//
+// Example:
+//
+//	default_profile = "work"
+//
+//	[profiles.work]
+//	format = "json"
+//	enrich = true
+//	backend = "whois"
+//	timeout = "20s"
+//	resolver = "https://dns.example/dns-query"
+//
+//	[profiles.work.cache]
+//	enabled = true
+//	ttl = "12h"
+//
+//	[profiles.work.providers.proxycheck]
+//	api_key_command = "pass show proxycheck"
+type File struct {
+	DefaultProfile string             `toml:"default_profile"`
+	Profiles       map[string]Profile `toml:"profiles"`
+}
+
+// Profile is a named set of defaults. Unset fields fall through to the
+// built-in defaults.
+type Profile struct {
+	Format        string              `toml:"format"`
+	Enrich        *bool               `toml:"enrich"`
+	Backend       string              `toml:"backend"`
+	Timeout       Duration            `toml:"timeout"`
+	LookupTimeout Duration            `toml:"lookup_timeout"`
+	EnrichTimeout Duration            `toml:"enrich_timeout"`
+	Resolver      string              `toml:"resolver"`
+	DNSTimeout    Duration            `toml:"dns_timeout"`
+	DNSRetries    *int                `toml:"dns_retries"`
+	Proxy         string              `toml:"proxy"`
+	Cache         Cache               `toml:"cache"`
+	Providers     map[string]Provider `toml:"providers"`
+}
+
+// Cache configures the lookup cache.
+type Cache struct {
+	Enabled *bool    `toml:"enabled"`
+	Path    string   `toml:"path"`
+	TTL     Duration `toml:"ttl"`
+}
+
+// Provider holds credentials for an enrichment provider. At most one of the
+// key sources should be set.
+type Provider struct {
+	APIKey        string `toml:"api_key"`
+	APIKeyFile    string `toml:"api_key_file"`
+	APIKeyCommand string `toml:"api_key_command"`
+}
+
+// Duration is a time.Duration written as a Go duration string ("15s").
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// DefaultPath returns ~/.config/ip2asn/config.toml, honoring XDG_CONFIG_HOME.
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ip2asn", "config.toml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate config directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "ip2asn", "config.toml"), nil
+}
+
+// Load parses the config file at path. When optional is set, a missing file
+// yields an empty configuration instead of an error.
+func Load(path string, optional bool) (*File, error) {
+	var file File
+	meta, err := toml.DecodeFile(path, &file)
+	if errors.Is(err, fs.ErrNotExist) && optional {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load config %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("load config %s: unknown keys: %s", path, strings.Join(keys, ", "))
+	}
+	return &file, nil
+}
+
+// Profile returns the named profile, or the default profile when name is
+// empty. With no name and no default profile, the zero Profile is returned.
+func (f *File) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = f.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return profile, nil
+}
+
+// Key returns the provider's API key from the first configured source.
+func (p Provider) Key(ctx context.Context) (string, error) {
+	switch {
+	case p.APIKey != "":
+		return p.APIKey, nil
+	case p.APIKeyFile != "":
+		data, err := os.ReadFile(expandHome(p.APIKeyFile))
+		if err != nil {
+			return "", fmt.Errorf("read API key file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case p.APIKeyCommand != "":
+		cmd := shellCommand(ctx, p.APIKeyCommand)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("API key command failed: %w: %s", err, msg)
+			}
+			return "", fmt.Errorf("API key command failed: %w", err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	default:
+		return "", nil
+	}
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
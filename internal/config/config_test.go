@@ -0,0 +1,142 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+default_profile = "work"
+
+[profiles.work]
+format = "json"
+enrich = true
+backend = "whois"
+timeout = "20s"
+resolver = "tls://dns.example"
+dns_retries = 0
+proxy = "socks5://127.0.0.1:1080"
+
+[profiles.work.cache]
+enabled = true
+ttl = "12h"
+
+[profiles.work.providers.proxycheck]
+api_key_file = "KEYFILE"
+
+[profiles.quiet]
+format = "csv"
+`
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "proxycheck.key")
+	if err := os.WriteFile(keyFile, []byte("file-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	contents = strings.ReplaceAll(contents, "KEYFILE", filepath.ToSlash(keyFile))
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolveLayersProfileThenEnvironment(t *testing.T) {
+	file, err := Load(writeConfig(t, testConfig), false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	profile, err := file.Profile("")
+	if err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+
+	defaults := Settings{Format: "table", Backend: "auto", EnrichTimeout: 8 * time.Second, DNSTimeout: 3 * time.Second, DNSRetries: 2}
+	env := map[string]string{EnvFormat: "csv", EnvResolver: "https://dns.example/dns-query", EnvLookupTimeout: "45s"}
+	settings, err := Resolve(defaults, profile, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	if settings.Format != "csv" {
+		t.Fatalf("expected environment to override profile format, got %q", settings.Format)
+	}
+	if !settings.Enrich || settings.Backend != "whois" || settings.Timeout != 20*time.Second {
+		t.Fatalf("expected profile values, got %+v", settings)
+	}
+	if settings.LookupTimeout != 45*time.Second || settings.EnrichTimeout != 8*time.Second {
+		t.Fatalf("expected environment lookup timeout and default enrich timeout, got %v, %v", settings.LookupTimeout, settings.EnrichTimeout)
+	}
+	if settings.Resolver != "https://dns.example/dns-query" || settings.DNSTimeout != 3*time.Second || settings.DNSRetries != 0 {
+		t.Fatalf("expected environment resolver with profile retries, got %+v", settings)
+	}
+	if settings.Proxy != "socks5://127.0.0.1:1080" {
+		t.Fatalf("expected profile proxy, got %q", settings.Proxy)
+	}
+	if !settings.CacheEnabled || settings.CacheTTL != 12*time.Hour {
+		t.Fatalf("expected profile cache settings, got %+v", settings)
+	}
+	key, err := settings.Proxycheck.Key(context.Background())
+	if err != nil || key != "file-key" {
+		t.Fatalf("expected key from file, got %q (%v)", key, err)
+	}
+
+	env[EnvProxycheckKey] = "env-key"
+	settings, err = Resolve(defaults, profile, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if key, _ := settings.Proxycheck.Key(context.Background()); key != "env-key" {
+		t.Fatalf("expected environment key to override profile provider, got %q", key)
+	}
+}
+
+func TestLoadRejectsUnknownKeysAndProfiles(t *testing.T) {
+	if _, err := Load(writeConfig(t, "[profiles.work]\nformt = \"json\"\n"), false); err == nil || !strings.Contains(err.Error(), "profiles.work.formt") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+
+	file, err := Load(writeConfig(t, testConfig), false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := file.Profile("missing"); err == nil {
+		t.Fatal("expected unknown profile error")
+	}
+	if profile, err := file.Profile("quiet"); err != nil || profile.Format != "csv" {
+		t.Fatalf("expected named profile, got %+v (%v)", profile, err)
+	}
+}
+
+func TestLoadOptionalMissingFile(t *testing.T) {
+	file, err := Load(filepath.Join(t.TempDir(), "missing.toml"), true)
+	if err != nil {
+		t.Fatalf("expected missing optional config to load, got %v", err)
+	}
+	if profile, err := file.Profile(""); err != nil || profile.Format != "" {
+		t.Fatalf("expected empty default profile, got %+v (%v)", profile, err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.toml"), false); err == nil {
+		t.Fatal("expected explicit missing config to fail")
+	}
+}
+
+func TestProviderKeyFromCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell command")
+	}
+	key, err := Provider{APIKeyCommand: "echo command-key"}.Key(context.Background())
+	if err != nil || key != "command-key" {
+		t.Fatalf("expected key from command, got %q (%v)", key, err)
+	}
+	if _, err := (Provider{APIKeyCommand: "exit 3"}).Key(context.Background()); err == nil {
+		t.Fatal("expected failing command to return an error")
+	}
+}
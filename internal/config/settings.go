@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Settings are the effective options after layering defaults, the profile
+// and the environment, in increasing precedence. Callers apply command-line
+// flags on top.
+type Settings struct {
+	Format        string
+	Enrich        bool
+	Backend       string
+	Timeout       time.Duration // Whole run; zero for none
+	LookupTimeout time.Duration // Cymru lookups; zero scales with the input
+	EnrichTimeout time.Duration // proxycheck.io; zero scales with the input
+	Resolver      string
+	DNSTimeout    time.Duration
+	DNSRetries    int
+	Proxy         string
+	CacheEnabled  bool
+	CachePath     string
+	CacheTTL      time.Duration
+	Proxycheck    Provider
+}
+
+// Environment variables read by Resolve.
+const (
+	EnvProxycheckKey = "PROXYCHECK_API_KEY"
+	EnvFormat        = "IP2ASN_FORMAT"
+	EnvBackend       = "IP2ASN_BACKEND"
+	EnvTimeout       = "IP2ASN_TIMEOUT"
+	EnvLookupTimeout = "IP2ASN_LOOKUP_TIMEOUT"
+	EnvEnrichTimeout = "IP2ASN_ENRICH_TIMEOUT"
+	EnvEnrich        = "IP2ASN_ENRICH"
+	EnvCache         = "IP2ASN_CACHE"
+	EnvResolver      = "IP2ASN_RESOLVER"
+	EnvProxy         = "IP2ASN_PROXY"
+)
+
+// Resolve layers profile and then the environment (read via getenv) over
+// defaults.
+func Resolve(defaults Settings, profile Profile, getenv func(string) string) (Settings, error) {
+	s := defaults
+
+	if profile.Format != "" {
+		s.Format = profile.Format
+	}
+	if profile.Enrich != nil {
+		s.Enrich = *profile.Enrich
+	}
+	if profile.Backend != "" {
+		s.Backend = profile.Backend
+	}
+	if profile.Timeout.Duration > 0 {
+		s.Timeout = profile.Timeout.Duration
+	}
+	if profile.LookupTimeout.Duration > 0 {
+		s.LookupTimeout = profile.LookupTimeout.Duration
+	}
+	if profile.EnrichTimeout.Duration > 0 {
+		s.EnrichTimeout = profile.EnrichTimeout.Duration
+	}
+	if profile.Resolver != "" {
+		s.Resolver = profile.Resolver
+	}
+	if profile.DNSTimeout.Duration > 0 {
+		s.DNSTimeout = profile.DNSTimeout.Duration
+	}
+	if profile.DNSRetries != nil {
+		if *profile.DNSRetries < 0 {
+			return Settings{}, fmt.Errorf("dns_retries: must not be negative")
+		}
+		s.DNSRetries = *profile.DNSRetries
+	}
+	if profile.Proxy != "" {
+		s.Proxy = profile.Proxy
+	}
+	if profile.Cache.Enabled != nil {
+		s.CacheEnabled = *profile.Cache.Enabled
+	}
+	if profile.Cache.Path != "" {
+		s.CachePath = expandHome(profile.Cache.Path)
+	}
+	if profile.Cache.TTL.Duration > 0 {
+		s.CacheTTL = profile.Cache.TTL.Duration
+	}
+	if provider, ok := profile.Providers["proxycheck"]; ok {
+		s.Proxycheck = provider
+	}
+
+	if v := getenv(EnvFormat); v != "" {
+		s.Format = v
+	}
+	if v := getenv(EnvBackend); v != "" {
+		s.Backend = v
+	}
+	if v := getenv(EnvResolver); v != "" {
+		s.Resolver = v
+	}
+	if v := getenv(EnvProxy); v != "" {
+		s.Proxy = v
+	}
+	if err := envDuration(getenv, EnvTimeout, &s.Timeout); err != nil {
+		return Settings{}, err
+	}
+	if err := envDuration(getenv, EnvLookupTimeout, &s.LookupTimeout); err != nil {
+		return Settings{}, err
+	}
+	if err := envDuration(getenv, EnvEnrichTimeout, &s.EnrichTimeout); err != nil {
+		return Settings{}, err
+	}
+	if err := envBool(getenv, EnvEnrich, &s.Enrich); err != nil {
+		return Settings{}, err
+	}
+	if err := envBool(getenv, EnvCache, &s.CacheEnabled); err != nil {
+		return Settings{}, err
+	}
+	if v := getenv(EnvProxycheckKey); v != "" {
+		s.Proxycheck = Provider{APIKey: v}
+	}
+	return s, nil
+}
+
+func envDuration(getenv func(string) string, name string, dst *time.Duration) error {
+	v := getenv(name)
+	if v == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil || parsed <= 0 {
+		return fmt.Errorf("%s: invalid duration %q", name, v)
+	}
+	*dst = parsed
+	return nil
+}
+
+func envBool(getenv func(string) string, name string, dst *bool) error {
+	v := getenv(name)
+	if v == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: invalid boolean %q", name, v)
+	}
+	*dst = parsed
+	return nil
+}
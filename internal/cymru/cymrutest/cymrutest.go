@@ -0,0 +1,42 @@
+// Package cymrutest provides local stand-ins for Team Cymru's bulk WHOIS and
+// TXT DNS interfaces, so lookups can be tested without the internet.
+//
+// Point cymru lookups at them with cymru.WithWhoisAddr(whois.Addr) and
+// cymru.WithResolver(dns.Resolver()).
+package cymrutest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Row is one line of a verbose bulk WHOIS response.
+type Row struct {
+	ASN       string
+	IP        string
+	Prefix    string
+	CC        string
+	Registry  string
+	Allocated string
+	ASName    string
+}
+
+// String formats r the way whois.cymru.com does.
+func (r Row) String() string {
+	return fmt.Sprintf("%-7s | %-16s | %-19s | %-2s | %-8s | %-10s | %s", r.ASN, r.IP, r.Prefix, r.CC, r.Registry, r.Allocated, r.ASName)
+}
+
+// BulkResponse returns a complete verbose bulk response: the banner, the
+// column header and rows.
+func BulkResponse(rows ...Row) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Bulk mode; whois.cymru.com [%s]\n", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02 15:04:05 -0700"))
+	sb.WriteString(Row{ASN: "AS", IP: "IP", Prefix: "BGP Prefix", CC: "CC", Registry: "Registry", Allocated: "Allocated", ASName: "AS Name"}.String())
+	sb.WriteByte('\n')
+	for _, row := range rows {
+		sb.WriteString(row.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
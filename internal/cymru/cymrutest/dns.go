@@ -0,0 +1,193 @@
+package cymrutest
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// TXTReply scripts the answer for one name.
+type TXTReply struct {
+	// TXT holds one record per element; each is sent as a single string
+	// (split at 255 bytes), so malformed text is passed through as is.
+	TXT []string
+	// RCode is the response code; names without a reply get NXDOMAIN.
+	RCode dnsmessage.RCode
+	// Delay is waited before answering.
+	Delay time.Duration
+	// Drop never answers, so the client times out.
+	Drop bool
+}
+
+// DNSServer is a fake TXT DNS server over UDP and TCP.
+type DNSServer struct {
+	// Addr is the host:port the server listens on, for both UDP and TCP.
+	Addr string
+
+	pc net.PacketConn
+	ln net.Listener
+
+	mu      sync.Mutex
+	replies map[string]TXTReply
+	queries []string
+}
+
+// NewDNSServer starts a server answering from replies, keyed by query name
+// without the trailing dot (case-insensitive). It is shut down when the test
+// ends.
+func NewDNSServer(t testing.TB, replies map[string]TXTReply) *DNSServer {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cymrutest: listen: %v", err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatalf("cymrutest: listen: %v", err)
+	}
+	s := &DNSServer{Addr: pc.LocalAddr().String(), pc: pc, ln: ln, replies: make(map[string]TXTReply)}
+	for name, reply := range replies {
+		s.Set(name, reply)
+	}
+	go s.serveUDP()
+	go s.serveTCP()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Set scripts the reply for name.
+func (s *DNSServer) Set(name string, reply TXTReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[canonicalName(name)] = reply
+}
+
+// Queries returns the names queried so far, without the trailing dot.
+func (s *DNSServer) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// Resolver returns a resolver that sends every query to the server.
+func (s *DNSServer) Resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, s.Addr)
+		},
+	}
+}
+
+// Close stops the server.
+func (s *DNSServer) Close() {
+	s.pc.Close()
+	s.ln.Close()
+}
+
+func (s *DNSServer) serveUDP() {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			if resp := s.answer(query); resp != nil {
+				_, _ = s.pc.WriteTo(resp, addr)
+			}
+		}()
+	}
+}
+
+func (s *DNSServer) serveTCP() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				resp := s.answer(query)
+				if resp == nil {
+					return
+				}
+				if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// answer returns the packed response, or nil to stay silent.
+func (s *DNSServer) answer(query []byte) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	question := msg.Questions[0]
+	name := canonicalName(question.Name.String())
+
+	s.mu.Lock()
+	s.queries = append(s.queries, name)
+	reply, ok := s.replies[name]
+	s.mu.Unlock()
+
+	if reply.Drop {
+		return nil
+	}
+	time.Sleep(reply.Delay)
+
+	msg.Header.Response = true
+	msg.Header.Authoritative = true
+	msg.Header.RCode = reply.RCode
+	if !ok {
+		msg.Header.RCode = dnsmessage.RCodeNameError
+	}
+	if msg.Header.RCode == dnsmessage.RCodeSuccess && question.Type == dnsmessage.TypeTXT {
+		for _, txt := range reply.TXT {
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.TXTResource{TXT: splitTXT(txt)},
+			})
+		}
+	}
+	resp, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func splitTXT(s string) []string {
+	var parts []string
+	for len(s) > 255 {
+		parts = append(parts, s[:255])
+		s = s[255:]
+	}
+	return append(parts, s)
+}
@@ -0,0 +1,123 @@
+package cymrutest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// WhoisReply scripts the answer to one bulk session.
+type WhoisReply struct {
+	// Body is written verbatim, so it may hold malformed or error lines.
+	Body string
+	// Delay is waited before anything is written.
+	Delay time.Duration
+	// Hang keeps the connection open after Body instead of closing it, as a
+	// server that stalls mid-response.
+	Hang bool
+	// Reset aborts the connection (TCP RST) instead of replying.
+	Reset bool
+}
+
+// WhoisHandler returns the reply to a session that queried ips.
+type WhoisHandler func(ips []string) WhoisReply
+
+// WhoisServer is a fake bulk WHOIS server.
+type WhoisServer struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	ln      net.Listener
+	handler WhoisHandler
+
+	mu       sync.Mutex
+	sessions [][]string
+	hung     []net.Conn
+}
+
+// NewWhoisServer starts a server answering every session with handler. It
+// is shut down when the test ends.
+func NewWhoisServer(t testing.TB, handler WhoisHandler) *WhoisServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cymrutest: listen: %v", err)
+	}
+	s := &WhoisServer{Addr: ln.Addr().String(), ln: ln, handler: handler}
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// StaticWhois answers every session with reply.
+func StaticWhois(reply WhoisReply) WhoisHandler {
+	return func([]string) WhoisReply { return reply }
+}
+
+// Sessions returns the IPs queried in each session so far.
+func (s *WhoisServer) Sessions() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.sessions...)
+}
+
+// Close stops the server and drops hung connections.
+func (s *WhoisServer) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.hung {
+		conn.Close()
+	}
+	s.hung = nil
+}
+
+func (s *WhoisServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *WhoisServer) session(conn net.Conn) {
+	var ips []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "end" {
+			break
+		}
+		if line == "" || line == "begin" || line == "verbose" {
+			continue
+		}
+		ips = append(ips, line)
+	}
+	s.mu.Lock()
+	s.sessions = append(s.sessions, ips)
+	s.mu.Unlock()
+
+	reply := s.handler(ips)
+	time.Sleep(reply.Delay)
+	if reply.Reset {
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			_ = tcp.SetLinger(0)
+		}
+		conn.Close()
+		return
+	}
+	_, _ = io.WriteString(conn, reply.Body)
+	if reply.Hang {
+		s.mu.Lock()
+		s.hung = append(s.hung, conn)
+		s.mu.Unlock()
+		return
+	}
+	conn.Close()
+}
@@ -3,7 +3,6 @@ package cymru
 import (
 	"context"
 	"fmt"
-	"net"
 	"net/netip"
 	"regexp"
 	"sort"
@@ -11,13 +10,14 @@ import (
 	"sync"
 	"time"
 
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
 )
 
 // LookupDNS performs Team Cymru DNS interface lookup for a single IP.
 // For IPv4 uses origin.asn.cymru.com with reversed octets; for IPv6 uses origin6.asn.cymru.com with nibble-reversed form.
-// Returns one or more results (in case of multiple origin ASNs).
-func LookupDNS(ctx context.Context, ip string) ([]model.Result, error) {
+// Returns a single result per IP; multi-origin (MOAS) prefixes are reported via Origins.
+func LookupDNS(ctx context.Context, ip string, opts ...Option) ([]model.Result, error) {
+	o := newOptions(opts)
 	addr, err := netip.ParseAddr(ip)
 	if err != nil {
 		return nil, fmt.Errorf("invalid IP: %w", err)
@@ -32,7 +32,7 @@ func LookupDNS(ctx context.Context, ip string) ([]model.Result, error) {
 
 	// TXT response format (single record) generally:
 	// "<ASN(s)> | <BGP Prefix> | <CC> | <Registry> | <Allocated>"
-	txts, err := lookupTXT(ctx, qname)
+	txts, err := o.resolver.LookupTXT(ctx, qname)
 	if err != nil {
 		return nil, err
 	}
@@ -47,15 +47,15 @@ func LookupDNS(ctx context.Context, ip string) ([]model.Result, error) {
 	}
 
 	asField := fields[0]
-	bgpPrefix := fields[1]
+	bgpPrefix := parsePrefix(fields[1])
 	cc := fields[2]
 	registry := fields[3]
-	allocated := fields[4]
+	allocated := parseDate(fields[4])
 
-	// asField might contain multiple ASNs separated by spaces
+	// asField might contain multiple ASNs separated by spaces (MOAS prefix)
 	asns := strings.Fields(asField)
-	// Sort for deterministic output
-	sort.SliceStable(asns, func(i, j int) bool { return asns[i] < asns[j] })
+	// Sort numerically for deterministic output; the lowest ASN becomes the primary origin
+	sort.SliceStable(asns, func(i, j int) bool { return parseASN(asns[i]) < parseASN(asns[j]) })
 
 	// Parallel lookup for AS Names
 	var wg sync.WaitGroup
@@ -67,7 +67,7 @@ func LookupDNS(ctx context.Context, ip string) ([]model.Result, error) {
 		go func(asnStr string) {
 			defer wg.Done()
 			// Best effort; if it fails, we just get empty string which is fine
-			name, _ := asNameLookup(ctx, asnStr)
+			name, _ := asNameLookup(ctx, asnStr, opts...)
 			if name != "" {
 				mu.Lock()
 				asNameMap[asnStr] = name
@@ -77,23 +77,22 @@ func LookupDNS(ctx context.Context, ip string) ([]model.Result, error) {
 	}
 	wg.Wait()
 
-	now := time.Now().UTC()
-	results := make([]model.Result, 0, len(asns))
+	origins := make([]model.Origin, 0, len(asns))
 	for _, s := range asns {
-		results = append(results, model.Result{
-			ASN:       atoiSafe(s),
-			IP:        addr.String(),
-			IPAddr:    addr,
-			BGPPrefix: bgpPrefix,
-			CC:        cc,
-			Registry:  registry,
-			Allocated: allocated,
-			ASName:    asNameMap[s],
-			Method:    "dns",
-			Retrieved: now,
-		})
-	}
-	return results, nil
+		origins = append(origins, model.Origin{ASN: parseASN(s), ASName: asNameMap[s]})
+	}
+
+	result := model.Result{
+		IP:        addr,
+		BGPPrefix: bgpPrefix,
+		CC:        cc,
+		Registry:  registry,
+		Allocated: allocated,
+		Method:    model.MethodDNS,
+		Retrieved: time.Now().UTC(),
+	}
+	setOrigins(&result, origins)
+	return []model.Result{result}, nil
 }
 
 func reverseIPv4(a netip.Addr) string {
@@ -121,28 +120,43 @@ func nibbleReverseIPv6(a netip.Addr) string {
 	return sb.String()
 }
 
-func lookupTXT(ctx context.Context, name string) ([]string, error) {
-	return net.DefaultResolver.LookupTXT(ctx, name)
-}
-
-// AS name lookup: "AS<asn>.asn.cymru.com" returns TXT like:
+// LookupASN performs a Team Cymru DNS lookup of an AS's registration data.
+// "AS<asn>.asn.cymru.com" returns TXT like:
 // "<ASN> | <CC> | <Registry> | <Allocated> | <AS Name>"
-func asNameLookup(ctx context.Context, asn string) (string, error) {
-	name := fmt.Sprintf("AS%s.asn.cymru.com", strings.TrimSpace(asn))
-	txts, err := lookupTXT(ctx, name)
+func LookupASN(ctx context.Context, asn model.ASN, opts ...Option) (model.ASInfo, error) {
+	if !asn.Known() {
+		return model.ASInfo{}, fmt.Errorf("invalid ASN: %s", asn)
+	}
+	name := fmt.Sprintf("AS%d.asn.cymru.com", uint32(asn))
+	txts, err := newOptions(opts).resolver.LookupTXT(ctx, name)
 	if err != nil {
-		return "", err
+		return model.ASInfo{}, err
 	}
 	if len(txts) == 0 {
-		return "", fmt.Errorf("no TXT for %s", name)
+		return model.ASInfo{}, fmt.Errorf("no TXT for %s", name)
 	}
 	rec := strings.Join(txts, " ")
 	f := splitFields(rec)
 	if len(f) < 5 {
-		return "", fmt.Errorf("unexpected AS TXT: %q", rec)
+		return model.ASInfo{}, fmt.Errorf("unexpected AS TXT: %q", rec)
+	}
+	return model.ASInfo{
+		ASN:       asn,
+		CC:        f[1],
+		Registry:  f[2],
+		Allocated: parseDate(f[3]),
+		// Last field is AS Name (can include spaces and commas)
+		ASName:    f[len(f)-1],
+		Retrieved: time.Now().UTC(),
+	}, nil
+}
+
+func asNameLookup(ctx context.Context, asn string, opts ...Option) (string, error) {
+	info, err := LookupASN(ctx, parseASN(asn), opts...)
+	if err != nil {
+		return "", err
 	}
-	// Last field is AS Name (can include spaces and commas)
-	return f[len(f)-1], nil
+	return info.ASName, nil
 }
 
 var fieldSplitRe = regexp.MustCompile(`\s*\|\s*`)
@@ -155,13 +169,30 @@ func splitFields(line string) []string {
 	return parts
 }
 
-func atoiSafe(s string) int {
-	var n int
-	for _, ch := range s {
-		if ch < '0' || ch > '9' {
-			return -1
-		}
-		n = n*10 + int(ch-'0')
+// parseASN maps Cymru's ASN text to model.ASN; "NA" and anything malformed
+// become model.ASNUnknown.
+func parseASN(s string) model.ASN {
+	asn, err := model.ParseASN(s)
+	if err != nil {
+		return model.ASNUnknown
+	}
+	return asn
+}
+
+// parsePrefix returns the zero prefix for "NA" or malformed text.
+func parsePrefix(s string) netip.Prefix {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}
 	}
-	return n
-}
\ No newline at end of file
+	return prefix
+}
+
+// parseDate returns the zero date for empty or malformed text.
+func parseDate(s string) model.Date {
+	date, err := model.ParseDate(s)
+	if err != nil {
+		return model.Date{}
+	}
+	return date
+}
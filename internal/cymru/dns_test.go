@@ -0,0 +1,120 @@
+package cymru
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/hink/ip2asn/internal/cymru/cymrutest"
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestLookupDNS(t *testing.T) {
+	dns := cymrutest.NewDNSServer(t, map[string]cymrutest.TXTReply{
+		"7.113.0.203.origin.asn.cymru.com": {TXT: []string{"64510 64500 | 203.0.113.0/24 | US | arin | 2010-05-01"}},
+		"AS64500.asn.cymru.com":            {TXT: []string{"64500 | US | arin | 2010-05-01 | LOWER, US"}},
+		"AS64510.asn.cymru.com":            {TXT: []string{"64510 | US | arin | 2011-01-01 | HIGHER, US"}},
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com": {
+			TXT: []string{"64502 | 2001:db8::/32 | NL | ripencc | "},
+		},
+		// AS name lookup fails; the origin is still reported
+		"AS64502.asn.cymru.com": {RCode: dnsmessage.RCodeServerFailure},
+	})
+	opt := WithResolver(dns.Resolver())
+
+	results, err := LookupDNS(context.Background(), "203.0.113.7", opt)
+	if err != nil {
+		t.Fatalf("LookupDNS: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected one result, got %+v", results)
+	}
+	got := results[0]
+	if got.ASN != 64500 || got.ASName != "LOWER, US" || !got.MOAS || got.Method != model.MethodDNS {
+		t.Fatalf("expected MOAS result with lowest ASN primary, got %+v", got)
+	}
+	if len(got.Origins) != 2 || got.Origins[1].ASN != 64510 || got.Origins[1].ASName != "HIGHER, US" {
+		t.Fatalf("unexpected origins: %+v", got.Origins)
+	}
+	if got.BGPPrefix != netip.MustParsePrefix("203.0.113.0/24") || got.CC != "US" || got.Registry != "arin" || got.Allocated != model.MustParseDate("2010-05-01") {
+		t.Fatalf("unexpected fields: %+v", got)
+	}
+
+	results, err = LookupDNS(context.Background(), "2001:db8::1", opt)
+	if err != nil {
+		t.Fatalf("LookupDNS IPv6: %v", err)
+	}
+	if got := results[0]; got.ASN != 64502 || got.ASName != "" || got.MOAS || !got.Allocated.IsZero() || got.CC != "NL" {
+		t.Fatalf("unexpected IPv6 result: %+v", got)
+	}
+}
+
+func TestLookupDNSErrors(t *testing.T) {
+	dns := cymrutest.NewDNSServer(t, map[string]cymrutest.TXTReply{
+		"1.2.0.192.origin.asn.cymru.com": {TXT: []string{"64500 | 192.0.2.0/24"}},
+		"2.2.0.192.origin.asn.cymru.com": {TXT: []string{"64500 | 192.0.2.0/24 | US | arin | 2010-05-01"}, Drop: true},
+		"3.2.0.192.origin.asn.cymru.com": {RCode: dnsmessage.RCodeServerFailure},
+	})
+	opt := WithResolver(dns.Resolver())
+
+	tests := []struct {
+		ip   string
+		want func(error) bool
+	}{
+		{"192.0.2.1", func(err error) bool { return strings.Contains(err.Error(), "unexpected DNS TXT format") }},
+		{"192.0.2.2", func(err error) bool { return errors.Is(err, context.DeadlineExceeded) || isTimeout(err) }},
+		{"192.0.2.3", func(err error) bool { return !isNotFound(err) }},
+		{"192.0.2.4", isNotFound},
+		{"not-an-ip", func(err error) bool { return strings.Contains(err.Error(), "invalid IP") }},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		_, err := LookupDNS(ctx, tt.ip, opt)
+		cancel()
+		if err == nil || !tt.want(err) {
+			t.Fatalf("LookupDNS(%s): unexpected error %v", tt.ip, err)
+		}
+	}
+}
+
+func TestLookupASN(t *testing.T) {
+	dns := cymrutest.NewDNSServer(t, map[string]cymrutest.TXTReply{
+		// Quoted text; AS names contain spaces, dashes and commas
+		"AS64500.asn.cymru.com": {TXT: []string{`"64500 | US | arin | 2010-05-01 | EXAMPLE-NET - Example, Inc., US"`}},
+		"AS64501.asn.cymru.com": {TXT: []string{"64501 | US | arin"}},
+	})
+	opt := WithResolver(dns.Resolver())
+
+	info, err := LookupASN(context.Background(), 64500, opt)
+	if err != nil {
+		t.Fatalf("LookupASN: %v", err)
+	}
+	if info.ASN != 64500 || info.CC != "US" || info.Registry != "arin" || info.ASName != "EXAMPLE-NET - Example, Inc., US" {
+		t.Fatalf("unexpected AS info: %+v", info)
+	}
+	if _, err := LookupASN(context.Background(), 64501, opt); err == nil || !strings.Contains(err.Error(), "unexpected AS TXT") {
+		t.Fatalf("expected malformed TXT error, got %v", err)
+	}
+	if _, err := LookupASN(context.Background(), model.ASNUnknown, opt); err == nil {
+		t.Fatal("expected error for unknown ASN")
+	}
+	if got := dns.Queries(); len(got) != 2 {
+		t.Fatalf("expected no query for the unknown ASN, got %v", got)
+	}
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func isTimeout(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsTimeout
+}
@@ -0,0 +1,66 @@
+package cymru
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// setOrigins assigns the primary ASN and, for multi-origin prefixes, the full
+// origin list. origins must already be in primary-first order.
+func setOrigins(result *model.Result, origins []model.Origin) {
+	if len(origins) == 0 {
+		return
+	}
+	result.ASN = origins[0].ASN
+	result.ASName = origins[0].ASName
+	result.Origins = nil
+	result.MOAS = len(origins) > 1
+	if result.MOAS {
+		result.Origins = origins
+	}
+}
+
+// mergeOrigins folds multiple rows for the same IP (one per origin AS, as the
+// WHOIS interface reports MOAS prefixes) into a single result, matching the
+// shape LookupDNS returns. First-seen IP order is preserved.
+func mergeOrigins(results []model.Result) []model.Result {
+	merged := make([]model.Result, 0, len(results))
+	index := make(map[netip.Addr]int, len(results))
+	origins := make(map[netip.Addr][]model.Origin, len(results))
+
+	for _, result := range results {
+		idx, exists := index[result.IP]
+		if !exists {
+			index[result.IP] = len(merged)
+			merged = append(merged, result)
+			origins[result.IP] = []model.Origin{{ASN: result.ASN, ASName: result.ASName}}
+			continue
+		}
+		if hasOrigin(origins[result.IP], result.ASN) {
+			continue
+		}
+		origins[result.IP] = append(origins[result.IP], model.Origin{ASN: result.ASN, ASName: result.ASName})
+		if result.ASN < merged[idx].ASN {
+			// Keep the row fields of the lowest origin, like LookupDNS does
+			merged[idx] = result
+		}
+	}
+
+	for idx := range merged {
+		ipOrigins := origins[merged[idx].IP]
+		sort.SliceStable(ipOrigins, func(i, j int) bool { return ipOrigins[i].ASN < ipOrigins[j].ASN })
+		setOrigins(&merged[idx], ipOrigins)
+	}
+	return merged
+}
+
+func hasOrigin(origins []model.Origin, asn model.ASN) bool {
+	for _, origin := range origins {
+		if origin.ASN == asn {
+			return true
+		}
+	}
+	return false
+}
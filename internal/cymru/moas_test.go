@@ -0,0 +1,37 @@
+package cymru
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestMergeOriginsFoldsRowsPerIP(t *testing.T) {
+	merged := mergeOrigins([]model.Result{
+		{ASN: 64510, IP: netip.MustParseAddr("203.0.113.7"), BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"), ASName: "HIGHER"},
+		{ASN: 64501, IP: netip.MustParseAddr("198.51.100.1"), BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"), ASName: "SINGLE"},
+		{ASN: 64500, IP: netip.MustParseAddr("203.0.113.7"), BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"), ASName: "LOWER"},
+		{ASN: 64500, IP: netip.MustParseAddr("203.0.113.7"), BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"), ASName: "LOWER"},
+	})
+
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 merged results, got %d: %+v", len(merged), merged)
+	}
+
+	moas := merged[0]
+	if moas.IP != netip.MustParseAddr("203.0.113.7") {
+		t.Fatalf("expected first-seen IP order, got %s first", moas.IP)
+	}
+	if !moas.MOAS || moas.ASN != 64500 || moas.ASName != "LOWER" {
+		t.Fatalf("expected MOAS result with lowest origin as primary, got %+v", moas)
+	}
+	if len(moas.Origins) != 2 || moas.Origins[0].ASN != 64500 || moas.Origins[1].ASN != 64510 || moas.Origins[1].ASName != "HIGHER" {
+		t.Fatalf("unexpected origins: %+v", moas.Origins)
+	}
+
+	single := merged[1]
+	if single.MOAS || single.Origins != nil || single.ASN != 64501 {
+		t.Fatalf("expected untouched single-origin result, got %+v", single)
+	}
+}
@@ -0,0 +1,77 @@
+package cymru
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Option configures a lookup.
+type Option func(*options)
+
+type options struct {
+	resolver  Resolver
+	dialer    Dialer
+	whoisAddr string
+	progress  func(done int)
+	// idleTimeout ends a WHOIS session whose server sends nothing for this
+	// long, even when ctx has no deadline.
+	idleTimeout time.Duration
+}
+
+// Dialer opens the WHOIS TCP connection. *net.Dialer satisfies it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// WithDialer opens WHOIS connections with dialer, such as one that goes
+// through a proxy.
+func WithDialer(dialer Dialer) Option {
+	return func(o *options) {
+		if dialer != nil {
+			o.dialer = dialer
+		}
+	}
+}
+
+// WithResolver sends DNS interface queries to resolver instead of the system
+// resolver.
+func WithResolver(resolver Resolver) Option {
+	return func(o *options) {
+		if resolver != nil {
+			o.resolver = resolver
+		}
+	}
+}
+
+// WithWhoisAddr sends bulk WHOIS queries to addr (host:port) instead of
+// whois.cymru.com:43, such as a local mirror.
+func WithWhoisAddr(addr string) Option {
+	return func(o *options) {
+		if addr != "" {
+			o.whoisAddr = addr
+		}
+	}
+}
+
+// WithProgress calls fn with the number of IPs answered so far as a bulk
+// WHOIS response arrives.
+func WithProgress(fn func(done int)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		resolver:    net.DefaultResolver,
+		dialer:      &net.Dialer{},
+		whoisAddr:   net.JoinHostPort(whoisHost, strconv.Itoa(whoisPort)),
+		idleTimeout: whoisIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
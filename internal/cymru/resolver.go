@@ -0,0 +1,238 @@
+package cymru
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Resolver answers the TXT queries of the DNS interface. *net.Resolver
+// satisfies it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// ResolverConfig tunes a resolver built by NewResolver.
+type ResolverConfig struct {
+	// Timeout bounds each attempt; zero leaves only the caller's context.
+	Timeout time.Duration
+	// Retries is the number of extra attempts after a failed query. Negative
+	// answers (NXDOMAIN) are never retried.
+	Retries int
+	// HTTPClient sends DNS-over-HTTPS queries; nil uses a default client.
+	HTTPClient *http.Client
+	// Dialer connects to udp://, tcp:// and tls:// servers; nil dials
+	// directly.
+	Dialer Dialer
+	// TCPOnly sends udp:// queries over TCP, as needed when Dialer is a proxy.
+	TCPOnly bool
+}
+
+// NewResolver returns a resolver for spec:
+//
+//	""                           the system resolver
+//	1.2.3.4, 1.2.3.4:53          plain DNS (UDP, TCP on truncation); also udp://
+//	tcp://1.2.3.4:53             DNS over TCP only
+//	tls://dns.example:853        DNS-over-TLS
+//	https://dns.example/dns-query  DNS-over-HTTPS (RFC 8484)
+func NewResolver(spec string, cfg ResolverConfig) (Resolver, error) {
+	var resolver Resolver
+	scheme, rest, hasScheme := strings.Cut(spec, "://")
+	if !hasScheme {
+		scheme, rest = "udp", spec
+	}
+	switch {
+	case spec == "":
+		resolver = net.DefaultResolver
+	case scheme == "https":
+		if _, err := url.Parse(spec); err != nil {
+			return nil, fmt.Errorf("invalid resolver %q: %w", spec, err)
+		}
+		httpClient := cfg.HTTPClient
+		if httpClient == nil {
+			httpClient = &http.Client{}
+		}
+		resolver = &dohResolver{url: spec, client: httpClient}
+	case scheme == "udp" || scheme == "tcp" || scheme == "tls":
+		addr, err := resolverAddr(rest, scheme)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolver %q: %w", spec, err)
+		}
+		if scheme == "udp" && cfg.TCPOnly {
+			scheme = "tcp"
+		}
+		dialer := cfg.Dialer
+		if dialer == nil {
+			dialer = &net.Dialer{}
+		}
+		resolver = &net.Resolver{PreferGo: true, Dial: resolverDial(dialer, scheme, addr)}
+	default:
+		return nil, fmt.Errorf("invalid resolver %q: unsupported scheme %q (want udp, tcp, tls or https)", spec, scheme)
+	}
+
+	if cfg.Timeout <= 0 && cfg.Retries <= 0 {
+		return resolver, nil
+	}
+	return &retryResolver{next: resolver, timeout: cfg.Timeout, retries: cfg.Retries}, nil
+}
+
+// resolverAddr adds the scheme's default port to hostport when missing.
+func resolverAddr(hostport, scheme string) (string, error) {
+	hostport = strings.TrimSuffix(hostport, "/")
+	if hostport == "" {
+		return "", errors.New("missing host")
+	}
+	if _, _, err := net.SplitHostPort(hostport); err == nil {
+		return hostport, nil
+	}
+	port := "53"
+	if scheme == "tls" {
+		port = "853"
+	}
+	return net.JoinHostPort(strings.Trim(hostport, "[]"), port), nil
+}
+
+// resolverDial returns a net.Resolver dial function that sends every query
+// to addr. The Go resolver frames queries for TCP whenever the connection is
+// not a net.PacketConn, which covers TCP-only and TLS.
+func resolverDial(d Dialer, scheme, addr string) func(ctx context.Context, network, _ string) (net.Conn, error) {
+	switch scheme {
+	case "tcp":
+		return func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+	case "tls":
+		host, _, _ := net.SplitHostPort(addr)
+		return func(ctx context.Context, _, _ string) (net.Conn, error) {
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	default:
+		return func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+}
+
+// retryResolver bounds each attempt and retries failures other than
+// negative answers.
+type retryResolver struct {
+	next    Resolver
+	timeout time.Duration
+	retries int
+}
+
+func (r *retryResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	var err error
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		var txts []string
+		txts, err = r.attempt(ctx, name)
+		if err == nil {
+			return txts, nil
+		}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (r *retryResolver) attempt(ctx context.Context, name string) ([]string, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	return r.next.LookupTXT(ctx, name)
+}
+
+// dohResolver sends TXT queries as DNS-over-HTTPS POST requests.
+type dohResolver struct {
+	url    string
+	client *http.Client
+}
+
+func (r *dohResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	qname, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name}
+	}
+	// RFC 8484 recommends ID 0 so responses are cacheable
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET}},
+	}
+	body, err := query.Pack()
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: r.url, IsTemporary: true}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: r.url, IsTemporary: true}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &net.DNSError{Err: fmt.Sprintf("DoH server returned %s", resp.Status), Name: name, Server: r.url, IsTemporary: true}
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(data); err != nil {
+		return nil, &net.DNSError{Err: "cannot unmarshal DNS message", Name: name, Server: r.url}
+	}
+	switch msg.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.url, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, Server: r.url, IsTemporary: true}
+	}
+
+	var txts []string
+	for _, answer := range msg.Answers {
+		if txt, ok := answer.Body.(*dnsmessage.TXTResource); ok {
+			// Like net.Resolver, the strings of one record are joined
+			txts = append(txts, strings.Join(txt.TXT, ""))
+		}
+	}
+	if len(txts) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.url, IsNotFound: true}
+	}
+	return txts, nil
+}
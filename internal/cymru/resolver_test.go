@@ -0,0 +1,161 @@
+package cymru
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/hink/ip2asn/internal/cymru/cymrutest"
+)
+
+// txtAnswer packs the response to a TXT query from records; names without a
+// record get NXDOMAIN.
+func txtAnswer(t *testing.T, query []byte, records map[string][]string) []byte {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		t.Errorf("unpack query: %v", err)
+		return nil
+	}
+	msg.Header.Response = true
+	question := msg.Questions[0]
+	txt, ok := records[strings.ToLower(question.Name.String())]
+	if !ok {
+		msg.Header.RCode = dnsmessage.RCodeNameError
+	} else {
+		msg.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.TXTResource{TXT: txt},
+		}}
+	}
+	resp, err := msg.Pack()
+	if err != nil {
+		t.Errorf("pack response: %v", err)
+	}
+	return resp
+}
+
+var testRecords = map[string][]string{
+	"as64500.asn.cymru.com.": {"64500 | US | arin | 2010-05-01 | ", "EXAMPLE, US"},
+}
+
+func TestDoHResolver(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(txtAnswer(t, query, testRecords))
+	}))
+	defer srv.Close()
+
+	resolver, err := NewResolver(srv.URL+"/dns-query", ResolverConfig{HTTPClient: srv.Client()})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	info, err := LookupASN(context.Background(), 64500, WithResolver(resolver))
+	if err != nil {
+		t.Fatalf("LookupASN: %v", err)
+	}
+	if info.ASName != "EXAMPLE, US" || info.Registry != "arin" || info.Allocated.String() != "2010-05-01" {
+		t.Fatalf("unexpected AS info: %+v", info)
+	}
+
+	_, err = LookupASN(context.Background(), 64999, WithResolver(resolver))
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("expected not-found DNS error, got %v", err)
+	}
+}
+
+func TestTCPResolver(t *testing.T) {
+	dns := cymrutest.NewDNSServer(t, map[string]cymrutest.TXTReply{
+		"AS64500.asn.cymru.com": {TXT: []string{"64500 | US | arin | 2010-05-01 | EXAMPLE, US"}},
+	})
+
+	resolver, err := NewResolver("tcp://"+dns.Addr, ResolverConfig{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	txts, err := resolver.LookupTXT(context.Background(), "AS64500.asn.cymru.com")
+	if err != nil {
+		t.Fatalf("LookupTXT: %v", err)
+	}
+	if len(txts) != 1 || txts[0] != "64500 | US | arin | 2010-05-01 | EXAMPLE, US" {
+		t.Fatalf("unexpected TXT: %q", txts)
+	}
+}
+
+type flakyResolver struct {
+	calls    atomic.Int32
+	failures int32
+	err      error
+}
+
+func (f *flakyResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if f.calls.Add(1) <= f.failures {
+		return nil, f.err
+	}
+	return []string{"ok"}, nil
+}
+
+func TestRetryResolver(t *testing.T) {
+	timeout := &net.DNSError{Err: "i/o timeout", IsTimeout: true}
+	flaky := &flakyResolver{failures: 2, err: timeout}
+	resolver := &retryResolver{next: flaky, retries: 2}
+	if txts, err := resolver.LookupTXT(context.Background(), "x"); err != nil || txts[0] != "ok" {
+		t.Fatalf("expected success on third attempt, got %q (%v)", txts, err)
+	}
+
+	flaky = &flakyResolver{failures: 3, err: timeout}
+	resolver = &retryResolver{next: flaky, retries: 2}
+	if _, err := resolver.LookupTXT(context.Background(), "x"); !errors.Is(err, timeout) {
+		t.Fatalf("expected last error after retries, got %v", err)
+	}
+
+	notFound := &net.DNSError{Err: "no such host", IsNotFound: true}
+	flaky = &flakyResolver{failures: 3, err: notFound}
+	resolver = &retryResolver{next: flaky, retries: 2}
+	if _, err := resolver.LookupTXT(context.Background(), "x"); !errors.Is(err, notFound) || flaky.calls.Load() != 1 {
+		t.Fatalf("expected NXDOMAIN without retries, got %v after %d calls", err, flaky.calls.Load())
+	}
+}
+
+func TestNewResolverSpecs(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: ""},
+		{spec: "1.1.1.1"},
+		{spec: "[2606:4700:4700::1111]:53"},
+		{spec: "2606:4700:4700::1111"},
+		{spec: "tls://one.one.one.one"},
+		{spec: "https://cloudflare-dns.com/dns-query"},
+		{spec: "quic://dns.example", wantErr: true},
+		{spec: "tcp://", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := NewResolver(tt.spec, ResolverConfig{})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("NewResolver(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+	if addr, _ := resolverAddr("one.one.one.one", "tls"); addr != "one.one.one.one:853" {
+		t.Fatalf("expected default DoT port, got %q", addr)
+	}
+	if addr, _ := resolverAddr("2606:4700:4700::1111", "udp"); addr != "[2606:4700:4700::1111]:53" {
+		t.Fatalf("expected bracketed IPv6 with default port, got %q", addr)
+	}
+}
@@ -3,55 +3,141 @@ package cymru
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
 )
 
 const (
 	whoisHost = "whois.cymru.com"
 	whoisPort = 43
+
+	whoisDialTimeout = 6 * time.Second
+	whoisIdleTimeout = 10 * time.Second
 )
 
 // LookupWhoisBulk connects once to Team Cymru WHOIS, sends a bulk query in a single TCP session,
-// and parses the verbose response.
-func LookupWhoisBulk(ctx context.Context, ips []string) ([]model.Result, error) {
+// and parses the verbose response. Multiple rows for one IP (MOAS prefixes) are merged
+// into a single result.
+//
+// The session is bounded by ctx; if ctx ends while the response is being
+// read, the rows parsed so far are returned along with ctx's error.
+// Connecting is additionally capped at 6s, and a server that sends nothing
+// for 10s ends the session the same way, with a timeout error.
+func LookupWhoisBulk(ctx context.Context, ips []string, opts ...Option) ([]model.Result, error) {
+	var rows []model.Result
+	err := whoisSession(ctx, ips, newOptions(opts), func(row model.Result) {
+		rows = append(rows, row)
+	})
+	if len(rows) == 0 {
+		return nil, err
+	}
+	return mergeOrigins(rows), err
+}
+
+// StreamWhoisBulk is LookupWhoisBulk, but calls emit with each result as soon
+// as its rows are known to be complete, instead of returning them all at the
+// end. The rows of one IP arrive together, so an IP's result is emitted when
+// the next IP's first row, or the end of the response, is read; MOAS results
+// are still merged.
+func StreamWhoisBulk(ctx context.Context, ips []string, emit func(model.Result), opts ...Option) error {
+	var group []model.Result
+	flush := func() {
+		if len(group) > 0 {
+			emit(mergeOrigins(group)[0])
+			group = group[:0]
+		}
+	}
+	err := whoisSession(ctx, ips, newOptions(opts), func(row model.Result) {
+		if len(group) > 0 && group[0].IP != row.IP {
+			flush()
+		}
+		group = append(group, row)
+	})
+	flush()
+	return err
+}
+
+// whoisSession runs one bulk session for ips, calling onRow for each row as
+// it is read.
+func whoisSession(ctx context.Context, ips []string, o options, onRow func(model.Result)) error {
 	if len(ips) == 0 {
-		return nil, nil
+		return nil
 	}
 
-	d := net.Dialer{Timeout: 6 * time.Second}
-	conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", whoisHost, whoisPort))
+	dialCtx, cancel := context.WithTimeout(ctx, whoisDialTimeout)
+	defer cancel()
+	conn, err := o.dialer.DialContext(dialCtx, "tcp", o.whoisAddr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer conn.Close()
 
+	// The session lasts as long as ctx: its deadline, or cancellation,
+	// unblocks reads and writes. Each read also waits at most idleTimeout.
+	idle := &idleConn{Conn: conn, timeout: o.idleTimeout}
+	stop := context.AfterFunc(ctx, idle.stop)
+	defer stop()
+
 	// Send begin/verbose, then IPs, then end
-	if _, err := conn.Write([]byte("begin\nverbose\n")); err != nil {
-		return nil, err
-	}
+	var query strings.Builder
+	query.WriteString("begin\nverbose\n")
 	for _, ip := range ips {
 		// Each on its own line
-		if _, err := conn.Write([]byte(ip + "\n")); err != nil {
-			return nil, err
+		query.WriteString(ip + "\n")
+	}
+	query.WriteString("end\n")
+	if _, err := io.WriteString(conn, query.String()); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		return err
 	}
-	if _, err := conn.Write([]byte("end\n")); err != nil {
-		return nil, err
+
+	if o.progress != nil {
+		seen := make(map[netip.Addr]struct{}, len(ips))
+		next := onRow
+		onRow = func(row model.Result) {
+			next(row)
+			if _, ok := seen[row.IP]; !ok {
+				seen[row.IP] = struct{}{}
+				o.progress(len(seen))
+			}
+		}
+	}
+	parseWhoisBulk(idle, time.Now().UTC(), onRow)
+	if !stop() {
+		// ctx ended, possibly cutting the response short
+		return ctx.Err()
+	}
+	if idle.timedOut() {
+		return fmt.Errorf("%s sent nothing for %v", o.whoisAddr, o.idleTimeout)
 	}
+	return nil
+}
 
-	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
-	r := bufio.NewReader(conn)
+// ParseWhoisBulk parses a verbose bulk WHOIS response, stamping results with
+// retrieved. Banner, header and error lines are skipped. It reads until EOF or
+// a read error such as a deadline, keeping the rows parsed so far.
+func ParseWhoisBulk(r io.Reader, retrieved time.Time) []model.Result {
+	var rows []model.Result
+	parseWhoisBulk(r, retrieved, func(row model.Result) { rows = append(rows, row) })
+	return mergeOrigins(rows)
+}
 
-	results := make([]model.Result, 0, len(ips))
-	now := time.Now().UTC()
+// parseWhoisBulk calls onRow for each row of a verbose bulk WHOIS response as
+// it is read.
+func parseWhoisBulk(r io.Reader, retrieved time.Time, onRow func(model.Result)) {
+	br := bufio.NewReader(r)
 	for {
-		line, err := r.ReadString('\n')
+		line, err := br.ReadString('\n')
 		if len(line) > 0 {
 			line = strings.TrimSpace(line)
 			if line == "" {
@@ -67,26 +153,68 @@ func LookupWhoisBulk(ctx context.Context, ips []string) ([]model.Result, error)
 				continue
 			}
 
-			ipStr := fields[1]
-			addr, _ := netip.ParseAddr(ipStr) // Best effort parsing for sorting optimization
+			addr, err := netip.ParseAddr(fields[1])
+			if err != nil {
+				// Column header ("AS | IP | ...") or an error echo
+				continue
+			}
 
-			res := model.Result{
-				ASN:       atoiSafe(fields[0]),
-				IP:        ipStr,
-				IPAddr:    addr,
-				BGPPrefix: fields[2],
+			onRow(model.Result{
+				ASN:       parseASN(fields[0]),
+				IP:        addr,
+				BGPPrefix: parsePrefix(fields[2]),
 				CC:        fields[3],
 				Registry:  fields[4],
-				Allocated: fields[5],
+				Allocated: parseDate(fields[5]),
 				ASName:    fields[len(fields)-1], // last field is AS Name
-				Method:    "whois",
-				Retrieved: now,
-			}
-			results = append(results, res)
+				Method:    model.MethodWhois,
+				Retrieved: retrieved,
+			})
 		}
 		if err != nil { // EOF or timeout
 			break
 		}
 	}
-	return results, nil
-}
\ No newline at end of file
+}
+
+// idleConn is a WHOIS connection whose reads each wait at most timeout for
+// data, until stop sets a deadline in the past for good.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+
+	mu      sync.Mutex
+	stopped bool
+	idle    bool // A read timed out before stop
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	if !c.stopped {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	c.mu.Unlock()
+
+	n, err := c.Conn.Read(p)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		c.mu.Lock()
+		c.idle = !c.stopped
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// stop unblocks reads and writes in progress and fails later ones.
+func (c *idleConn) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	_ = c.Conn.SetDeadline(time.Unix(1, 0))
+}
+
+func (c *idleConn) timedOut() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.idle
+}
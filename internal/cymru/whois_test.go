@@ -0,0 +1,125 @@
+package cymru
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/cymru/cymrutest"
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestLookupWhoisBulk(t *testing.T) {
+	body := cymrutest.BulkResponse(
+		cymrutest.Row{ASN: "64510", IP: "203.0.113.7", Prefix: "203.0.113.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "HIGHER, US"},
+		cymrutest.Row{ASN: "64500", IP: "203.0.113.7", Prefix: "203.0.113.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "LOWER, US"},
+		cymrutest.Row{ASN: "NA", IP: "10.0.0.1", Prefix: "NA", CC: "", Registry: "other", Allocated: "", ASName: "NA"},
+		cymrutest.Row{ASN: "64502", IP: "2001:db8::1", Prefix: "2001:db8::/32", CC: "NL", Registry: "ripencc", Allocated: "2001-09-11", ASName: "V6-NET"},
+	) + "Error: no ASN or IP match on line 4.\n" +
+		"64503 | 192.0.2.1 | truncated\n" +
+		"64504 | not-an-ip | 192.0.2.0/24 | US | arin | 2010-05-01 | BROKEN\n"
+	whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: body}))
+
+	ips := []string{"203.0.113.7", "10.0.0.1", "2001:db8::1"}
+	results, err := LookupWhoisBulk(context.Background(), ips, WithWhoisAddr(whois.Addr))
+	if err != nil {
+		t.Fatalf("LookupWhoisBulk: %v", err)
+	}
+	if sessions := whois.Sessions(); len(sessions) != 1 || strings.Join(sessions[0], ",") != strings.Join(ips, ",") {
+		t.Fatalf("expected one session with every IP, got %v", sessions)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results with malformed lines skipped, got %+v", results)
+	}
+
+	moas := results[0]
+	if moas.IP != netip.MustParseAddr("203.0.113.7") || !moas.MOAS || moas.ASN != 64500 || moas.ASName != "LOWER, US" || len(moas.Origins) != 2 {
+		t.Fatalf("expected merged MOAS result, got %+v", moas)
+	}
+	if moas.Method != model.MethodWhois || moas.Allocated != model.MustParseDate("2010-05-01") {
+		t.Fatalf("unexpected fields: %+v", moas)
+	}
+	if unrouted := results[1]; unrouted.ASN.Known() || unrouted.BGPPrefix.IsValid() || !unrouted.Allocated.IsZero() {
+		t.Fatalf("expected NA fields to parse as unknown, got %+v", unrouted)
+	}
+	if v6 := results[2]; v6.ASN != 64502 || v6.BGPPrefix != netip.MustParsePrefix("2001:db8::/32") || v6.ASName != "V6-NET" {
+		t.Fatalf("unexpected IPv6 result: %+v", v6)
+	}
+}
+
+func TestLookupWhoisBulkServerBehaviour(t *testing.T) {
+	row := cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"}
+
+	t.Run("slow", func(t *testing.T) {
+		whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: cymrutest.BulkResponse(row), Delay: 200 * time.Millisecond}))
+		results, err := LookupWhoisBulk(context.Background(), []string{"192.0.2.1"}, WithWhoisAddr(whois.Addr))
+		if err != nil || len(results) != 1 {
+			t.Fatalf("expected slow server to be waited for, got %+v (%v)", results, err)
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		// The connection closes mid-row; complete rows are kept
+		body := cymrutest.BulkResponse(row) + "64501   | 192.0.2.2        | 192.0"
+		whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: body}))
+		results, err := LookupWhoisBulk(context.Background(), []string{"192.0.2.1", "192.0.2.2"}, WithWhoisAddr(whois.Addr))
+		if err != nil || len(results) != 1 || results[0].ASN != 64500 {
+			t.Fatalf("expected the complete row only, got %+v (%v)", results, err)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Reset: true}))
+		results, err := LookupWhoisBulk(context.Background(), []string{"192.0.2.1"}, WithWhoisAddr(whois.Addr))
+		if err != nil || len(results) != 0 {
+			t.Fatalf("expected no results from an aborted session, got %+v (%v)", results, err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		// The server stalls after the first row; cancellation keeps it
+		whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: cymrutest.BulkResponse(row), Hang: true}))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start := time.Now()
+		results, err := LookupWhoisBulk(ctx, []string{"192.0.2.1", "192.0.2.2"}, WithWhoisAddr(whois.Addr))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("cancellation took %s", elapsed)
+		}
+		if len(results) != 1 || results[0].ASN != 64500 {
+			t.Fatalf("expected the row read before cancellation, got %+v", results)
+		}
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		// Without a ctx deadline, the idle timeout ends the session
+		whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: cymrutest.BulkResponse(row), Hang: true}))
+		idle := func(o *options) { o.idleTimeout = 100 * time.Millisecond }
+		results, err := LookupWhoisBulk(context.Background(), []string{"192.0.2.1", "192.0.2.2"}, WithWhoisAddr(whois.Addr), idle)
+		if err == nil || errors.Is(err, context.Canceled) {
+			t.Fatalf("expected an idle timeout error, got %v", err)
+		}
+		if len(results) != 1 || results[0].ASN != 64500 {
+			t.Fatalf("expected the row read before the stall, got %+v", results)
+		}
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+		if _, err := LookupWhoisBulk(context.Background(), []string{"192.0.2.1"}, WithWhoisAddr(addr)); err == nil {
+			t.Fatal("expected dial error")
+		}
+	})
+}
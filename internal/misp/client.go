@@ -0,0 +1,96 @@
+// Package misp uploads events to a MISP instance over its REST API.
+package misp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client adds events to the MISP instance at BaseURL, authenticating with
+// APIKey (a MISP automation key).
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the MISP instance at baseURL, such as
+// "https://misp.example.org".
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{BaseURL: baseURL, APIKey: apiKey, HTTPClient: http.DefaultClient}
+}
+
+// Event identifies an event stored by MISP.
+type Event struct {
+	ID   string `json:"id"`
+	UUID string `json:"uuid"`
+}
+
+// AddEvent creates event, which is encoded as JSON in the {"Event": {...}}
+// shape MISP expects, and returns the stored event's ID and UUID.
+func (c *Client) AddEvent(ctx context.Context, event any) (Event, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return Event{}, fmt.Errorf("encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.BaseURL, "/")+"/events/add", bytes.NewReader(body))
+	if err != nil {
+		return Event{}, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Authorization", c.APIKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return Event{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return Event{}, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Event{}, apiError(resp.StatusCode, data)
+	}
+
+	var created struct {
+		Event Event `json:"Event"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return Event{}, fmt.Errorf("decode response: %w", err)
+	}
+	if created.Event.ID == "" {
+		return Event{}, fmt.Errorf("unexpected MISP response (%s): no event ID", resp.Status)
+	}
+	return created.Event, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// apiError reports a failed request with MISP's message and field errors,
+// when the response has them.
+func apiError(statusCode int, body []byte) error {
+	var failure struct {
+		Message string          `json:"message"`
+		Errors  json.RawMessage `json:"errors"`
+	}
+	if json.Unmarshal(body, &failure) != nil || failure.Message == "" {
+		return fmt.Errorf("MISP request failed with HTTP %d", statusCode)
+	}
+	if len(failure.Errors) > 0 && string(failure.Errors) != "null" && string(failure.Errors) != "[]" {
+		return fmt.Errorf("MISP request failed with HTTP %d: %s %s", statusCode, failure.Message, failure.Errors)
+	}
+	return fmt.Errorf("MISP request failed with HTTP %d: %s", statusCode, failure.Message)
+}
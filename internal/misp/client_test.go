@@ -0,0 +1,84 @@
+package misp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAddEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/events/add" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "test-key" {
+			t.Fatalf("expected the API key in Authorization, got %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Fatalf("expected a JSON body, got %q", got)
+		}
+		var body struct {
+			Event struct {
+				Info string `json:"info"`
+			} `json:"Event"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Event.Info != "test event" {
+			t.Fatalf("unexpected body %+v (%v)", body, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"Event":{"id":"42","uuid":"5f0c1d2e-0000-4000-8000-000000000000","info":"test event"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "test-key")
+	client.HTTPClient = server.Client()
+	event := map[string]any{"Event": map[string]any{"info": "test event"}}
+	created, err := client.AddEvent(context.Background(), event)
+	if err != nil {
+		t.Fatalf("AddEvent: %v", err)
+	}
+	if created.ID != "42" || created.UUID != "5f0c1d2e-0000-4000-8000-000000000000" {
+		t.Fatalf("unexpected created event %+v", created)
+	}
+}
+
+func TestAddEventErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "forbidden",
+			status:  http.StatusForbidden,
+			body:    `{"name":"Authentication failed.","message":"Authentication failed.","url":"/events/add"}`,
+			wantErr: "MISP request failed with HTTP 403: Authentication failed.",
+		},
+		{
+			name:    "validation",
+			status:  http.StatusForbidden,
+			body:    `{"name":"Could not add Event","message":"Could not add Event","url":"/events/add","errors":{"Event":{"info":["Info cannot be empty."]}}}`,
+			wantErr: `Could not add Event {"Event":{"info":["Info cannot be empty."]}}`,
+		},
+		{name: "not JSON", status: http.StatusBadGateway, body: "<html>", wantErr: "MISP request failed with HTTP 502"},
+		{name: "no event", status: http.StatusOK, body: `{"saved":true}`, wantErr: "no event ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := NewClient(server.URL, "test-key").AddEvent(context.Background(), map[string]any{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
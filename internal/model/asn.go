@@ -0,0 +1,78 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ASN is a 32-bit autonomous system number.
+type ASN uint32
+
+// ASNUnknown marks a result whose origin AS is not known, e.g. Team Cymru's
+// "NA" for unannounced space. AS4294967295 is reserved (RFC 7300), so it can
+// never collide with a real origin.
+const ASNUnknown ASN = math.MaxUint32
+
+// ParseASN parses a decimal ASN with an optional "AS" prefix.
+func ParseASN(s string) (ASN, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		s = s[2:]
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || ASN(n) == ASNUnknown {
+		return ASNUnknown, fmt.Errorf("invalid ASN %q", s)
+	}
+	return ASN(n), nil
+}
+
+// Known reports whether a is a real AS number.
+func (a ASN) Known() bool {
+	return a != ASNUnknown
+}
+
+// Int returns a as an int, or -1 when unknown. -1 is the legacy sentinel
+// used by JSON and CSV output.
+func (a ASN) Int() int {
+	if !a.Known() {
+		return -1
+	}
+	return int(a)
+}
+
+// String returns the decimal ASN, or "NA" when unknown.
+func (a ASN) String() string {
+	if !a.Known() {
+		return "NA"
+	}
+	return strconv.FormatUint(uint64(a), 10)
+}
+
+// MarshalJSON encodes a as a number, using -1 for unknown.
+func (a ASN) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Itoa(a.Int())), nil
+}
+
+// UnmarshalJSON accepts a number; -1 and null decode as ASNUnknown.
+func (a *ASN) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*a = ASNUnknown
+		return nil
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid ASN: %w", err)
+	}
+	if n == -1 {
+		*a = ASNUnknown
+		return nil
+	}
+	if n < 0 || n >= int64(ASNUnknown) {
+		return fmt.Errorf("invalid ASN %d", n)
+	}
+	*a = ASN(n)
+	return nil
+}
@@ -0,0 +1,53 @@
+package model
+
+import "fmt"
+
+// Method identifies the Team Cymru interface a result came from.
+type Method uint8
+
+const (
+	MethodUnknown Method = iota
+	MethodDNS
+	MethodWhois
+)
+
+// ParseMethod parses "dns" or "whois".
+func ParseMethod(s string) (Method, error) {
+	switch s {
+	case "dns":
+		return MethodDNS, nil
+	case "whois":
+		return MethodWhois, nil
+	case "":
+		return MethodUnknown, nil
+	default:
+		return MethodUnknown, fmt.Errorf("unknown method %q", s)
+	}
+}
+
+// String returns "dns", "whois" or "" for MethodUnknown.
+func (m Method) String() string {
+	switch m {
+	case MethodDNS:
+		return "dns"
+	case MethodWhois:
+		return "whois"
+	default:
+		return ""
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Method) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *Method) UnmarshalText(text []byte) error {
+	parsed, err := ParseMethod(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
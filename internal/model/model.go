@@ -1,25 +1,122 @@
 package model
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/netip"
 	"time"
 )
 
+// prefixNA is the BGP prefix of unannounced space in Team Cymru answers and
+// legacy JSON.
+const prefixNA = "NA"
+
 // Result is a normalized output row for an IP to ASN mapping.
 //
-// Fields align with Team Cymru outputs and the legacy tool.
+// Fields align with Team Cymru outputs and the legacy tool. JSON encoding
+// keeps the legacy string/number shapes, including "NA" for a zero BGPPrefix.
 type Result struct {
-	ASN        int         `json:"asn"`
-	IP         string      `json:"ip"`
-	IPAddr     netip.Addr  `json:"-"` // Parsed IP for sorting/logic
-	BGPPrefix  string      `json:"bgp_prefix"`
-	CC         string      `json:"cc"`
-	Registry   string      `json:"registry"`
-	Allocated  string      `json:"allocated"` // YYYY-MM-DD string per service output
-	ASName     string      `json:"as_name"`
-	Method     string      `json:"method"` // "dns" or "whois"
-	Retrieved  time.Time   `json:"retrieved"`
-	ProxyCheck *ProxyCheck `json:"proxycheck,omitempty"`
+	ASN        ASN          `json:"asn"`
+	IP         netip.Addr   `json:"ip"`
+	BGPPrefix  netip.Prefix `json:"bgp_prefix"` // Zero when the service reports none
+	CC         string       `json:"cc"`
+	Registry   string       `json:"registry"`
+	Allocated  Date         `json:"allocated"`
+	ASName     string       `json:"as_name"`
+	Method     Method       `json:"method"`
+	Retrieved  time.Time    `json:"retrieved"`
+	Origins    []Origin     `json:"origins,omitempty"` // All origin ASNs, lowest first; set only for MOAS prefixes
+	MOAS       bool         `json:"moas,omitempty"`    // Prefix is announced by more than one origin AS
+	ProxyCheck *ProxyCheck  `json:"proxycheck,omitempty"`
+}
+
+// Origin is one announcing AS of a (possibly multi-origin) BGP prefix.
+type Origin struct {
+	ASN    ASN    `json:"asn"`
+	ASName string `json:"as_name,omitempty"`
+}
+
+// AllOrigins returns every origin AS for the result.
+//
+// Single-origin results report their primary ASN, so callers can treat both
+// cases uniformly.
+func (r Result) AllOrigins() []Origin {
+	if len(r.Origins) > 0 {
+		return r.Origins
+	}
+	return []Origin{{ASN: r.ASN, ASName: r.ASName}}
+}
+
+// OriginASNs returns the ASNs of AllOrigins in order.
+func (r Result) OriginASNs() []ASN {
+	origins := r.AllOrigins()
+	asns := make([]ASN, 0, len(origins))
+	for _, origin := range origins {
+		asns = append(asns, origin.ASN)
+	}
+	return asns
+}
+
+// IPString returns the canonical IP text, or "" when unset.
+func (r Result) IPString() string {
+	if !r.IP.IsValid() {
+		return ""
+	}
+	return r.IP.String()
+}
+
+// PrefixString returns the BGP prefix text, or "" when unset.
+func (r Result) PrefixString() string {
+	if !r.BGPPrefix.IsValid() {
+		return ""
+	}
+	return r.BGPPrefix.String()
+}
+
+// resultJSON is Result with the BGP prefix as its legacy JSON text.
+type resultJSON struct {
+	plainResult
+	BGPPrefix string `json:"bgp_prefix"`
+}
+
+// plainResult has Result's fields without its JSON methods.
+type plainResult Result
+
+// MarshalJSON encodes r with BGPPrefix as "NA" when it is zero.
+func (r Result) MarshalJSON() ([]byte, error) {
+	prefix := prefixNA
+	if r.BGPPrefix.IsValid() {
+		prefix = r.BGPPrefix.String()
+	}
+	return json.Marshal(resultJSON{plainResult: plainResult(r), BGPPrefix: prefix})
+}
+
+// UnmarshalJSON decodes a Result; a bgp_prefix of "NA" or "" decodes as the
+// zero prefix.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var decoded resultJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*r = Result(decoded.plainResult)
+	if decoded.BGPPrefix != "" && decoded.BGPPrefix != prefixNA {
+		prefix, err := netip.ParsePrefix(decoded.BGPPrefix)
+		if err != nil {
+			return fmt.Errorf("invalid BGP prefix: %w", err)
+		}
+		r.BGPPrefix = prefix
+	}
+	return nil
+}
+
+// ASInfo is the registration data of an autonomous system.
+type ASInfo struct {
+	ASN       ASN       `json:"asn"`
+	CC        string    `json:"cc"`
+	Registry  string    `json:"registry"`
+	Allocated Date      `json:"allocated"`
+	ASName    string    `json:"as_name"`
+	Retrieved time.Time `json:"retrieved"`
 }
 
 // ProxyCheck contains optional enrichment data from proxycheck.io.
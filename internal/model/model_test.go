@@ -0,0 +1,111 @@
+package model
+
+import (
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultJSONKeepsLegacyShape(t *testing.T) {
+	result := Result{
+		ASN:       ASNUnknown,
+		IP:        netip.MustParseAddr("198.51.100.7"),
+		CC:        "US",
+		Registry:  "arin",
+		Allocated: MustParseDate("2020-01-31"),
+		ASName:    "NA",
+		Method:    MethodWhois,
+		Retrieved: time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC),
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`"asn":-1`,
+		`"ip":"198.51.100.7"`,
+		`"bgp_prefix":"NA"`,
+		`"allocated":"2020-01-31"`,
+		`"method":"whois"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %s in %s", want, got)
+		}
+	}
+
+	var decoded Result
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.ASN.Known() || decoded.IP != result.IP || decoded.BGPPrefix.IsValid() || decoded.Allocated != result.Allocated || decoded.Method != MethodWhois {
+		t.Fatalf("round trip mismatch: %+v", decoded)
+	}
+}
+
+func TestResultJSONPrefix(t *testing.T) {
+	result := Result{ASN: 64500, IP: netip.MustParseAddr("192.0.2.1"), BGPPrefix: netip.MustParsePrefix("192.0.2.0/24")}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"bgp_prefix":"192.0.2.0/24"`) {
+		t.Fatalf("expected the prefix in %s", data)
+	}
+	var decoded Result
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.BGPPrefix != result.BGPPrefix {
+		t.Fatalf("round trip = %v (%v)", decoded.BGPPrefix, err)
+	}
+
+	// An empty prefix also decodes as none
+	if err := json.Unmarshal([]byte(`{"asn":-1,"ip":"192.0.2.1","bgp_prefix":""}`), &decoded); err != nil || decoded.BGPPrefix.IsValid() {
+		t.Fatalf("empty prefix decoded as %v (%v)", decoded.BGPPrefix, err)
+	}
+	if err := json.Unmarshal([]byte(`{"bgp_prefix":"192.0.2.0"}`), &decoded); err == nil {
+		t.Fatal("expected an error for an invalid prefix")
+	}
+}
+
+func TestParseASN(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ASN
+		wantErr bool
+	}{
+		{input: "13335", want: 13335},
+		{input: "AS15169", want: 15169},
+		{input: "4200000000", want: 4200000000},
+		{input: "NA", want: ASNUnknown, wantErr: true},
+		{input: "-1", want: ASNUnknown, wantErr: true},
+		{input: "4294967295", want: ASNUnknown, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseASN(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Fatalf("ParseASN(%q) = %v, %v; want %v, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if ASNUnknown.String() != "NA" || ASN(64500).String() != "64500" {
+		t.Fatalf("unexpected ASN strings %q, %q", ASNUnknown.String(), ASN(64500).String())
+	}
+}
+
+func TestDateCompareAndZero(t *testing.T) {
+	older := MustParseDate("1992-12-01")
+	newer := MustParseDate("2011-01-01")
+	if older.Compare(newer) >= 0 || newer.Compare(older) <= 0 || older.Compare(older) != 0 {
+		t.Fatalf("unexpected date ordering between %s and %s", older, newer)
+	}
+
+	var zero Date
+	if err := zero.UnmarshalText(nil); err != nil || !zero.IsZero() || zero.String() != "" {
+		t.Fatalf("expected empty text to decode as zero date, got %v (%v)", zero, err)
+	}
+	if _, err := ParseDate("2020-13-01"); err == nil {
+		t.Fatal("expected invalid month to fail")
+	}
+}
@@ -0,0 +1,50 @@
+// Package modeltest provides lookup results for testing the writers of
+// stored and exported runs.
+package modeltest
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// Results returns three results covering the shapes writers must handle: an
+// enriched IPv4 result, an IPv6 result of a multi-origin prefix and an
+// unannounced IP with nothing but its address.
+func Results() []model.Result {
+	vpn := true
+	risk := 80
+	return []model.Result{
+		{
+			ASN:       64500,
+			IP:        netip.MustParseAddr("203.0.113.7"),
+			BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"),
+			CC:        "US",
+			Registry:  "arin",
+			Allocated: model.MustParseDate("2020-01-01"),
+			ASName:    "TEST-NET",
+			Method:    model.MethodWhois,
+			Retrieved: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			ProxyCheck: &model.ProxyCheck{
+				VPN:         &vpn,
+				Risk:        &risk,
+				VPNProvider: "IVPN",
+			},
+		},
+		{
+			ASN:       64501,
+			IP:        netip.MustParseAddr("2001:db8::1"),
+			BGPPrefix: netip.MustParsePrefix("2001:db8::/32"),
+			CC:        "DE",
+			Registry:  "ripencc",
+			ASName:    "FIRST",
+			MOAS:      true,
+			Origins:   []model.Origin{{ASN: 64501, ASName: "FIRST"}, {ASN: 64502, ASName: "SECOND"}},
+		},
+		{
+			ASN: model.ASNUnknown,
+			IP:  netip.MustParseAddr("192.0.2.1"),
+		},
+	}
+}
@@ -0,0 +1,161 @@
+// Package netproxy routes outbound connections through a SOCKS5 or HTTP
+// CONNECT proxy.
+package netproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer opens TCP connections. *net.Dialer satisfies it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Parse validates a proxy URL: socks5://, socks5h://, http:// or https://,
+// with optional user:password. A missing port defaults to 1080 for SOCKS5
+// and 8080 for HTTP (443 for https://).
+func Parse(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q (want socks5, socks5h, http or https)", raw, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", raw)
+	}
+	return u, nil
+}
+
+// NewDialer returns a Dialer that tunnels through the proxy at u, or a plain
+// dialer when u is nil.
+func NewDialer(u *url.URL) (Dialer, error) {
+	direct := &net.Dialer{}
+	if u == nil {
+		return direct, nil
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, direct)
+		if err != nil {
+			return nil, err
+		}
+		return d.(proxy.ContextDialer), nil
+	default:
+		return &connectDialer{proxy: u, forward: direct}, nil
+	}
+}
+
+// NewHTTPClient returns an HTTP client that sends requests through the proxy
+// at u, or one that ignores the environment's proxy settings when u is nil,
+// so the configured proxy is the only one in effect.
+func NewHTTPClient(u *url.URL) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	if u != nil {
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Transport: transport}
+}
+
+// connectDialer tunnels connections with HTTP CONNECT.
+type connectDialer struct {
+	proxy   *url.URL
+	forward Dialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("proxy: network %q not supported through HTTP CONNECT", network)
+	}
+
+	proxyAddr := d.proxy.Host
+	if d.proxy.Port() == "" {
+		port := "8080"
+		if d.proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(d.proxy.Hostname(), port)
+	}
+	conn, err := d.forward.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	if d.proxy.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: d.proxy.Hostname()})
+	}
+
+	// Bound the handshake by ctx; the tunnel itself is the caller's
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("proxy: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy: CONNECT %s: %s", address, resp.Status)
+	}
+
+	if !stop() {
+		// ctx ended as the handshake finished
+		conn.Close()
+		return nil, fmt.Errorf("proxy: %w", ctx.Err())
+	}
+	_ = conn.SetDeadline(time.Time{})
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn returns bytes the proxy sent right after its CONNECT reply
+// before reading from the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
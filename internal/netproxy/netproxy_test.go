@@ -0,0 +1,321 @@
+package netproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/hink/ip2asn/internal/cymru"
+	"github.com/hink/ip2asn/internal/cymru/cymrutest"
+)
+
+// standIn is a local SOCKS5 or HTTP CONNECT proxy that sends every tunnel to
+// target, recording the address each client asked for.
+type standIn struct {
+	ln       net.Listener
+	target   string
+	password string // Required HTTP Basic password for user "analyst", if set
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func startStandIn(t *testing.T, kind, target string) *standIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &standIn{ln: ln, target: target}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if kind == "socks5" {
+				go p.serveSOCKS5(conn)
+			} else {
+				go p.serveConnect(conn)
+			}
+		}
+	}()
+	return p
+}
+
+func (p *standIn) url(scheme string) string {
+	return scheme + "://" + p.ln.Addr().String()
+}
+
+func (p *standIn) record(addr string) {
+	p.mu.Lock()
+	p.requested = append(p.requested, addr)
+	p.mu.Unlock()
+}
+
+func (p *standIn) requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requested...)
+}
+
+func (p *standIn) serveSOCKS5(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	// Greeting: version, method count, methods; accept "no auth"
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(br, make([]byte, header[1])); err != nil {
+		return
+	}
+	_, _ = conn.Write([]byte{5, 0})
+
+	// Request: version, CONNECT, reserved, address type, address, port
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(br, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(br, ip)
+		host = net.IP(ip).String()
+	case 3:
+		length, _ := br.ReadByte()
+		name := make([]byte, length)
+		_, _ = io.ReadFull(br, name)
+		host = string(name)
+	case 4:
+		ip := make([]byte, 16)
+		_, _ = io.ReadFull(br, ip)
+		host = net.IP(ip).String()
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(br, port); err != nil {
+		return
+	}
+	p.record(net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	pipe(conn, br, upstream)
+}
+
+func (p *standIn) serveConnect(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil || req.Method != http.MethodConnect {
+		return
+	}
+	if p.password != "" {
+		user, password, ok := (&http.Request{Header: http.Header{"Authorization": req.Header["Proxy-Authorization"]}}).BasicAuth()
+		if !ok || user != "analyst" || password != p.password {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+	}
+	p.record(req.Host)
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer upstream.Close()
+	_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	pipe(conn, br, upstream)
+}
+
+// pipe relays both ways until upstream finishes sending; the callers then
+// close both connections.
+func pipe(client net.Conn, clientReader io.Reader, upstream net.Conn) {
+	go func() {
+		_, _ = io.Copy(upstream, clientReader)
+	}()
+	_, _ = io.Copy(client, upstream)
+}
+
+// startFakeWhois answers every bulk session with a canned row.
+func startFakeWhois(t *testing.T) string {
+	t.Helper()
+	row := cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE, US"}
+	return cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: cymrutest.BulkResponse(row)})).Addr
+}
+
+func TestWhoisThroughProxies(t *testing.T) {
+	whois := startFakeWhois(t)
+	for _, scheme := range []string{"socks5", "http"} {
+		t.Run(scheme, func(t *testing.T) {
+			stand := startStandIn(t, scheme, whois)
+			proxyURL, err := Parse(stand.url(scheme))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			dialer, err := NewDialer(proxyURL)
+			if err != nil {
+				t.Fatalf("NewDialer: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			results, err := cymru.LookupWhoisBulk(ctx, []string{"192.0.2.1"}, cymru.WithDialer(dialer))
+			if err != nil {
+				t.Fatalf("LookupWhoisBulk: %v", err)
+			}
+			if len(results) != 1 || results[0].ASN != 64500 || results[0].ASName != "EXAMPLE, US" {
+				t.Fatalf("unexpected results: %+v", results)
+			}
+			if got := stand.requests(); len(got) != 1 || got[0] != "whois.cymru.com:43" {
+				t.Fatalf("expected tunnel to whois.cymru.com:43, got %v", got)
+			}
+		})
+	}
+}
+
+func TestConnectProxyAuthentication(t *testing.T) {
+	whois := startFakeWhois(t)
+	stand := startStandIn(t, "http", whois)
+	stand.password = "s3cret"
+
+	for _, tt := range []struct {
+		userinfo string
+		wantErr  bool
+	}{
+		{userinfo: "analyst:wrong@", wantErr: true},
+		{userinfo: "analyst:s3cret@"},
+	} {
+		proxyURL, err := Parse("http://" + tt.userinfo + stand.ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		dialer, _ := NewDialer(proxyURL)
+		conn, err := dialer.DialContext(context.Background(), "tcp", "whois.cymru.com:43")
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("DialContext with %q: error = %v, wantErr %v", tt.userinfo, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "407") {
+			t.Fatalf("expected proxy status in error, got %v", err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
+
+func TestDNSThroughProxies(t *testing.T) {
+	answer := func(query []byte) []byte {
+		var msg dnsmessage.Message
+		if err := msg.Unpack(query); err != nil {
+			return nil
+		}
+		msg.Header.Response = true
+		msg.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET},
+			Body:   &dnsmessage.TXTResource{TXT: []string{"64500 | US | arin | 2010-05-01 | EXAMPLE, US"}},
+		}}
+		resp, _ := msg.Pack()
+		return resp
+	}
+
+	// DNS over TCP
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				resp := answer(query)
+				_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+			}()
+		}
+	}()
+
+	// DNS-over-HTTPS; the test certificate is valid for example.com
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(answer(query))
+	}))
+	defer doh.Close()
+
+	tests := []struct {
+		name   string
+		spec   string
+		target string
+		want   string
+	}{
+		{name: "plain DNS over TCP", spec: "192.0.2.53", target: ln.Addr().String(), want: "192.0.2.53:53"},
+		{name: "DoH", spec: "https://example.com/dns-query", target: doh.Listener.Addr().String(), want: "example.com:443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stand := startStandIn(t, "socks5", tt.target)
+			proxyURL, _ := Parse(stand.url("socks5"))
+			dialer, err := NewDialer(proxyURL)
+			if err != nil {
+				t.Fatalf("NewDialer: %v", err)
+			}
+			httpClient := NewHTTPClient(proxyURL)
+			httpClient.Transport.(*http.Transport).TLSClientConfig = doh.Client().Transport.(*http.Transport).TLSClientConfig
+
+			resolver, err := cymru.NewResolver(tt.spec, cymru.ResolverConfig{Dialer: dialer, TCPOnly: true, HTTPClient: httpClient, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatalf("NewResolver: %v", err)
+			}
+			info, err := cymru.LookupASN(context.Background(), 64500, cymru.WithResolver(resolver))
+			if err != nil {
+				t.Fatalf("LookupASN: %v", err)
+			}
+			if info.ASName != "EXAMPLE, US" {
+				t.Fatalf("unexpected AS info: %+v", info)
+			}
+			if got := stand.requests(); len(got) == 0 || got[0] != tt.want {
+				t.Fatalf("expected tunnel to %s, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRejectsUnsupportedSchemes(t *testing.T) {
+	for _, raw := range []string{"ftp://proxy:21", "socks4://proxy:1080", "http://"} {
+		if _, err := Parse(raw); err == nil {
+			t.Fatalf("Parse(%q): expected error", raw)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// RenderASInfoTable renders AS registration data with the same style as
+// RenderTable.
+func RenderASInfoTable(infos []model.ASInfo, enableColor bool) string {
+	restoreTextColors := configureTextColors(enableColor)
+	defer restoreTextColors()
+
+	tw := table.NewWriter()
+	tw.SetStyle(tableStyle(enableColor))
+	tw.SuppressTrailingSpaces()
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "ASN", Align: text.AlignRight},
+		{Name: "CC", Align: text.AlignCenter},
+		{Name: "Allocated", Align: text.AlignCenter},
+	})
+	tw.AppendHeader(table.Row{"ASN", "CC", "Registry", "Allocated", "AS Name"})
+	for _, info := range infos {
+		tw.AppendRow(table.Row{
+			info.ASN.String(),
+			info.CC,
+			info.Registry,
+			info.Allocated.String(),
+			valueOrDash(info.ASName),
+		})
+	}
+	return tw.Render()
+}
+
+// PrintASInfoTable writes an AS registration table to w.
+func PrintASInfoTable(w io.Writer, infos []model.ASInfo) {
+	fmt.Fprintln(w, RenderASInfoTable(infos, ColorEnabled(w)))
+}
@@ -0,0 +1,307 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// Field is one output column. The same fields, selected by name, make up
+// the table, TUI, CSV and flat JSON outputs.
+type Field struct {
+	// Name selects the field, as in --fields asn,ip,risk.
+	Name string
+	// Header labels the table column, and the CSV column unless CSVHeader
+	// is set.
+	Header    string
+	CSVHeader string
+	// Align and MinWidth shape the table column; it is never shrunk below
+	// MinWidth unless the terminal is too narrow for every column's minimum.
+	Align    text.Align
+	MinWidth int
+	// Enrichment marks proxycheck.io fields, which are empty without
+	// --enrich.
+	Enrichment bool
+
+	// jsonKey is the field's key in flat JSON, when it differs from Name
+	jsonKey string
+	// grow lets the column widen to its content; preferExtra gets spare
+	// width first, and shrinkFirst gives it up first
+	grow        bool
+	preferExtra bool
+	shrinkFirst bool
+
+	table func(r model.Result, enableColor bool) string
+	csv   func(r model.Result) string
+	// json returns the flat JSON value, or nil to omit it
+	json func(flat FlatResult) any
+}
+
+// JSONKey returns the key of the field in flat JSON.
+func (f Field) JSONKey() string {
+	if f.jsonKey != "" {
+		return f.jsonKey
+	}
+	return f.Name
+}
+
+// csvHeader returns the CSV column header.
+func (f Field) csvHeader() string {
+	if f.CSVHeader != "" {
+		return f.CSVHeader
+	}
+	return f.Header
+}
+
+// fieldRegistry lists every field in its canonical order.
+var fieldRegistry = []Field{
+	{
+		Name: "asn", Header: "ASN", CSVHeader: "AS", Align: text.AlignRight, MinWidth: 3,
+		table: func(r model.Result, _ bool) string { return asnCell(r, model.ASN.String) },
+		csv:   func(r model.Result) string { return asnCell(r, legacyASN) },
+		json:  func(f FlatResult) any { return f.ASN },
+	},
+	{
+		Name: "ip", Header: "IP", MinWidth: 7, grow: true,
+		table: func(r model.Result, _ bool) string { return r.IPString() },
+		csv:   model.Result.IPString,
+		json:  func(f FlatResult) any { return f.IP },
+	},
+	{
+		Name: "prefix", Header: "BGP Prefix", MinWidth: 10, grow: true, jsonKey: "bgp_prefix",
+		table: func(r model.Result, _ bool) string { return r.PrefixString() },
+		csv:   legacyPrefix,
+		json:  func(f FlatResult) any { return f.BGPPrefix },
+	},
+	{
+		Name: "cc", Header: "CC", Align: text.AlignCenter, MinWidth: 2,
+		table: func(r model.Result, _ bool) string { return r.CC },
+		csv:   func(r model.Result) string { return r.CC },
+		json:  func(f FlatResult) any { return f.CC },
+	},
+	{
+		Name: "registry", Header: "Registry", MinWidth: 8, grow: true,
+		table: func(r model.Result, _ bool) string { return r.Registry },
+		csv:   func(r model.Result) string { return r.Registry },
+		json:  func(f FlatResult) any { return f.Registry },
+	},
+	{
+		Name: "allocated", Header: "Allocated", Align: text.AlignCenter, MinWidth: 10,
+		table: func(r model.Result, _ bool) string { return r.Allocated.String() },
+		csv:   func(r model.Result) string { return r.Allocated.String() },
+		json:  func(f FlatResult) any { return f.Allocated },
+	},
+	{
+		Name: "as_name", Header: "AS Name", MinWidth: 12, grow: true, preferExtra: true, shrinkFirst: true,
+		table: func(r model.Result, _ bool) string { return valueOrDash(r.ASName) },
+		csv:   func(r model.Result) string { return r.ASName },
+		json:  func(f FlatResult) any { return f.ASName },
+	},
+	{
+		Name: "method", Header: "Method", Align: text.AlignCenter, MinWidth: 5,
+		table: func(r model.Result, _ bool) string { return r.Method.String() },
+		csv:   func(r model.Result) string { return r.Method.String() },
+		json:  func(f FlatResult) any { return f.Method },
+	},
+	{
+		Name: "retrieved", Header: "Retrieved", MinWidth: 10, grow: true,
+		table: func(r model.Result, _ bool) string { return retrievedCell(r) },
+		csv:   retrievedCell,
+		json:  func(f FlatResult) any { return f.Retrieved },
+	},
+	{
+		Name: "origins", Header: "Origins", MinWidth: 7, grow: true,
+		table: func(r model.Result, enableColor bool) string { return tableValue(originsCell(r), enableColor) },
+		csv:   originsCell,
+		json:  func(f FlatResult) any { return omitEmpty(f.Origins) },
+	},
+	{
+		Name: "status", Header: "Status", Align: text.AlignCenter, MinWidth: 6, Enrichment: true,
+		table: func(r model.Result, _ bool) string { return statusLabels(r.ProxyCheck) },
+		csv:   func(r model.Result) string { return strings.Join(statusList(r.ProxyCheck), " ") },
+		json:  func(f FlatResult) any { return omitEmpty(f.Status) },
+	},
+	boolField("proxy", "Proxy", func(pc *model.ProxyCheck) *bool { return pc.Proxy }, func(f FlatResult) *bool { return f.Proxy }),
+	boolField("vpn", "VPN", func(pc *model.ProxyCheck) *bool { return pc.VPN }, func(f FlatResult) *bool { return f.VPN }),
+	boolField("compromised", "Compromised", func(pc *model.ProxyCheck) *bool { return pc.Compromised }, func(f FlatResult) *bool { return f.Compromised }),
+	boolField("hosting", "Hosting", func(pc *model.ProxyCheck) *bool { return pc.Hosting }, func(f FlatResult) *bool { return f.Hosting }),
+	boolField("tor", "TOR", func(pc *model.ProxyCheck) *bool { return pc.TOR }, func(f FlatResult) *bool { return f.TOR }),
+	{
+		Name: "risk", Header: "Risk", Align: text.AlignCenter, MinWidth: 4, Enrichment: true,
+		table: func(r model.Result, enableColor bool) string { return riskCell(r.ProxyCheck, enableColor) },
+		csv:   func(r model.Result) string { return riskCSVCell(r.ProxyCheck) },
+		json: func(f FlatResult) any {
+			if f.Risk == nil {
+				return nil
+			}
+			return *f.Risk
+		},
+	},
+	stringField("vpn_provider", "VPN Provider", 8, func(pc *model.ProxyCheck) string { return pc.VPNProvider }, func(f FlatResult) string { return f.VPNProvider }),
+	stringField("city", "City", 6, func(pc *model.ProxyCheck) string { return pc.City }, func(f FlatResult) string { return f.City }),
+	stringField("state", "State", 6, func(pc *model.ProxyCheck) string { return pc.State }, func(f FlatResult) string { return f.State }),
+	stringField("country", "Country", 7, func(pc *model.ProxyCheck) string { return pc.Country }, func(f FlatResult) string { return f.Country }),
+}
+
+// boolField is a proxycheck.io detection flag.
+func boolField(name, header string, pick func(*model.ProxyCheck) *bool, flat func(FlatResult) *bool) Field {
+	return Field{
+		Name: name, Header: header, Align: text.AlignCenter, MinWidth: len(header), Enrichment: true,
+		table: func(r model.Result, enableColor bool) string {
+			return tableValue(boolCell(r.ProxyCheck, pick), enableColor)
+		},
+		csv: func(r model.Result) string { return boolCell(r.ProxyCheck, pick) },
+		json: func(f FlatResult) any {
+			if value := flat(f); value != nil {
+				return *value
+			}
+			return nil
+		},
+	}
+}
+
+// stringField is a proxycheck.io text attribute.
+func stringField(name, header string, minWidth int, pick func(*model.ProxyCheck) string, flat func(FlatResult) string) Field {
+	return Field{
+		Name: name, Header: header, MinWidth: minWidth, grow: true, Enrichment: true,
+		table: func(r model.Result, enableColor bool) string {
+			return tableValue(enrichmentString(r.ProxyCheck, pick), enableColor)
+		},
+		csv: func(r model.Result) string { return enrichmentString(r.ProxyCheck, pick) },
+		json: func(f FlatResult) any {
+			if value := flat(f); value != "" {
+				return value
+			}
+			return nil
+		},
+	}
+}
+
+var (
+	basicTableFields      = []string{"asn", "ip", "prefix", "cc", "registry", "allocated", "as_name"}
+	proxycheckTableFields = []string{"asn", "ip", "prefix", "as_name", "status", "vpn_provider", "city", "state", "country", "risk"}
+	enrichmentCSVFields   = []string{"proxy", "vpn", "compromised", "hosting", "tor", "risk", "vpn_provider", "city", "state", "country"}
+	reportEnrichedFields  = []string{"asn", "ip", "prefix", "cc", "as_name", "status", "risk", "vpn_provider", "country"}
+)
+
+// Fields returns every field in its canonical order.
+func Fields() []Field {
+	return append([]Field(nil), fieldRegistry...)
+}
+
+// FieldNames returns the names of every field.
+func FieldNames() []string {
+	names := make([]string, 0, len(fieldRegistry))
+	for _, field := range fieldRegistry {
+		names = append(names, field.Name)
+	}
+	return names
+}
+
+// LookupField returns the field called name; the flat JSON key (such as
+// bgp_prefix) is accepted too.
+func LookupField(name string) (Field, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, field := range fieldRegistry {
+		if field.Name == name || field.JSONKey() == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// ParseFields parses a comma-separated field list such as
+// "asn,ip,prefix,risk", keeping its order. Unknown and repeated names are
+// errors.
+func ParseFields(spec string) ([]Field, error) {
+	var fields []Field
+	seen := make(map[string]struct{})
+	for _, name := range strings.Split(spec, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		field, ok := LookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q (want %s)", strings.TrimSpace(name), strings.Join(FieldNames(), ", "))
+		}
+		if _, dup := seen[field.Name]; dup {
+			return nil, fmt.Errorf("field %q is listed more than once", field.Name)
+		}
+		seen[field.Name] = struct{}{}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields selected")
+	}
+	return fields, nil
+}
+
+// HasEnrichment reports whether any of fields comes from proxycheck.io.
+func HasEnrichment(fields []Field) bool {
+	for _, field := range fields {
+		if field.Enrichment {
+			return true
+		}
+	}
+	return false
+}
+
+// tableFields returns the table columns: opts.Fields when set, otherwise
+// the columns of opts.Mode.
+func tableFields(opts TableOptions) []Field {
+	if len(opts.Fields) > 0 {
+		return opts.Fields
+	}
+	if opts.Mode == TableModeProxycheck {
+		return mustFields(proxycheckTableFields...)
+	}
+	return mustFields(basicTableFields...)
+}
+
+// csvFields returns the default CSV columns.
+func csvFields(includeEnrichment bool) []Field {
+	fields := mustFields(basicTableFields...)
+	if includeEnrichment {
+		fields = append(fields, mustFields(enrichmentCSVFields...)...)
+	}
+	return fields
+}
+
+func mustFields(names ...string) []Field {
+	fields := make([]Field, 0, len(names))
+	for _, name := range names {
+		field, ok := LookupField(name)
+		if !ok {
+			panic("output: unknown field " + name)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func retrievedCell(r model.Result) string {
+	if r.Retrieved.IsZero() {
+		return ""
+	}
+	return r.Retrieved.UTC().Format(time.RFC3339)
+}
+
+// originsCell lists every origin of a MOAS result, and nothing otherwise.
+func originsCell(r model.Result) string {
+	if !r.MOAS {
+		return ""
+	}
+	return asnCell(r, model.ASN.String)
+}
+
+// omitEmpty returns nil for an empty slice, so the JSON field is omitted.
+func omitEmpty[T any](values []T) any {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func fieldsTestResult() model.Result {
+	trueValue := true
+	riskValue := 80
+	return model.Result{
+		ASN:       64500,
+		IP:        netip.MustParseAddr("203.0.113.7"),
+		BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"),
+		CC:        "US",
+		Registry:  "arin",
+		Allocated: model.MustParseDate("2020-01-01"),
+		ASName:    "TEST-NET",
+		ProxyCheck: &model.ProxyCheck{
+			VPN:         &trueValue,
+			Risk:        &riskValue,
+			VPNProvider: "IVPN",
+		},
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{spec: "asn,ip,prefix,cc,risk,vpn_provider", want: []string{"asn", "ip", "prefix", "cc", "risk", "vpn_provider"}},
+		{spec: " Risk , ASN ", want: []string{"risk", "asn"}},
+		{spec: "bgp_prefix", want: []string{"prefix"}},
+		{spec: "asn,nope", wantErr: true},
+		{spec: "asn,ip,asn", wantErr: true},
+		{spec: ",", wantErr: true},
+	}
+	for _, tt := range tests {
+		fields, err := ParseFields(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseFields(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+		if len(fields) != len(tt.want) {
+			t.Fatalf("ParseFields(%q) = %d fields, want %v", tt.spec, len(fields), tt.want)
+		}
+		for i := range tt.want {
+			if fields[i].Name != tt.want[i] {
+				t.Fatalf("ParseFields(%q)[%d] = %s, want %s", tt.spec, i, fields[i].Name, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFieldsSelectColumnsAcrossFormats(t *testing.T) {
+	fields, err := ParseFields("asn,ip,cc,risk,vpn_provider")
+	if err != nil {
+		t.Fatalf("ParseFields: %v", err)
+	}
+	results := []model.Result{fieldsTestResult()}
+
+	rendered := RenderTable(results, TableOptions{Fields: fields}, 0, false)
+	header := strings.Split(rendered, "\n")[1]
+	for _, want := range []string{"ASN", "IP", "CC", "Risk", "VPN Provider"} {
+		if !strings.Contains(header, want) {
+			t.Fatalf("expected %q in table header, got %q", want, header)
+		}
+	}
+	if strings.Contains(header, "BGP Prefix") || strings.Contains(header, "AS Name") {
+		t.Fatalf("expected only the selected columns, got %q", header)
+	}
+	if !strings.Contains(rendered, "IVPN") || !strings.Contains(rendered, " 80 ") {
+		t.Fatalf("expected Cymru and proxycheck values side by side, got:\n%s", rendered)
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	WriteCSVFields(cw, results, fields, false)
+	cw.Flush()
+	if want := "AS,IP,CC,Risk,VPN Provider\n64500,203.0.113.7,US,80,IVPN\n"; buf.String() != want {
+		t.Fatalf("CSV = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	nw := NewNDJSONWriter(&buf, true)
+	nw.SetFields(fields)
+	if err := nw.Write(results[0]); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if want := `{"asn":64500,"ip":"203.0.113.7","cc":"US","risk":80,"vpn_provider":"IVPN"}` + "\n"; buf.String() != want {
+		t.Fatalf("NDJSON = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFieldsColorRiskRowsOnlyWithEnrichmentFields(t *testing.T) {
+	results := []model.Result{fieldsTestResult()}
+	tests := []struct {
+		spec      string
+		wantColor bool
+	}{
+		{spec: "asn,ip,risk", wantColor: true},
+		{spec: "asn,ip,cc", wantColor: false},
+	}
+	for _, tt := range tests {
+		fields, err := ParseFields(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseFields: %v", err)
+		}
+		rendered := RenderTable(results, TableOptions{Fields: fields}, 0, true)
+		if got := strings.Contains(rendered, "\x1b[101"); got != tt.wantColor {
+			t.Fatalf("%s: red risk row = %v, want %v:\n%q", tt.spec, got, tt.wantColor, rendered)
+		}
+	}
+}
@@ -2,59 +2,63 @@ package output
 
 import (
 	"fmt"
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
+	"net/netip"
+	"sort"
 	"time"
 )
 
 // JSONASNGroup represents the JSON output structure grouped by ASN.
 type JSONASNGroup struct {
-	ASN    int           `json:"asn"`
+	ASN    model.ASN     `json:"asn"`
 	ASName string        `json:"as_name"`
 	IPs    []JSONIPEntry `json:"ips"`
+	// Partial marks groups from a lookup that did not finish, so IPs may be
+	// missing from them.
+	Partial bool `json:"partial,omitempty"`
 }
 
 // JSONIPEntry contains per-IP metadata nested under an ASN group.
 type JSONIPEntry struct {
-	IP         string               `json:"ip"`
-	BGPPrefix  string               `json:"bgp_prefix"`
+	IP         netip.Addr           `json:"ip"`
+	BGPPrefix  string               `json:"bgp_prefix"` // "NA" when unannounced
 	CC         string               `json:"cc"`
 	Registry   string               `json:"registry"`
-	Allocated  string               `json:"allocated"`
-	Method     string               `json:"method"`
+	Allocated  model.Date           `json:"allocated"`
+	Method     model.Method         `json:"method"`
 	Retrieved  time.Time            `json:"retrieved"`
+	MOAS       bool                 `json:"moas,omitempty"`
+	Origins    []model.ASN          `json:"origins,omitempty"` // Cross-reference to every group listing this IP
 	ProxyCheck *JSONProxyCheckEntry `json:"proxycheck,omitempty"`
 }
 
 // GroupResultsByASN transforms a flat list of results into ASN-grouped JSON structures.
+//
+// Groups are ordered by ASN. Multi-origin (MOAS) results are listed in every
+// origin's group, each entry carrying the full origin list as a cross-reference.
 func GroupResultsByASN(results []model.Result, includeEnrichment bool) []JSONASNGroup {
 	if len(results) == 0 {
 		return nil
 	}
 
 	grouped := make([]JSONASNGroup, 0)
-	var currentGroup *JSONASNGroup
-	var seen map[string]struct{}
+	groupIndex := make(map[model.ASN]int)
+	seen := make(map[model.ASN]map[string]struct{})
 
 	for _, r := range results {
-		if currentGroup == nil || r.ASN != currentGroup.ASN {
-			grouped = append(grouped, JSONASNGroup{
-				ASN:    r.ASN,
-				ASName: r.ASName,
-				IPs:    make([]JSONIPEntry, 0, 1),
-			})
-			currentGroup = &grouped[len(grouped)-1]
-			seen = make(map[string]struct{})
-		}
-
 		entry := JSONIPEntry{
 			IP:        r.IP,
-			BGPPrefix: r.BGPPrefix,
+			BGPPrefix: legacyPrefix(r),
 			CC:        r.CC,
 			Registry:  r.Registry,
 			Allocated: r.Allocated,
 			Method:    r.Method,
 			Retrieved: r.Retrieved,
 		}
+		if r.MOAS {
+			entry.MOAS = true
+			entry.Origins = r.OriginASNs()
+		}
 		if includeEnrichment && r.ProxyCheck != nil && !r.ProxyCheck.IsEmpty() {
 			entry.ProxyCheck = &JSONProxyCheckEntry{
 				Proxy:       r.ProxyCheck.Proxy,
@@ -70,13 +74,31 @@ func GroupResultsByASN(results []model.Result, includeEnrichment bool) []JSONASN
 			}
 		}
 		key := makeEntryKey(entry)
-		if _, exists := seen[key]; exists {
-			continue
+
+		for _, origin := range r.AllOrigins() {
+			idx, exists := groupIndex[origin.ASN]
+			if !exists {
+				idx = len(grouped)
+				groupIndex[origin.ASN] = idx
+				grouped = append(grouped, JSONASNGroup{
+					ASN:    origin.ASN,
+					ASName: origin.ASName,
+					IPs:    make([]JSONIPEntry, 0, 1),
+				})
+				seen[origin.ASN] = make(map[string]struct{})
+			}
+			if grouped[idx].ASName == "" {
+				grouped[idx].ASName = origin.ASName
+			}
+			if _, exists := seen[origin.ASN][key]; exists {
+				continue
+			}
+			seen[origin.ASN][key] = struct{}{}
+			grouped[idx].IPs = append(grouped[idx].IPs, entry)
 		}
-		seen[key] = struct{}{}
-		currentGroup.IPs = append(currentGroup.IPs, entry)
 	}
 
+	sort.SliceStable(grouped, func(i, j int) bool { return grouped[i].ASN < grouped[j].ASN })
 	return grouped
 }
 
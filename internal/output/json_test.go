@@ -1,7 +1,8 @@
 package output
 
 import (
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
+	"net/netip"
 	"testing"
 	"time"
 )
@@ -13,13 +14,13 @@ func TestGroupResultsByASN(t *testing.T) {
 	input := []model.Result{
 		{
 			ASN:       13335,
-			IP:        "1.1.1.1",
-			BGPPrefix: "1.1.1.0/24",
+			IP:        netip.MustParseAddr("1.1.1.1"),
+			BGPPrefix: netip.MustParsePrefix("1.1.1.0/24"),
 			CC:        "AU",
 			Registry:  "apnic",
-			Allocated: "2011-01-01",
+			Allocated: model.MustParseDate("2011-01-01"),
 			ASName:    "CLOUDFLARENET",
-			Method:    "dns",
+			Method:    model.MethodDNS,
 			Retrieved: ts,
 			ProxyCheck: &model.ProxyCheck{
 				VPN:         &trueValue,
@@ -33,46 +34,46 @@ func TestGroupResultsByASN(t *testing.T) {
 		},
 		{
 			ASN:       13335,
-			IP:        "1.0.0.1",
-			BGPPrefix: "1.0.0.0/24",
+			IP:        netip.MustParseAddr("1.0.0.1"),
+			BGPPrefix: netip.MustParsePrefix("1.0.0.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "2012-02-02",
+			Allocated: model.MustParseDate("2012-02-02"),
 			ASName:    "CLOUDFLARENET",
-			Method:    "dns",
+			Method:    model.MethodDNS,
 			Retrieved: ts.Add(time.Minute),
 		},
 		{
 			ASN:       15169,
-			IP:        "8.8.8.8",
-			BGPPrefix: "8.8.8.0/24",
+			IP:        netip.MustParseAddr("8.8.8.8"),
+			BGPPrefix: netip.MustParsePrefix("8.8.8.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "1992-12-01",
+			Allocated: model.MustParseDate("1992-12-01"),
 			ASName:    "GOOGLE",
-			Method:    "whois",
+			Method:    model.MethodWhois,
 			Retrieved: ts.Add(2 * time.Minute),
 		},
 		{
 			ASN:       15169,
-			IP:        "8.8.4.4",
-			BGPPrefix: "8.8.4.0/24",
+			IP:        netip.MustParseAddr("8.8.4.4"),
+			BGPPrefix: netip.MustParsePrefix("8.8.4.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "1992-12-01",
+			Allocated: model.MustParseDate("1992-12-01"),
 			ASName:    "GOOGLE",
-			Method:    "whois",
+			Method:    model.MethodWhois,
 			Retrieved: ts.Add(3 * time.Minute),
 		},
 		{
 			ASN:       15169,
-			IP:        "8.8.4.4",
-			BGPPrefix: "8.8.4.0/24",
+			IP:        netip.MustParseAddr("8.8.4.4"),
+			BGPPrefix: netip.MustParsePrefix("8.8.4.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "1992-12-01",
+			Allocated: model.MustParseDate("1992-12-01"),
 			ASName:    "GOOGLE",
-			Method:    "whois",
+			Method:    model.MethodWhois,
 			Retrieved: ts.Add(3 * time.Minute),
 		},
 	}
@@ -107,3 +108,53 @@ func TestGroupResultsByASN(t *testing.T) {
 		t.Fatalf("expected deduplicated IP count of 2 for ASN 15169, got %d", len(second.IPs))
 	}
 }
+
+func TestGroupResultsByASNPlacesMOASInEveryOriginGroup(t *testing.T) {
+	ts := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)
+	input := []model.Result{
+		{
+			ASN:       64500,
+			IP:        netip.MustParseAddr("203.0.113.7"),
+			BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"),
+			ASName:    "FIRST-NET",
+			Method:    model.MethodDNS,
+			Retrieved: ts,
+			MOAS:      true,
+			Origins: []model.Origin{
+				{ASN: 64500, ASName: "FIRST-NET"},
+				{ASN: 64502, ASName: "THIRD-NET"},
+			},
+		},
+		{
+			ASN:       64501,
+			IP:        netip.MustParseAddr("198.51.100.1"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
+			ASName:    "SECOND-NET",
+			Method:    model.MethodDNS,
+			Retrieved: ts,
+		},
+	}
+
+	grouped := GroupResultsByASN(input, false)
+	if len(grouped) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(grouped))
+	}
+	if grouped[0].ASN != 64500 || grouped[1].ASN != 64501 || grouped[2].ASN != 64502 {
+		t.Fatalf("expected groups ordered by ASN, got %d, %d, %d", grouped[0].ASN, grouped[1].ASN, grouped[2].ASN)
+	}
+	if grouped[2].ASName != "THIRD-NET" {
+		t.Fatalf("expected secondary origin group to carry its own AS name, got %q", grouped[2].ASName)
+	}
+	for _, group := range []JSONASNGroup{grouped[0], grouped[2]} {
+		if len(group.IPs) != 1 || group.IPs[0].IP != netip.MustParseAddr("203.0.113.7") {
+			t.Fatalf("expected MOAS IP in group %d, got %+v", group.ASN, group.IPs)
+		}
+		entry := group.IPs[0]
+		if !entry.MOAS || len(entry.Origins) != 2 || entry.Origins[0] != 64500 || entry.Origins[1] != 64502 {
+			t.Fatalf("expected MOAS cross-reference in group %d, got %+v", group.ASN, entry)
+		}
+	}
+	if grouped[1].IPs[0].MOAS || grouped[1].IPs[0].Origins != nil {
+		t.Fatalf("did not expect MOAS fields on single-origin entry, got %+v", grouped[1].IPs[0])
+	}
+}
@@ -0,0 +1,119 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// MarkupFormat selects a document markup for RenderMarkup.
+type MarkupFormat int
+
+const (
+	MarkupMarkdown MarkupFormat = iota
+	MarkupHTML
+	MarkupAsciiDoc
+)
+
+// HTMLTableClass is the CSS class of the <table> RenderMarkup emits.
+const HTMLTableClass = "ip2asn-results"
+
+// RenderMarkup renders the table for pasting into documents, with the same
+// columns as RenderTable but no width limit. The enrichment error and
+// partial notes follow as a caption. In HTML, rows are given the CSS
+// classes of their risk coloring, e.g. "bg-hi-red fg-black" from a risk of
+// 75.
+func RenderMarkup(results []model.Result, opts TableOptions, format MarkupFormat) string {
+	restoreTextColors := configureTextColors(false)
+	defer restoreTextColors()
+
+	fields := tableFields(opts)
+	layout := newTableLayout(results, fields, false, 0)
+	caption := markupCaption(opts)
+	if format == MarkupAsciiDoc {
+		return renderAsciiDoc(layout, caption)
+	}
+
+	tw := table.NewWriter()
+	tw.SetColumnConfigs(layout.columnConfigs())
+	tw.AppendHeader(layout.header())
+	for _, row := range layout.rows {
+		tw.AppendRow(row)
+	}
+
+	switch format {
+	case MarkupHTML:
+		tw.Style().HTML.CSSClass = HTMLTableClass
+		if HasEnrichment(fields) {
+			tw.SetRowPainter(layout.rowPainter())
+		}
+		// The caption is written verbatim
+		tw.SetCaption(html.EscapeString(caption))
+		return tw.RenderHTML() + "\n"
+	default:
+		rendered := tw.RenderMarkdown() + "\n"
+		if caption != "" {
+			// A blank line ends the table; go-pretty's caption would
+			// become another row
+			rendered += "\n_" + caption + "_\n"
+		}
+		return rendered
+	}
+}
+
+func markupCaption(opts TableOptions) string {
+	var notes []string
+	if opts.EnrichmentError != "" {
+		notes = append(notes, "Proxycheck enrichment failed: "+opts.EnrichmentError)
+	}
+	if opts.Partial != "" {
+		notes = append(notes, "Partial results: "+opts.Partial)
+	}
+	return strings.Join(notes, "; ")
+}
+
+// renderAsciiDoc renders layout as an AsciiDoc table, which go-pretty does
+// not support.
+func renderAsciiDoc(layout tableLayout, caption string) string {
+	var b strings.Builder
+	specs := make([]string, 0, len(layout.columns))
+	for _, column := range layout.columns {
+		specs = append(specs, asciiDocAlign(column.align))
+	}
+	fmt.Fprintf(&b, "[cols=\"%s\", options=\"header\"]\n|===\n", strings.Join(specs, ","))
+	writeAsciiDocRow(&b, layout.header())
+	for _, row := range layout.rows {
+		writeAsciiDocRow(&b, row)
+	}
+	b.WriteString("|===\n")
+	if caption != "" {
+		fmt.Fprintf(&b, "\nNOTE: %s\n", caption)
+	}
+	return b.String()
+}
+
+func writeAsciiDocRow(b *strings.Builder, row table.Row) {
+	for i, cell := range row {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString("|" + strings.ReplaceAll(fmt.Sprint(cell), "|", "\\|"))
+	}
+	b.WriteByte('\n')
+}
+
+func asciiDocAlign(align text.Align) string {
+	switch align {
+	case text.AlignRight:
+		return ">"
+	case text.AlignCenter:
+		return "^"
+	default:
+		return "<"
+	}
+}
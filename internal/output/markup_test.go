@@ -0,0 +1,67 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestRenderMarkup(t *testing.T) {
+	result := fieldsTestResult()
+	result.ASName = "PIPE|NET <b>"
+	results := []model.Result{result}
+	opts := TableOptions{Mode: TableModeProxycheck, Partial: "interrupted"}
+
+	tests := []struct {
+		name   string
+		format MarkupFormat
+		want   []string
+	}{
+		{
+			name:   "markdown",
+			format: MarkupMarkdown,
+			want: []string{
+				"| ASN | IP | BGP Prefix | AS Name | Status | VPN Provider | City | State | Country | Risk |",
+				"| ---:| --- | --- | --- |:---:| --- | --- | --- | --- |:---:|",
+				"| 64500 | 203.0.113.7 | 203.0.113.0/24 | PIPE\\|NET <b> | VPN | IVPN | · | · | · | 80 |",
+				"|\n\n_Partial results: interrupted_\n",
+			},
+		},
+		{
+			name:   "html",
+			format: MarkupHTML,
+			want: []string{
+				`<table class="ip2asn-results">`,
+				`<td align="right" class="bg-hi-red fg-black">64500</td>`,
+				`PIPE|NET &lt;b&gt;`,
+				`Partial results: interrupted</caption>`,
+			},
+		},
+		{
+			name:   "asciidoc",
+			format: MarkupAsciiDoc,
+			want: []string{
+				`[cols=">,<,<,<,^,<,<,<,<,^", options="header"]`,
+				"|===\n|ASN |IP |BGP Prefix",
+				"|64500 |203.0.113.7 |203.0.113.0/24 |PIPE\\|NET <b> |VPN |IVPN",
+				"NOTE: Partial results: interrupted",
+			},
+		},
+	}
+	for _, tt := range tests {
+		rendered := RenderMarkup(results, opts, tt.format)
+		for _, want := range tt.want {
+			if !strings.Contains(rendered, want) {
+				t.Fatalf("%s: expected %q in:\n%s", tt.name, want, rendered)
+			}
+		}
+	}
+}
+
+func TestRenderMarkupHTMLOnlyColorsRowsWithEnrichmentColumns(t *testing.T) {
+	rendered := RenderMarkup([]model.Result{fieldsTestResult()}, TableOptions{Mode: TableModeBasic}, MarkupHTML)
+	if strings.Contains(rendered, "bg-hi-red") {
+		t.Fatalf("expected no risk classes without proxycheck columns:\n%s", rendered)
+	}
+}
@@ -0,0 +1,255 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// mispNamespace is the UUIDv5 namespace of the MISP event UUIDs ip2asn
+// derives.
+const mispNamespace = "e2fe6be5-94c1-4378-850c-882cd2bfc2d7"
+
+// MISP attribute types for the looked-up IPs.
+const (
+	MISPIPSource      = "ip-src"
+	MISPIPDestination = "ip-dst"
+)
+
+// MISPOptions configures a MISP event.
+type MISPOptions struct {
+	// Info is the event title; it defaults to "ip2asn lookup of N IPs".
+	Info string
+	// IPType is MISPIPSource (the default) or MISPIPDestination.
+	IPType string
+}
+
+// MISPEvent is a MISP event in the JSON shape that MISP imports and its
+// /events/add API accepts.
+type MISPEvent struct {
+	Event MISPEventBody `json:"Event"`
+}
+
+// MISPEventBody holds the event's attributes and objects.
+type MISPEventBody struct {
+	UUID          string          `json:"uuid"`
+	Info          string          `json:"info"`
+	Date          string          `json:"date"`
+	Timestamp     string          `json:"timestamp"`
+	ThreatLevelID string          `json:"threat_level_id"`
+	Analysis      string          `json:"analysis"`
+	Distribution  string          `json:"distribution"`
+	Published     bool            `json:"published"`
+	Attribute     []MISPAttribute `json:"Attribute"`
+	Object        []MISPObject    `json:"Object"`
+}
+
+// MISPAttribute is an event or object attribute.
+type MISPAttribute struct {
+	UUID           string    `json:"uuid"`
+	Type           string    `json:"type"`
+	Category       string    `json:"category"`
+	ObjectRelation string    `json:"object_relation,omitempty"`
+	Value          string    `json:"value"`
+	ToIDS          bool      `json:"to_ids"`
+	Comment        string    `json:"comment,omitempty"`
+	Tag            []MISPTag `json:"Tag,omitempty"`
+}
+
+// MISPTag is an attribute tag.
+type MISPTag struct {
+	Name string `json:"name"`
+}
+
+// MISPObject is a MISP object such as "asn".
+type MISPObject struct {
+	UUID            string                `json:"uuid"`
+	Name            string                `json:"name"`
+	MetaCategory    string                `json:"meta-category"`
+	Comment         string                `json:"comment,omitempty"`
+	Attribute       []MISPAttribute       `json:"Attribute"`
+	ObjectReference []MISPObjectReference `json:"ObjectReference,omitempty"`
+}
+
+// MISPObjectReference links an object to an attribute or object.
+type MISPObjectReference struct {
+	UUID             string `json:"uuid"`
+	ObjectUUID       string `json:"object_uuid"`
+	ReferencedUUID   string `json:"referenced_uuid"`
+	RelationshipType string `json:"relationship_type"`
+}
+
+// NewMISPEvent builds an unpublished MISP event from results. Each IP is an
+// attribute of opts.IPType, commented with its AS and prefix. Each origin AS
+// is an "asn" object (asn, description, subnet-announced and country, from
+// the results it announces) that references those attributes with
+// "includes". With includeEnrichment, proxycheck.io detections become tags
+// on the IP attributes, such as proxycheck:vpn and proxycheck:tor, with the
+// risk level as proxycheck:risk="high". UUIDs derive from run.Generated and
+// the data, so writing the same run twice gives the same event.
+func NewMISPEvent(results []model.Result, includeEnrichment bool, run RunInfo, opts MISPOptions) MISPEvent {
+	ipType := opts.IPType
+	if ipType == "" {
+		ipType = MISPIPSource
+	}
+	info := opts.Info
+	if info == "" {
+		info = fmt.Sprintf("ip2asn lookup of %d IPs", len(results))
+	}
+	if run.Partial != "" {
+		info += " (partial: " + run.Partial + ")"
+	}
+	generated := run.Generated.UTC()
+	eventUUID := uuidV5(mispNamespace, "event|"+generated.Format(time.RFC3339Nano)+"|"+info)
+
+	event := MISPEventBody{
+		UUID:          eventUUID,
+		Info:          info,
+		Date:          generated.Format(time.DateOnly),
+		Timestamp:     strconv.FormatInt(generated.Unix(), 10),
+		ThreatLevelID: "4", // undefined
+		Analysis:      "0", // initial
+		Distribution:  "0", // your organisation only
+		Attribute:     []MISPAttribute{},
+		Object:        []MISPObject{},
+	}
+
+	var objects []*mispASNObject
+	byASN := make(map[model.ASN]*mispASNObject)
+	attributes := make(map[string]string)
+	for _, r := range results {
+		if !r.IP.IsValid() {
+			continue
+		}
+		value := r.IP.Unmap().String()
+		attrUUID, seen := attributes[value]
+		if !seen {
+			attrUUID = uuidV5(eventUUID, "attribute|"+ipType+"|"+value)
+			attributes[value] = attrUUID
+			attr := MISPAttribute{
+				UUID:     attrUUID,
+				Type:     ipType,
+				Category: "Network activity",
+				Value:    value,
+				Comment:  mispComment(r),
+			}
+			if includeEnrichment {
+				attr.Tag = mispTags(r.ProxyCheck)
+			}
+			event.Attribute = append(event.Attribute, attr)
+		}
+
+		for _, origin := range r.AllOrigins() {
+			if !origin.ASN.Known() {
+				continue
+			}
+			o, ok := byASN[origin.ASN]
+			if !ok {
+				o = &mispASNObject{object: MISPObject{
+					UUID:         uuidV5(eventUUID, "asn|"+origin.ASN.String()),
+					Name:         "asn",
+					MetaCategory: "network",
+				}}
+				o.object.Attribute = append(o.object.Attribute,
+					o.attribute("asn", "AS", "Network activity", origin.ASN.String()))
+				if origin.ASName != "" {
+					o.object.Attribute = append(o.object.Attribute,
+						o.attribute("description", "text", "Other", origin.ASName))
+				}
+				byASN[origin.ASN] = o
+				objects = append(objects, o)
+			}
+			if r.BGPPrefix.IsValid() && !slices.Contains(o.prefixes, r.BGPPrefix.String()) {
+				o.prefixes = append(o.prefixes, r.BGPPrefix.String())
+			}
+			if r.CC != "" && !slices.Contains(o.countries, r.CC) {
+				o.countries = append(o.countries, r.CC)
+			}
+			refUUID := uuidV5(eventUUID, "reference|"+o.object.UUID+"|"+attrUUID)
+			if !slices.ContainsFunc(o.object.ObjectReference, func(ref MISPObjectReference) bool { return ref.UUID == refUUID }) {
+				o.object.ObjectReference = append(o.object.ObjectReference, MISPObjectReference{
+					UUID:             refUUID,
+					ObjectUUID:       o.object.UUID,
+					ReferencedUUID:   attrUUID,
+					RelationshipType: "includes",
+				})
+			}
+		}
+	}
+	for _, o := range objects {
+		for _, prefix := range o.prefixes {
+			o.object.Attribute = append(o.object.Attribute,
+				o.attribute("subnet-announced", "ip-src", "Network activity", prefix))
+		}
+		for _, country := range o.countries {
+			o.object.Attribute = append(o.object.Attribute,
+				o.attribute("country", "text", "Other", country))
+		}
+		event.Object = append(event.Object, o.object)
+	}
+	return MISPEvent{Event: event}
+}
+
+// mispASNObject collects an asn object and the prefixes and countries of
+// the results it announces.
+type mispASNObject struct {
+	object    MISPObject
+	prefixes  []string
+	countries []string
+}
+
+// attribute returns an attribute of the asn object.
+func (o *mispASNObject) attribute(relation, attrType, category, value string) MISPAttribute {
+	return MISPAttribute{
+		UUID:           uuidV5(o.object.UUID, relation+"|"+value),
+		Type:           attrType,
+		Category:       category,
+		ObjectRelation: relation,
+		Value:          value,
+	}
+}
+
+// WriteMISP writes NewMISPEvent's event as indented JSON.
+func WriteMISP(w io.Writer, results []model.Result, includeEnrichment bool, run RunInfo, opts MISPOptions) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewMISPEvent(results, includeEnrichment, run, opts))
+}
+
+// mispComment describes the AS and prefix of r, such as
+// "AS64500 TEST-NET (203.0.113.0/24)". Unknown origins are left out, so an
+// unannounced IP has no comment.
+func mispComment(r model.Result) string {
+	var origins []string
+	for _, origin := range r.AllOrigins() {
+		if origin.ASN.Known() {
+			origins = append(origins, strings.TrimSpace("AS"+origin.ASN.String()+" "+origin.ASName))
+		}
+	}
+	comment := strings.Join(origins, "; ")
+	if r.BGPPrefix.IsValid() {
+		comment = strings.TrimSpace(comment + " (" + r.BGPPrefix.String() + ")")
+	}
+	return comment
+}
+
+// mispTags returns the proxycheck.io tags of an attribute.
+func mispTags(pc *model.ProxyCheck) []MISPTag {
+	var tags []MISPTag
+	for _, label := range statusList(pc) {
+		tags = append(tags, MISPTag{Name: "proxycheck:" + detectionNames[label]})
+	}
+	if level := RiskLevel(pc); level != "" {
+		tags = append(tags, MISPTag{Name: `proxycheck:risk="` + level + `"`})
+	}
+	if pc != nil && pc.VPNProvider != "" {
+		tags = append(tags, MISPTag{Name: `proxycheck:vpn-provider="` + pc.VPNProvider + `"`})
+	}
+	return tags
+}
@@ -0,0 +1,104 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestNewMISPEvent(t *testing.T) {
+	run := RunInfo{Version: "9.9", Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Enriched: true}
+	event := NewMISPEvent(reportTestResults(), true, run, MISPOptions{IPType: MISPIPDestination}).Event
+
+	if event.Info != "ip2asn lookup of 3 IPs" || event.Date != "2026-10-18" || event.Published {
+		t.Fatalf("unexpected event header %+v", event)
+	}
+	if len(event.Attribute) != 3 {
+		t.Fatalf("expected 3 IP attributes, got %d", len(event.Attribute))
+	}
+	first := event.Attribute[0]
+	if first.Type != "ip-dst" || first.Value != "203.0.113.7" || first.Comment != "AS64500 TEST-NET <script> (203.0.113.0/24)" {
+		t.Fatalf("unexpected attribute %+v", first)
+	}
+	var tags []string
+	for _, tag := range first.Tag {
+		tags = append(tags, tag.Name)
+	}
+	if len(tags) != 3 || tags[0] != "proxycheck:vpn" || tags[1] != `proxycheck:risk="high"` || tags[2] != `proxycheck:vpn-provider="IVPN"` {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	// AS64500 announces both IPv4 results; the MOAS prefix adds AS64501
+	// and AS64502
+	if len(event.Object) != 3 {
+		t.Fatalf("expected 3 asn objects, got %d", len(event.Object))
+	}
+	as := event.Object[0]
+	values := make(map[string]string)
+	for _, attr := range as.Attribute {
+		values[attr.ObjectRelation] = attr.Value
+	}
+	if as.Name != "asn" || values["asn"] != "64500" || values["description"] != "TEST-NET <script>" ||
+		values["subnet-announced"] != "203.0.113.0/24" || values["country"] != "US" {
+		t.Fatalf("unexpected asn object %+v", as)
+	}
+	if len(as.ObjectReference) != 2 || as.ObjectReference[0].ReferencedUUID != first.UUID || as.ObjectReference[0].RelationshipType != "includes" {
+		t.Fatalf("unexpected references %+v", as.ObjectReference)
+	}
+	moas := event.Attribute[2].UUID
+	for _, object := range event.Object[1:] {
+		if len(object.ObjectReference) != 1 || object.ObjectReference[0].ReferencedUUID != moas {
+			t.Fatalf("expected the MOAS IP under every origin, got %+v", object.ObjectReference)
+		}
+	}
+
+	again := NewMISPEvent(reportTestResults(), true, run, MISPOptions{IPType: MISPIPDestination}).Event
+	if again.UUID != event.UUID || again.Attribute[0].UUID != first.UUID {
+		t.Fatalf("expected the same UUIDs for the same run")
+	}
+	later := run
+	later.Generated = run.Generated.Add(time.Hour)
+	if NewMISPEvent(reportTestResults(), true, later, MISPOptions{}).Event.UUID == event.UUID {
+		t.Fatalf("expected a new event UUID for another run")
+	}
+}
+
+func TestWriteMISPPartialWithoutEnrichment(t *testing.T) {
+	var b bytes.Buffer
+	run := RunInfo{Generated: time.Now(), Partial: "interrupted"}
+	if err := WriteMISP(&b, reportTestResults(), false, run, MISPOptions{Info: "Incident 42"}); err != nil {
+		t.Fatalf("WriteMISP: %v", err)
+	}
+	var decoded MISPEvent
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid event JSON: %v", err)
+	}
+	if decoded.Event.Info != "Incident 42 (partial: interrupted)" {
+		t.Fatalf("unexpected info %q", decoded.Event.Info)
+	}
+	for _, attr := range decoded.Event.Attribute {
+		if attr.Type != "ip-src" || len(attr.Tag) != 0 {
+			t.Fatalf("expected untagged ip-src attributes, got %+v", attr)
+		}
+	}
+}
+
+func TestMISPCommentOmitsUnknownASN(t *testing.T) {
+	tests := []struct {
+		result model.Result
+		want   string
+	}{
+		{model.Result{ASN: model.ASNUnknown, IP: netip.MustParseAddr("192.0.2.1")}, ""},
+		{model.Result{ASN: model.ASNUnknown, IP: netip.MustParseAddr("192.0.2.1"), BGPPrefix: netip.MustParsePrefix("192.0.2.0/24")}, "(192.0.2.0/24)"},
+		{model.Result{ASN: 64500, IP: netip.MustParseAddr("192.0.2.1")}, "AS64500"},
+	}
+	for _, tt := range tests {
+		if got := mispComment(tt.result); got != tt.want {
+			t.Fatalf("mispComment(%+v) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// FlatResult is one result as a single flat JSON object, as written by
+// NDJSONWriter. Enrichment fields are omitted when there is no data.
+type FlatResult struct {
+	IP        netip.Addr   `json:"ip"`
+	ASN       model.ASN    `json:"asn"`
+	ASName    string       `json:"as_name"`
+	BGPPrefix string       `json:"bgp_prefix"` // "NA" when unannounced
+	CC        string       `json:"cc"`
+	Registry  string       `json:"registry"`
+	Allocated model.Date   `json:"allocated"`
+	Method    model.Method `json:"method"`
+	Retrieved time.Time    `json:"retrieved"`
+	MOAS      bool         `json:"moas,omitempty"`
+	Origins   []model.ASN  `json:"origins,omitempty"`
+
+	Proxy       *bool  `json:"proxy,omitempty"`
+	VPN         *bool  `json:"vpn,omitempty"`
+	Compromised *bool  `json:"compromised,omitempty"`
+	Hosting     *bool  `json:"hosting,omitempty"`
+	TOR         *bool  `json:"tor,omitempty"`
+	Risk        *int   `json:"risk,omitempty"`
+	VPNProvider string `json:"vpn_provider,omitempty"`
+	City        string `json:"city,omitempty"`
+	State       string `json:"state,omitempty"`
+	Country     string `json:"country,omitempty"`
+	// Status lists the detections as in the table's Status column, e.g.
+	// ["VPN", "HST"].
+	Status []string `json:"status,omitempty"`
+}
+
+// FlattenResult converts r to a FlatResult. MOAS results keep their first
+// origin in ASN and list every origin in Origins.
+func FlattenResult(r model.Result, includeEnrichment bool) FlatResult {
+	flat := FlatResult{
+		IP:        r.IP,
+		ASN:       r.ASN,
+		ASName:    r.ASName,
+		BGPPrefix: legacyPrefix(r),
+		CC:        r.CC,
+		Registry:  r.Registry,
+		Allocated: r.Allocated,
+		Method:    r.Method,
+		Retrieved: r.Retrieved,
+	}
+	if r.MOAS {
+		flat.MOAS = true
+		flat.Origins = r.OriginASNs()
+	}
+	if pc := r.ProxyCheck; includeEnrichment && pc != nil && !pc.IsEmpty() {
+		flat.Proxy = pc.Proxy
+		flat.VPN = pc.VPN
+		flat.Compromised = pc.Compromised
+		flat.Hosting = pc.Hosting
+		flat.TOR = pc.TOR
+		flat.Risk = pc.Risk
+		flat.VPNProvider = pc.VPNProvider
+		flat.City = pc.City
+		flat.State = pc.State
+		flat.Country = pc.Country
+		flat.Status = statusList(pc)
+	}
+	return flat
+}
+
+// NDJSONWriter writes results as newline-delimited JSON, one flat object per
+// line, so consumers can process them as they arrive.
+type NDJSONWriter struct {
+	w                 io.Writer
+	enc               *json.Encoder
+	includeEnrichment bool
+	fields            []Field
+	schema            string
+	bulkAction        []byte
+	now               func() time.Time
+}
+
+// NewNDJSONWriter returns a writer to w. Each Write reaches w in a single
+// call, so lines are not held back by buffering.
+func NewNDJSONWriter(w io.Writer, includeEnrichment bool) *NDJSONWriter {
+	return &NDJSONWriter{w: w, enc: json.NewEncoder(w), includeEnrichment: includeEnrichment, now: time.Now}
+}
+
+// SetSchema writes SchemaECS documents or SchemaCIM events instead of flat
+// objects; SchemaFlat, the default, keeps FlatResult and SetFields.
+func (nw *NDJSONWriter) SetSchema(schema string) {
+	nw.schema = schema
+}
+
+// SetBulkIndex writes an Elasticsearch _bulk request body: each document
+// follows a {"create":{"_index":index}} action line, which works for both
+// indices and data streams.
+func (nw *NDJSONWriter) SetBulkIndex(index string) {
+	action, _ := json.Marshal(map[string]map[string]string{"create": {"_index": index}})
+	nw.bulkAction = append(action, '\n')
+}
+
+// SetFields limits each object to fields, in their order. Fields without
+// data are still omitted.
+func (nw *NDJSONWriter) SetFields(fields []Field) {
+	nw.fields = fields
+}
+
+// Write encodes r as one line, after its action line for _bulk.
+func (nw *NDJSONWriter) Write(r model.Result) error {
+	if nw.bulkAction != nil {
+		if _, err := nw.w.Write(nw.bulkAction); err != nil {
+			return err
+		}
+	}
+	switch nw.schema {
+	case SchemaECS:
+		return nw.enc.Encode(NewECSDocument(r, nw.includeEnrichment, nw.now()))
+	case SchemaCIM:
+		return nw.enc.Encode(NewCIMEvent(r, nw.includeEnrichment, nw.now()))
+	}
+	flat := FlattenResult(r, nw.includeEnrichment)
+	if len(nw.fields) == 0 {
+		return nw.enc.Encode(flat)
+	}
+	line, err := marshalFields(flat, nw.fields)
+	if err != nil {
+		return err
+	}
+	_, err = nw.w.Write(append(line, '\n'))
+	return err
+}
+
+// marshalFields encodes the fields of flat as one JSON object, keeping their
+// order.
+func marshalFields(flat FlatResult, fields []Field) ([]byte, error) {
+	buf := []byte{'{'}
+	for _, field := range fields {
+		value := field.json(flat)
+		if value == nil {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendQuote(buf, field.JSONKey())
+		buf = append(buf, ':')
+		buf = append(buf, encoded...)
+	}
+	return append(buf, '}'), nil
+}
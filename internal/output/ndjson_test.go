@@ -0,0 +1,86 @@
+package output
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestNDJSONWriterWritesOneFlatObjectPerLine(t *testing.T) {
+	ts := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)
+	trueValue := true
+	riskValue := 66
+	results := []model.Result{
+		{
+			ASN:       13335,
+			IP:        netip.MustParseAddr("1.1.1.1"),
+			BGPPrefix: netip.MustParsePrefix("1.1.1.0/24"),
+			CC:        "AU",
+			Registry:  "apnic",
+			Allocated: model.MustParseDate("2011-01-01"),
+			ASName:    "CLOUDFLARENET",
+			Method:    model.MethodDNS,
+			Retrieved: ts,
+			ProxyCheck: &model.ProxyCheck{
+				VPN:         &trueValue,
+				Hosting:     &trueValue,
+				Risk:        &riskValue,
+				VPNProvider: "IVPN",
+			},
+		},
+		{
+			ASN:       64500,
+			IP:        netip.MustParseAddr("192.0.2.1"),
+			BGPPrefix: netip.MustParsePrefix("192.0.2.0/24"),
+			CC:        "US",
+			Registry:  "arin",
+			ASName:    "EXAMPLE",
+			Method:    model.MethodWhois,
+			Retrieved: ts,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		enrich bool
+		want   []string
+	}{
+		{
+			name:   "with enrichment",
+			enrich: true,
+			want: []string{
+				`{"ip":"1.1.1.1","asn":13335,"as_name":"CLOUDFLARENET","bgp_prefix":"1.1.1.0/24","cc":"AU","registry":"apnic","allocated":"2011-01-01","method":"dns","retrieved":"2024-03-14T15:09:26Z","vpn":true,"hosting":true,"risk":66,"vpn_provider":"IVPN","status":["VPN","HST"]}`,
+				`{"ip":"192.0.2.1","asn":64500,"as_name":"EXAMPLE","bgp_prefix":"192.0.2.0/24","cc":"US","registry":"arin","allocated":"","method":"whois","retrieved":"2024-03-14T15:09:26Z"}`,
+			},
+		},
+		{
+			name: "without enrichment",
+			want: []string{
+				`{"ip":"1.1.1.1","asn":13335,"as_name":"CLOUDFLARENET","bgp_prefix":"1.1.1.0/24","cc":"AU","registry":"apnic","allocated":"2011-01-01","method":"dns","retrieved":"2024-03-14T15:09:26Z"}`,
+				`{"ip":"192.0.2.1","asn":64500,"as_name":"EXAMPLE","bgp_prefix":"192.0.2.0/24","cc":"US","registry":"arin","allocated":"","method":"whois","retrieved":"2024-03-14T15:09:26Z"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewNDJSONWriter(&buf, tt.enrich)
+		for _, r := range results {
+			if err := w.Write(r); err != nil {
+				t.Fatalf("%s: Write: %v", tt.name, err)
+			}
+		}
+		got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: expected %d lines, got %q", tt.name, len(tt.want), buf.String())
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%s: line %d:\n got %s\nwant %s", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
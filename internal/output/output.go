@@ -12,7 +12,7 @@ import (
 	"github.com/jedib0t/go-pretty/v6/text"
 	"golang.org/x/term"
 
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
 )
 
 // TableMode controls which table schema is rendered.
@@ -26,8 +26,14 @@ const (
 
 // TableOptions controls how table output is rendered.
 type TableOptions struct {
-	Mode            TableMode
+	Mode TableMode
+	// Fields, when set, selects and orders the columns instead of Mode.
+	// Rows are colored by risk when any proxycheck.io field is shown.
+	Fields          []Field
 	EnrichmentError string
+	// Partial, when set, is shown as a footer explaining why rows may be
+	// missing, such as an interrupted lookup.
+	Partial string
 }
 
 // RenderTable renders the current table output for a target width.
@@ -35,7 +41,8 @@ func RenderTable(results []model.Result, opts TableOptions, width int, enableCol
 	restoreTextColors := configureTextColors(enableColor)
 	defer restoreTextColors()
 
-	layout := newTableLayout(results, opts.Mode, enableColor, width)
+	fields := tableFields(opts)
+	layout := newTableLayout(results, fields, enableColor, width)
 	tw := table.NewWriter()
 	tw.SetStyle(tableStyle(enableColor))
 	tw.Style().Box.UnfinishedRow = "…"
@@ -45,7 +52,7 @@ func RenderTable(results []model.Result, opts TableOptions, width int, enableCol
 	tw.SetColumnConfigs(layout.columnConfigs())
 	tw.Style().Options.SeparateRows = false
 	tw.SuppressTrailingSpaces()
-	if opts.Mode == TableModeProxycheck && enableColor {
+	if HasEnrichment(fields) && enableColor {
 		tw.SetRowPainter(layout.rowPainter())
 	}
 
@@ -58,6 +65,9 @@ func RenderTable(results []model.Result, opts TableOptions, width int, enableCol
 	if opts.EnrichmentError != "" {
 		rendered += "\n" + coloredLine("Proxycheck enrichment failed: "+opts.EnrichmentError, enableColor, text.Colors{text.Bold, text.FgRed})
 	}
+	if opts.Partial != "" {
+		rendered += "\n" + coloredLine("Partial results: "+opts.Partial, enableColor, text.Colors{text.Bold, text.FgYellow})
+	}
 	return rendered
 }
 
@@ -66,37 +76,31 @@ func PrintTable(w io.Writer, results []model.Result, opts TableOptions) {
 	fmt.Fprint(w, RenderTable(results, opts, terminalWidth(w), ColorEnabled(w)))
 }
 
-// WriteCSV writes CSV header + records using the provided writer.
-func WriteCSV(w *csv.Writer, results []model.Result, includeEnrichment bool) {
-	header := []string{"AS", "IP", "BGP Prefix", "CC", "Registry", "Allocated", "AS Name"}
-	if includeEnrichment {
-		header = append(header, "Proxy", "VPN", "Compromised", "Hosting", "TOR", "Risk", "VPN Provider", "City", "State", "Country")
+// WriteCSV writes CSV header + records using the provided writer. partial
+// adds a trailing "Partial" column set to true on every record, for results
+// of a lookup that did not finish.
+func WriteCSV(w *csv.Writer, results []model.Result, includeEnrichment, partial bool) {
+	WriteCSVFields(w, results, csvFields(includeEnrichment), partial)
+}
+
+// WriteCSVFields is WriteCSV with the columns given by fields.
+func WriteCSVFields(w *csv.Writer, results []model.Result, fields []Field, partial bool) {
+	header := make([]string, 0, len(fields)+1)
+	for _, field := range fields {
+		header = append(header, field.csvHeader())
+	}
+	if partial {
+		header = append(header, "Partial")
 	}
 	_ = w.Write(header)
 
 	for _, result := range results {
-		row := []string{
-			strconv.Itoa(result.ASN),
-			result.IP,
-			result.BGPPrefix,
-			result.CC,
-			result.Registry,
-			result.Allocated,
-			result.ASName,
+		row := make([]string, 0, len(header))
+		for _, field := range fields {
+			row = append(row, field.csv(result))
 		}
-		if includeEnrichment {
-			row = append(row,
-				boolCell(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) *bool { return proxyCheck.Proxy }),
-				boolCell(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) *bool { return proxyCheck.VPN }),
-				boolCell(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) *bool { return proxyCheck.Compromised }),
-				boolCell(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) *bool { return proxyCheck.Hosting }),
-				boolCell(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) *bool { return proxyCheck.TOR }),
-				riskCSVCell(result.ProxyCheck),
-				enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.VPNProvider }),
-				enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.City }),
-				enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.State }),
-				enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.Country }),
-			)
+		if partial {
+			row = append(row, "true")
 		}
 		_ = w.Write(row)
 	}
@@ -157,12 +161,7 @@ func statusLabels(proxyCheck *model.ProxyCheck) string {
 		return placeholder(false)
 	}
 
-	labels := make([]string, 0, 5)
-	labels = appendStatusLabel(labels, proxyCheck.VPN, "VPN")
-	labels = appendStatusLabel(labels, proxyCheck.Proxy, "PXY")
-	labels = appendStatusLabel(labels, proxyCheck.Compromised, "CMP")
-	labels = appendStatusLabel(labels, proxyCheck.TOR, "TOR")
-	labels = appendStatusLabel(labels, proxyCheck.Hosting, "HST")
+	labels := statusList(proxyCheck)
 	if len(labels) == 0 {
 		if hasStatusData(proxyCheck) {
 			return ""
@@ -172,6 +171,29 @@ func statusLabels(proxyCheck *model.ProxyCheck) string {
 	return strings.Join(labels, " ")
 }
 
+// statusList returns the short labels of the detections set on proxyCheck.
+func statusList(proxyCheck *model.ProxyCheck) []string {
+	if proxyCheck == nil {
+		return nil
+	}
+	labels := make([]string, 0, 5)
+	labels = appendStatusLabel(labels, proxyCheck.VPN, "VPN")
+	labels = appendStatusLabel(labels, proxyCheck.Proxy, "PXY")
+	labels = appendStatusLabel(labels, proxyCheck.Compromised, "CMP")
+	labels = appendStatusLabel(labels, proxyCheck.TOR, "TOR")
+	labels = appendStatusLabel(labels, proxyCheck.Hosting, "HST")
+	return labels
+}
+
+// detectionNames maps the statusList labels to lowercase names for tags.
+var detectionNames = map[string]string{
+	"VPN": "vpn",
+	"PXY": "proxy",
+	"CMP": "compromised",
+	"TOR": "tor",
+	"HST": "hosting",
+}
+
 func appendStatusLabel(labels []string, value *bool, label string) []string {
 	if value == nil || !*value {
 		return labels
@@ -179,6 +201,34 @@ func appendStatusLabel(labels []string, value *bool, label string) []string {
 	return append(labels, label)
 }
 
+// asnCell renders the origin ASN(s) of a result using format; MOAS prefixes
+// list every origin separated by spaces, as Team Cymru does.
+func asnCell(result model.Result, format func(model.ASN) string) string {
+	if !result.MOAS {
+		return format(result.ASN)
+	}
+	asns := result.OriginASNs()
+	parts := make([]string, 0, len(asns))
+	for _, asn := range asns {
+		parts = append(parts, format(asn))
+	}
+	return strings.Join(parts, " ")
+}
+
+// legacyASN formats an ASN the way CSV output always has, with -1 for unknown.
+func legacyASN(asn model.ASN) string {
+	return strconv.Itoa(asn.Int())
+}
+
+// legacyPrefix formats the BGP prefix the way CSV and JSON output always
+// have, with Team Cymru's "NA" for unannounced space.
+func legacyPrefix(r model.Result) string {
+	if !r.BGPPrefix.IsValid() {
+		return "NA"
+	}
+	return r.BGPPrefix.String()
+}
+
 func riskCell(proxyCheck *model.ProxyCheck, enableColor bool) string {
 	if proxyCheck == nil || proxyCheck.Risk == nil {
 		return placeholder(enableColor)
@@ -237,17 +287,35 @@ func coloredLine(line string, enableColor bool, colors text.Colors) string {
 }
 
 func riskRowColors(proxyCheck *model.ProxyCheck) text.Colors {
-	if proxyCheck == nil || proxyCheck.Risk == nil {
+	switch RiskLevel(proxyCheck) {
+	case RiskHigh:
+		return text.Colors{text.BgHiRed, text.FgBlack}
+	case RiskMedium:
+		return text.Colors{text.BgHiYellow, text.FgBlack}
+	default:
 		return nil
 	}
+}
 
+// Risk levels group proxycheck.io risk scores the way table rows are colored.
+const (
+	RiskHigh   = "high"   // 75 and above
+	RiskMedium = "medium" // 50 to 74
+	RiskLow    = "low"    // below 50
+)
+
+// RiskLevel returns the risk level of proxyCheck, or "" without a score.
+func RiskLevel(proxyCheck *model.ProxyCheck) string {
+	if proxyCheck == nil || proxyCheck.Risk == nil {
+		return ""
+	}
 	switch {
 	case *proxyCheck.Risk >= 75:
-		return text.Colors{text.BgHiRed, text.FgBlack}
+		return RiskHigh
 	case *proxyCheck.Risk >= 50:
-		return text.Colors{text.BgHiYellow, text.FgBlack}
+		return RiskMedium
 	default:
-		return nil
+		return RiskLow
 	}
 }
 
@@ -296,17 +364,29 @@ type tableColumn struct {
 	shrinkFirst bool
 }
 
-func newTableLayout(results []model.Result, mode TableMode, enableColor bool, width int) tableLayout {
-	var columns []tableColumn
-	var rows []table.Row
-	var rowColors []text.Colors
+func newTableLayout(results []model.Result, fields []Field, enableColor bool, width int) tableLayout {
+	columns := make([]tableColumn, 0, len(fields))
+	for _, field := range fields {
+		columns = append(columns, tableColumn{
+			name:        field.Header,
+			align:       field.Align,
+			min:         field.MinWidth,
+			grow:        field.grow,
+			preferExtra: field.preferExtra,
+			shrinkFirst: field.shrinkFirst,
+		})
+	}
 
-	if mode == TableModeProxycheck {
-		columns = buildProxycheckColumns(results)
-		rows, rowColors = buildProxycheckRows(results, enableColor)
-	} else {
-		columns = buildBasicColumns(results)
-		rows = buildBasicRows(results)
+	rows := make([]table.Row, 0, len(results))
+	rowColors := make([]text.Colors, 0, len(results))
+	for _, result := range results {
+		row := make(table.Row, 0, len(fields))
+		for idx, field := range fields {
+			recordNatural(&columns[idx], field.table(result, false))
+			row = append(row, field.table(result, enableColor))
+		}
+		rows = append(rows, row)
+		rowColors = append(rowColors, riskRowColors(result.ProxyCheck))
 	}
 
 	layout := tableLayout{
@@ -319,95 +399,6 @@ func newTableLayout(results []model.Result, mode TableMode, enableColor bool, wi
 	return layout
 }
 
-func buildProxycheckColumns(results []model.Result) []tableColumn {
-	columns := []tableColumn{
-		{name: "ASN", align: text.AlignRight, min: 3, grow: false},
-		{name: "IP", min: 7, grow: true},
-		{name: "BGP Prefix", min: 10, grow: true},
-		{name: "AS Name", min: 12, grow: true, preferExtra: true, shrinkFirst: true},
-		{name: "Status", align: text.AlignCenter, min: 6, grow: false},
-		{name: "VPN Provider", min: 8, grow: true},
-		{name: "City", min: 6, grow: true},
-		{name: "State", min: 6, grow: true},
-		{name: "Country", min: 7, grow: true},
-		{name: "Risk", align: text.AlignCenter, min: 4, grow: false},
-	}
-
-	for _, result := range results {
-		recordNatural(&columns[0], strconv.Itoa(result.ASN))
-		recordNatural(&columns[1], result.IP)
-		recordNatural(&columns[2], result.BGPPrefix)
-		recordNatural(&columns[3], valueOrDash(result.ASName))
-		recordNatural(&columns[4], statusLabels(result.ProxyCheck))
-		recordNatural(&columns[5], tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.VPNProvider }), false))
-		recordNatural(&columns[6], tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.City }), false))
-		recordNatural(&columns[7], tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.State }), false))
-		recordNatural(&columns[8], tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.Country }), false))
-		recordNatural(&columns[9], riskCell(result.ProxyCheck, false))
-	}
-	return columns
-}
-
-func buildBasicColumns(results []model.Result) []tableColumn {
-	columns := []tableColumn{
-		{name: "ASN", align: text.AlignRight, min: 3, grow: false},
-		{name: "IP", min: 7, grow: true},
-		{name: "BGP Prefix", min: 10, grow: true},
-		{name: "CC", align: text.AlignCenter, min: 2, grow: false},
-		{name: "Registry", min: 8, grow: true},
-		{name: "Allocated", align: text.AlignCenter, min: 10, grow: false},
-		{name: "AS Name", min: 12, grow: true, preferExtra: true, shrinkFirst: true},
-	}
-
-	for _, result := range results {
-		recordNatural(&columns[0], strconv.Itoa(result.ASN))
-		recordNatural(&columns[1], result.IP)
-		recordNatural(&columns[2], result.BGPPrefix)
-		recordNatural(&columns[3], result.CC)
-		recordNatural(&columns[4], result.Registry)
-		recordNatural(&columns[5], result.Allocated)
-		recordNatural(&columns[6], valueOrDash(result.ASName))
-	}
-	return columns
-}
-
-func buildProxycheckRows(results []model.Result, enableColor bool) ([]table.Row, []text.Colors) {
-	rows := make([]table.Row, 0, len(results))
-	rowColors := make([]text.Colors, 0, len(results))
-	for _, result := range results {
-		rows = append(rows, table.Row{
-			strconv.Itoa(result.ASN),
-			result.IP,
-			result.BGPPrefix,
-			valueOrDash(result.ASName),
-			statusLabels(result.ProxyCheck),
-			tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.VPNProvider }), enableColor),
-			tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.City }), enableColor),
-			tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.State }), enableColor),
-			tableValue(enrichmentString(result.ProxyCheck, func(proxyCheck *model.ProxyCheck) string { return proxyCheck.Country }), enableColor),
-			riskCell(result.ProxyCheck, enableColor),
-		})
-		rowColors = append(rowColors, riskRowColors(result.ProxyCheck))
-	}
-	return rows, rowColors
-}
-
-func buildBasicRows(results []model.Result) []table.Row {
-	rows := make([]table.Row, 0, len(results))
-	for _, result := range results {
-		rows = append(rows, table.Row{
-			strconv.Itoa(result.ASN),
-			result.IP,
-			result.BGPPrefix,
-			result.CC,
-			result.Registry,
-			result.Allocated,
-			valueOrDash(result.ASName),
-		})
-	}
-	return rows
-}
-
 func (layout *tableLayout) assignWidths() {
 	for idx := range layout.columns {
 		if layout.columns[idx].natural == 0 {
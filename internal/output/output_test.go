@@ -3,12 +3,15 @@ package output
 import (
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
+	"net/netip"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/text"
 
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
 )
 
 func TestWriteCSVWithEnrichment(t *testing.T) {
@@ -18,11 +21,11 @@ func TestWriteCSVWithEnrichment(t *testing.T) {
 	results := []model.Result{
 		{
 			ASN:       64500,
-			IP:        "203.0.113.7",
-			BGPPrefix: "203.0.113.0/24",
+			IP:        netip.MustParseAddr("203.0.113.7"),
+			BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "2020-01-01",
+			Allocated: model.MustParseDate("2020-01-01"),
 			ASName:    "TEST-NET",
 			ProxyCheck: &model.ProxyCheck{
 				Proxy:       &falseValue,
@@ -41,7 +44,7 @@ func TestWriteCSVWithEnrichment(t *testing.T) {
 
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
-	WriteCSV(writer, results, true)
+	WriteCSV(writer, results, true, false)
 	writer.Flush()
 
 	output := buf.String()
@@ -59,8 +62,8 @@ func TestPrintTableWithFooterError(t *testing.T) {
 	results := []model.Result{
 		{
 			ASN:       64501,
-			IP:        "198.51.100.9",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.9"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "TEST-NET-2",
 			ProxyCheck: &model.ProxyCheck{
 				VPN:         &trueValue,
@@ -99,11 +102,11 @@ func TestPrintTableProxycheckModeKeepsSchemaOnLookupFailure(t *testing.T) {
 	results := []model.Result{
 		{
 			ASN:       64502,
-			IP:        "198.51.100.10",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.10"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "2020-01-01",
+			Allocated: model.MustParseDate("2020-01-01"),
 			ASName:    "TEST-NET-3",
 		},
 	}
@@ -149,8 +152,8 @@ func TestPrintTableProxycheckModeDoesNotRenderSCR(t *testing.T) {
 	results := []model.Result{
 		{
 			ASN:       64503,
-			IP:        "198.51.100.13",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.13"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "TEST-NET-4",
 			ProxyCheck: &model.ProxyCheck{
 				VPN:         &falseValue,
@@ -177,11 +180,11 @@ func TestPrintTableKeepsCompactWidthWhenContentFits(t *testing.T) {
 	results := []model.Result{
 		{
 			ASN:       64510,
-			IP:        "198.51.100.11",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.11"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "2020-01-01",
+			Allocated: model.MustParseDate("2020-01-01"),
 			ASName:    "TEST-NET",
 		},
 	}
@@ -204,8 +207,8 @@ func TestPrintTableAvoidsTruncationWhenWidthAllows(t *testing.T) {
 	results := []model.Result{
 		{
 			ASN:       64511,
-			IP:        "198.51.100.12",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.12"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "A-VERY-LONG-AS-NAME-THAT-SHOULD-REMAIN-FULLY-VISIBLE-WHEN-THERE-IS-ENOUGH-WIDTH",
 			ProxyCheck: &model.ProxyCheck{
 				City:    "Salt Lake City",
@@ -236,11 +239,11 @@ func TestRenderTableTruncatesASNameBeforeOtherColumns(t *testing.T) {
 	rendered := RenderTable([]model.Result{
 		{
 			ASN:       64512,
-			IP:        "198.51.100.123",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.123"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			CC:        "US",
 			Registry:  "arin",
-			Allocated: "2020-01-01",
+			Allocated: model.MustParseDate("2020-01-01"),
 			ASName:    asName,
 		},
 	}, TableOptions{}, 95, false)
@@ -269,8 +272,8 @@ func TestRenderTableHighlightsRiskRowsInProxycheckMode(t *testing.T) {
 	rendered := RenderTable([]model.Result{
 		{
 			ASN:       64520,
-			IP:        "198.51.100.20",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.20"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "MEDIUM-RISK",
 			ProxyCheck: &model.ProxyCheck{
 				Risk: &mediumRisk,
@@ -278,8 +281,8 @@ func TestRenderTableHighlightsRiskRowsInProxycheckMode(t *testing.T) {
 		},
 		{
 			ASN:       64521,
-			IP:        "198.51.100.21",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.21"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "HIGH-RISK",
 			ProxyCheck: &model.ProxyCheck{
 				Risk: &highRisk,
@@ -308,8 +311,8 @@ func TestRenderTableOnlyHighlightsYellowAtRisk50AndAbove(t *testing.T) {
 	rendered := RenderTable([]model.Result{
 		{
 			ASN:       64523,
-			IP:        "198.51.100.23",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.23"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "BELOW-YELLOW",
 			ProxyCheck: &model.ProxyCheck{
 				Risk: &justBelowYellow,
@@ -317,8 +320,8 @@ func TestRenderTableOnlyHighlightsYellowAtRisk50AndAbove(t *testing.T) {
 		},
 		{
 			ASN:       64524,
-			IP:        "198.51.100.24",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.24"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "AT-YELLOW",
 			ProxyCheck: &model.ProxyCheck{
 				Risk: &atYellow,
@@ -343,8 +346,8 @@ func TestRenderTableDoesNotHighlightRiskRowsOutsideProxycheckMode(t *testing.T)
 	result := []model.Result{
 		{
 			ASN:       64522,
-			IP:        "198.51.100.22",
-			BGPPrefix: "198.51.100.0/24",
+			IP:        netip.MustParseAddr("198.51.100.22"),
+			BGPPrefix: netip.MustParsePrefix("198.51.100.0/24"),
 			ASName:    "NO-HIGHLIGHT",
 			ProxyCheck: &model.ProxyCheck{
 				Risk: &highRisk,
@@ -370,3 +373,76 @@ func firstRenderedLineWidth(rendered string) int {
 	}
 	return text.StringWidthWithoutEscSequences(lines[0])
 }
+
+func TestWriteCSVListsAllMOASOrigins(t *testing.T) {
+	results := []model.Result{
+		{
+			ASN:       64500,
+			IP:        netip.MustParseAddr("203.0.113.7"),
+			BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"),
+			ASName:    "FIRST-NET",
+			MOAS:      true,
+			Origins:   []model.Origin{{ASN: 64500}, {ASN: 64502}},
+		},
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	WriteCSV(writer, results, false, false)
+	writer.Flush()
+
+	if !strings.Contains(buf.String(), "64500 64502,203.0.113.7,") {
+		t.Fatalf("expected all origin ASNs in one CSV row, got %q", buf.String())
+	}
+}
+
+func TestPartialResultsAreMarked(t *testing.T) {
+	results := []model.Result{{ASN: 64500, IP: netip.MustParseAddr("192.0.2.1"), ASName: "EXAMPLE"}}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	WriteCSV(writer, results, false, true)
+	writer.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.HasSuffix(lines[0], ",Partial") || !strings.HasSuffix(lines[1], ",true") {
+		t.Fatalf("expected a Partial column, got %q", buf.String())
+	}
+
+	rendered := RenderTable(results, TableOptions{Partial: "interrupted"}, 0, false)
+	if !strings.HasSuffix(rendered, "\nPartial results: interrupted") {
+		t.Fatalf("expected partial footer, got %q", rendered)
+	}
+}
+
+func TestUnannouncedIPIsNA(t *testing.T) {
+	results := []model.Result{{
+		ASN:       model.ASNUnknown,
+		IP:        netip.MustParseAddr("192.0.2.1"),
+		Method:    model.MethodWhois,
+		Retrieved: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	WriteCSV(writer, results, false, false)
+	writer.Flush()
+	if want := "AS,IP,BGP Prefix,CC,Registry,Allocated,AS Name\n-1,192.0.2.1,NA,,,,\n"; buf.String() != want {
+		t.Fatalf("CSV = %q, want %q", buf.String(), want)
+	}
+
+	data, err := json.Marshal(GroupResultsByASN(results, false))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `[{"asn":-1,"as_name":"","ips":[{"ip":"192.0.2.1","bgp_prefix":"NA","cc":"","registry":"","allocated":"","method":"whois","retrieved":"2026-10-18T12:00:00Z"}]}]`; string(data) != want {
+		t.Fatalf("JSON = %s, want %s", data, want)
+	}
+
+	buf.Reset()
+	if err := NewNDJSONWriter(&buf, false).Write(results[0]); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if want := `{"ip":"192.0.2.1","asn":-1,"as_name":"","bgp_prefix":"NA","cc":"","registry":"","allocated":"","method":"whois","retrieved":"2026-10-18T12:00:00Z"}` + "\n"; buf.String() != want {
+		t.Fatalf("NDJSON = %q, want %q", buf.String(), want)
+	}
+}
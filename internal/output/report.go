@@ -0,0 +1,443 @@
+package output
+
+import (
+	"cmp"
+	"html/template"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// ReportOptions configures WriteHTMLReport.
+type ReportOptions struct {
+	// Title heads the report; it defaults to "ip2asn report".
+	Title string
+	// Fields are the result table columns. Without them the report shows the
+	// basic table columns, or the enrichment columns when Run.Enriched.
+	Fields []Field
+	Run    RunInfo
+}
+
+// ReportSummary holds the headline numbers of a report, which are also
+// embedded in its metadata.
+type ReportSummary struct {
+	Results   int `json:"results"`
+	IPs       int `json:"unique_ips"`
+	ASNs      int `json:"asns"`
+	Countries int `json:"countries"`
+	Prefixes  int `json:"prefixes"`
+	// Risk counts results by RiskLevel, with "none" for unscored results;
+	// it is only set for enriched runs.
+	Risk map[string]int `json:"risk,omitempty"`
+}
+
+// WriteHTMLReport writes a self-contained HTML report of results: summary
+// numbers, a country and risk breakdown, every result in one table and a
+// section per origin AS. Tables sort by clicking their headers and a filter
+// box narrows every table at once, using inline CSS and JavaScript only, so
+// the file can be opened offline or attached to a ticket. The run metadata
+// is embedded as JSON in the script element with id "ip2asn-run".
+func WriteHTMLReport(w io.Writer, results []model.Result, opts ReportOptions) error {
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = mustFields(basicTableFields...)
+		if opts.Run.Enriched {
+			fields = mustFields(reportEnrichedFields...)
+		}
+	}
+	title := opts.Title
+	if title == "" {
+		title = "ip2asn report"
+	}
+
+	summary := SummarizeResults(results, opts.Run.Enriched)
+	data := reportData{
+		Title:     title,
+		Run:       opts.Run,
+		Generated: opts.Run.Generated.UTC().Format(time.RFC3339),
+		Summary:   summary,
+		Metadata:  reportMetadata{Title: title, RunInfo: opts.Run, Summary: summary},
+		Results:   newReportTable("results", results, fields),
+		Countries: countryCounts(results),
+		Groups:    reportGroups(results, fields),
+	}
+	if opts.Run.Enriched {
+		for _, level := range []string{RiskHigh, RiskMedium, RiskLow, reportRiskNone} {
+			data.Risk = append(data.Risk, reportCount{Label: level, Count: summary.Risk[level]})
+		}
+	}
+	return reportTemplate.Execute(w, data)
+}
+
+// SummarizeResults counts the distinct IPs, origin ASNs, countries and
+// prefixes in results, and their risk levels when enriched.
+func SummarizeResults(results []model.Result, enriched bool) ReportSummary {
+	ips := make(map[string]struct{})
+	asns := make(map[model.ASN]struct{})
+	countries := make(map[string]struct{})
+	prefixes := make(map[string]struct{})
+	summary := ReportSummary{Results: len(results)}
+	if enriched {
+		summary.Risk = map[string]int{RiskHigh: 0, RiskMedium: 0, RiskLow: 0, reportRiskNone: 0}
+	}
+	for _, r := range results {
+		ips[r.IPString()] = struct{}{}
+		for _, origin := range r.AllOrigins() {
+			asns[origin.ASN] = struct{}{}
+		}
+		if r.CC != "" {
+			countries[r.CC] = struct{}{}
+		}
+		if r.BGPPrefix.IsValid() {
+			prefixes[r.BGPPrefix.String()] = struct{}{}
+		}
+		if enriched {
+			summary.Risk[cmp.Or(RiskLevel(r.ProxyCheck), reportRiskNone)]++
+		}
+	}
+	summary.IPs = len(ips)
+	summary.ASNs = len(asns)
+	summary.Countries = len(countries)
+	summary.Prefixes = len(prefixes)
+	return summary
+}
+
+// reportRiskNone counts enriched results without a risk score.
+const reportRiskNone = "none"
+
+type reportData struct {
+	Title     string
+	Run       RunInfo
+	Generated string
+	Summary   ReportSummary
+	Metadata  reportMetadata
+	Results   reportTable
+	Risk      []reportCount
+	Countries []reportCount
+	Groups    []reportGroup
+}
+
+// reportMetadata is the JSON embedded in the report.
+type reportMetadata struct {
+	Title string `json:"title"`
+	RunInfo
+	Summary ReportSummary `json:"summary"`
+}
+
+type reportCount struct {
+	Label string
+	Count int
+}
+
+type reportTable struct {
+	ID      string
+	Columns []reportColumn
+	Rows    []reportRow
+}
+
+type reportColumn struct {
+	Header string
+	Class  string
+}
+
+type reportRow struct {
+	// Class is "risk-high", "risk-medium" or "risk-low" for scored results
+	Class string
+	Cells []string
+}
+
+type reportGroup struct {
+	ASN       string
+	Name      string
+	Countries string
+	HighRisk  int
+	Table     reportTable
+}
+
+func newReportTable(id string, results []model.Result, fields []Field) reportTable {
+	table := reportTable{ID: id, Columns: make([]reportColumn, 0, len(fields))}
+	for _, field := range fields {
+		table.Columns = append(table.Columns, reportColumn{Header: field.Header, Class: reportAlignClass(field.Align)})
+	}
+	for _, r := range results {
+		row := reportRow{Cells: make([]string, 0, len(fields))}
+		if level := RiskLevel(r.ProxyCheck); level != "" {
+			row.Class = "risk-" + level
+		}
+		for _, field := range fields {
+			row.Cells = append(row.Cells, field.table(r, false))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+func reportAlignClass(align text.Align) string {
+	switch align {
+	case text.AlignRight:
+		return "num"
+	case text.AlignCenter:
+		return "center"
+	default:
+		return ""
+	}
+}
+
+// reportGroups returns a section per origin AS, ordered by ASN. MOAS results
+// are listed under every origin. The group tables leave out the AS columns,
+// which the section heading already shows.
+func reportGroups(results []model.Result, fields []Field) []reportGroup {
+	groupFields := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		if field.Name != "asn" && field.Name != "as_name" {
+			groupFields = append(groupFields, field)
+		}
+	}
+	if len(groupFields) == 0 {
+		groupFields = mustFields("ip")
+	}
+
+	type group struct {
+		asn     model.ASN
+		name    string
+		results []model.Result
+	}
+	var groups []*group
+	byASN := make(map[model.ASN]*group)
+	for _, r := range results {
+		for _, origin := range r.AllOrigins() {
+			g, ok := byASN[origin.ASN]
+			if !ok {
+				g = &group{asn: origin.ASN}
+				byASN[origin.ASN] = g
+				groups = append(groups, g)
+			}
+			if g.name == "" {
+				g.name = origin.ASName
+			}
+			g.results = append(g.results, r)
+		}
+	}
+	slices.SortStableFunc(groups, func(a, b *group) int { return cmp.Compare(a.asn, b.asn) })
+
+	sections := make([]reportGroup, 0, len(groups))
+	for _, g := range groups {
+		section := reportGroup{
+			ASN:   g.asn.String(),
+			Name:  g.name,
+			Table: newReportTable("", g.results, groupFields),
+		}
+		var countries []string
+		for _, country := range countryCounts(g.results) {
+			countries = append(countries, country.Label+" ("+strconv.Itoa(country.Count)+")")
+		}
+		section.Countries = strings.Join(countries, ", ")
+		for _, r := range g.results {
+			if RiskLevel(r.ProxyCheck) == RiskHigh {
+				section.HighRisk++
+			}
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// countryCounts counts results by country code, most frequent first.
+func countryCounts(results []model.Result) []reportCount {
+	counts := make(map[string]int)
+	for _, r := range results {
+		if r.CC != "" {
+			counts[r.CC]++
+		}
+	}
+	list := make([]reportCount, 0, len(counts))
+	for cc, count := range counts {
+		list = append(list, reportCount{Label: cc, Count: count})
+	}
+	slices.SortFunc(list, func(a, b reportCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Label, b.Label))
+	})
+	return list
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(reportHTML))
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="ip2asn {{.Run.Version}}">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.4 system-ui, -apple-system, "Segoe UI", sans-serif; margin: 0 auto; max-width: 1200px; padding: 1em 2em; color: #1d1d1f; }
+h1 { margin-bottom: 0.2em; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 0.2em; margin-top: 2em; }
+.meta { color: #555; }
+.notice { background: #fff4ce; border: 1px solid #e0c25c; padding: 0.5em 1em; margin: 0.5em 0; }
+.cards { display: flex; flex-wrap: wrap; gap: 1em; margin: 1em 0; }
+.card { border: 1px solid #ccc; border-radius: 6px; padding: 0.6em 1.2em; min-width: 8em; }
+.card .value { font-size: 1.8em; font-weight: 600; }
+.card .label { color: #555; }
+.breakdown { display: flex; flex-wrap: wrap; gap: 2em; }
+#filter { font: inherit; padding: 0.4em; width: 100%; max-width: 30em; box-sizing: border-box; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; }
+th, td { border: 1px solid #ddd; padding: 0.25em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f2f2f2; cursor: pointer; user-select: none; white-space: nowrap; }
+th[aria-sort="ascending"]::after { content: " \25B2"; }
+th[aria-sort="descending"]::after { content: " \25BC"; }
+.num { text-align: right; }
+.center { text-align: center; }
+tr.risk-high td { background: #fbd5d5; }
+tr.risk-medium td { background: #fdf0c4; }
+section.asn h3 { margin-bottom: 0.2em; }
+footer { color: #777; margin-top: 3em; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">Generated {{.Generated}} by ip2asn {{.Run.Version}}{{if .Run.Enriched}} with proxycheck.io enrichment{{end}}</p>
+{{- if .Run.Partial}}
+<p class="notice">Partial results: {{.Run.Partial}}</p>
+{{- end}}
+{{- if .Run.EnrichmentError}}
+<p class="notice">Proxycheck enrichment failed: {{.Run.EnrichmentError}}</p>
+{{- end}}
+</header>
+
+<h2>Summary</h2>
+<div class="cards">
+<div class="card"><div class="value">{{.Summary.IPs}}</div><div class="label">unique IPs</div></div>
+<div class="card"><div class="value">{{.Summary.ASNs}}</div><div class="label">ASNs</div></div>
+<div class="card"><div class="value">{{.Summary.Countries}}</div><div class="label">countries</div></div>
+<div class="card"><div class="value">{{.Summary.Prefixes}}</div><div class="label">prefixes</div></div>
+</div>
+<div class="breakdown">
+{{- if .Risk}}
+<div>
+<h3>Risk</h3>
+<table class="sortable" id="risk">
+<thead><tr><th>Level</th><th class="num">Results</th></tr></thead>
+<tbody>
+{{- range .Risk}}
+<tr class="risk-{{.Label}}"><td>{{.Label}}</td><td class="num">{{.Count}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</div>
+{{- end}}
+<div>
+<h3>Countries</h3>
+<table class="sortable" id="countries">
+<thead><tr><th>CC</th><th class="num">Results</th></tr></thead>
+<tbody>
+{{- range .Countries}}
+<tr><td>{{.Label}}</td><td class="num">{{.Count}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</div>
+<div>
+<h3>Origin ASNs</h3>
+<table class="sortable filterable" id="asns">
+<thead><tr><th class="num">ASN</th><th>AS Name</th><th class="num">Results</th>{{if .Run.Enriched}}<th class="num">High risk</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Groups}}
+<tr><td class="num"><a href="#as{{.ASN}}">{{.ASN}}</a></td><td>{{.Name}}</td><td class="num">{{len .Table.Rows}}</td>{{if $.Run.Enriched}}<td class="num">{{.HighRisk}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+</div>
+</div>
+
+<h2>Results</h2>
+<p><input id="filter" type="search" placeholder="Filter every table, e.g. 203.0.113 or US" aria-label="Filter"> <span id="shown"></span></p>
+{{template "table" .Results}}
+
+<h2>By origin AS</h2>
+{{- range .Groups}}
+<section class="asn" id="as{{.ASN}}">
+<h3>AS{{.ASN}}{{with .Name}} &mdash; {{.}}{{end}}</h3>
+<p class="meta">{{len .Table.Rows}} result(s){{with .Countries}}; {{.}}{{end}}{{if $.Run.Enriched}}; {{.HighRisk}} high risk{{end}}</p>
+{{template "table" .Table}}
+</section>
+{{- end}}
+
+<footer>ip2asn {{.Run.Version}}; data from Team Cymru{{if .Run.Enriched}} and proxycheck.io{{end}}.</footer>
+<script type="application/json" id="ip2asn-run">{{.Metadata}}</script>
+<script>
+(function () {
+  "use strict";
+  function cellText(row, index) {
+    var cell = row.cells[index];
+    return cell ? cell.textContent.trim() : "";
+  }
+  function compare(a, b) {
+    var x = Number(a), y = Number(b);
+    if (a !== "" && b !== "" && !isNaN(x) && !isNaN(y)) {
+      return x - y;
+    }
+    return a.localeCompare(b, undefined, {numeric: true, sensitivity: "base"});
+  }
+  document.querySelectorAll("table.sortable").forEach(function (table) {
+    var headers = table.tHead.rows[0].cells;
+    Array.prototype.forEach.call(headers, function (th, index) {
+      function sort() {
+        var ascending = th.getAttribute("aria-sort") !== "ascending";
+        Array.prototype.forEach.call(headers, function (h) { h.removeAttribute("aria-sort"); });
+        th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+        var body = table.tBodies[0];
+        var rows = Array.prototype.slice.call(body.rows);
+        rows.sort(function (r1, r2) {
+          var d = compare(cellText(r1, index), cellText(r2, index));
+          return ascending ? d : -d;
+        });
+        rows.forEach(function (row) { body.appendChild(row); });
+      }
+      th.tabIndex = 0;
+      th.addEventListener("click", sort);
+      th.addEventListener("keydown", function (event) {
+        if (event.key === "Enter" || event.key === " ") {
+          event.preventDefault();
+          sort();
+        }
+      });
+    });
+  });
+  var filter = document.getElementById("filter");
+  var shown = document.getElementById("shown");
+  filter.addEventListener("input", function () {
+    var terms = filter.value.toLowerCase().split(" ").filter(Boolean);
+    document.querySelectorAll("table.filterable tbody tr").forEach(function (row) {
+      var content = row.textContent.toLowerCase();
+      row.hidden = !terms.every(function (term) { return content.indexOf(term) >= 0; });
+    });
+    document.querySelectorAll("section.asn").forEach(function (section) {
+      section.hidden = terms.length > 0 && !section.querySelector("tbody tr:not([hidden])");
+    });
+    var rows = document.querySelectorAll("#results tbody tr");
+    var visible = document.querySelectorAll("#results tbody tr:not([hidden])");
+    shown.textContent = terms.length > 0 ? visible.length + " of " + rows.length + " results" : "";
+  });
+})();
+</script>
+</body>
+</html>
+{{define "table" -}}
+<table class="sortable filterable"{{with .ID}} id="{{.}}"{{end}}>
+<thead><tr>{{range .Columns}}<th{{with .Class}} class="{{.}}"{{end}}>{{.Header}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr{{with .Class}} class="{{.}}"{{end}}>{{range $i, $cell := .Cells}}<td{{with (index $.Columns $i).Class}} class="{{.}}"{{end}}>{{$cell}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+`
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func reportTestResults() []model.Result {
+	high := fieldsTestResult()
+	high.ASName = "TEST-NET <script>"
+
+	lowRisk := 10
+	low := fieldsTestResult()
+	low.IP = netip.MustParseAddr("203.0.113.8")
+	low.ProxyCheck = &model.ProxyCheck{Risk: &lowRisk}
+
+	moas := model.Result{
+		ASN:       64501,
+		IP:        netip.MustParseAddr("2001:db8::1"),
+		BGPPrefix: netip.MustParsePrefix("2001:db8::/32"),
+		CC:        "DE",
+		Registry:  "ripencc",
+		ASName:    "FIRST",
+		MOAS:      true,
+		Origins:   []model.Origin{{ASN: 64501, ASName: "FIRST"}, {ASN: 64502, ASName: "SECOND"}},
+	}
+	return []model.Result{high, low, moas}
+}
+
+func TestSummarizeResults(t *testing.T) {
+	summary := SummarizeResults(reportTestResults(), true)
+	want := ReportSummary{
+		Results: 3, IPs: 3, ASNs: 3, Countries: 2, Prefixes: 2,
+		Risk: map[string]int{RiskHigh: 1, RiskMedium: 0, RiskLow: 1, "none": 1},
+	}
+	if summary.Results != want.Results || summary.IPs != want.IPs || summary.ASNs != want.ASNs ||
+		summary.Countries != want.Countries || summary.Prefixes != want.Prefixes {
+		t.Fatalf("expected %+v, got %+v", want, summary)
+	}
+	for level, count := range want.Risk {
+		if summary.Risk[level] != count {
+			t.Fatalf("expected %d %s risk results, got %v", count, level, summary.Risk)
+		}
+	}
+	if basic := SummarizeResults(reportTestResults(), false); basic.Risk != nil {
+		t.Fatalf("expected no risk counts without enrichment, got %v", basic.Risk)
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	run := RunInfo{
+		Version:   "9.9",
+		Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Enriched:  true,
+		Partial:   "interrupted",
+	}
+	var b bytes.Buffer
+	if err := WriteHTMLReport(&b, reportTestResults(), ReportOptions{Title: "Case <42>", Run: run}); err != nil {
+		t.Fatalf("WriteHTMLReport: %v", err)
+	}
+	report := b.String()
+
+	for _, want := range []string{
+		"<title>Case &lt;42&gt;</title>",
+		"Generated 2026-10-18T12:00:00Z by ip2asn 9.9",
+		"Partial results: interrupted",
+		`<div class="value">3</div><div class="label">unique IPs</div>`,
+		`<tr class="risk-high"><td>high</td><td class="num">1</td></tr>`,
+		`<table class="sortable filterable" id="results">`,
+		`<tr class="risk-high"><td class="num">64500</td><td>203.0.113.7</td>`,
+		"TEST-NET &lt;script&gt;",
+		`<section class="asn" id="as64500">`,
+		`<section class="asn" id="as64502">`,
+		`<a href="#as64501">64501</a>`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("expected %q in report:\n%s", want, report)
+		}
+	}
+	// The MOAS result is listed under both origins
+	if got := strings.Count(report, "<td>2001:db8::1</td>"); got != 3 {
+		t.Fatalf("expected the MOAS result in the results table and 2 sections, got %d", got)
+	}
+	for _, external := range []string{"<link", " src=", "http://", "https://"} {
+		if strings.Contains(report, external) {
+			t.Fatalf("expected no external assets, found %q", external)
+		}
+	}
+
+	const open = `<script type="application/json" id="ip2asn-run">`
+	start := strings.Index(report, open)
+	end := strings.Index(report[start:], "</script>")
+	if start < 0 || end < 0 {
+		t.Fatalf("expected embedded run metadata:\n%s", report)
+	}
+	var metadata struct {
+		Title   string        `json:"title"`
+		Version string        `json:"version"`
+		Partial string        `json:"partial"`
+		Summary ReportSummary `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(report[start+len(open):start+end]), &metadata); err != nil {
+		t.Fatalf("invalid run metadata: %v", err)
+	}
+	if metadata.Title != "Case <42>" || metadata.Version != "9.9" || metadata.Partial != "interrupted" || metadata.Summary.ASNs != 3 {
+		t.Fatalf("unexpected run metadata: %+v", metadata)
+	}
+}
+
+func TestWriteHTMLReportBasicFields(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteHTMLReport(&b, reportTestResults(), ReportOptions{Run: RunInfo{Version: "9.9"}}); err != nil {
+		t.Fatalf("WriteHTMLReport: %v", err)
+	}
+	report := b.String()
+	if !strings.Contains(report, "<title>ip2asn report</title>") {
+		t.Fatalf("expected the default title")
+	}
+	if !strings.Contains(report, "<th>Registry</th>") || strings.Contains(report, "<th>Status</th>") {
+		t.Fatalf("expected the basic columns without enrichment")
+	}
+	if strings.Contains(report, `id="risk"`) {
+		t.Fatalf("expected no risk breakdown without enrichment")
+	}
+}
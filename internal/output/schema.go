@@ -0,0 +1,245 @@
+package output
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// Schemas name the field sets NDJSONWriter can write.
+const (
+	// SchemaFlat is ip2asn's own FlatResult and the default.
+	SchemaFlat = "flat"
+	// SchemaECS is the Elastic Common Schema; see NewECSDocument.
+	SchemaECS = "ecs"
+	// SchemaCIM is the Splunk Common Information Model; see NewCIMEvent.
+	SchemaCIM = "cim"
+)
+
+// ECSVersion is the ECS version ECS documents declare in ecs.version.
+const ECSVersion = "8.11.0"
+
+// ECSDocument is one result mapped to the Elastic Common Schema. Fields ECS
+// has no place for, such as the BGP prefix, are under ip2asn.
+type ECSDocument struct {
+	Timestamp time.Time     `json:"@timestamp"`
+	ECS       ECSVersionSet `json:"ecs"`
+	Event     ECSEvent      `json:"event"`
+	Source    ECSEndpoint   `json:"source"`
+	Threat    *ECSThreat    `json:"threat,omitempty"`
+	Tags      []string      `json:"tags,omitempty"`
+	IP2ASN    ECSIP2ASN     `json:"ip2asn"`
+}
+
+// ECSVersionSet is the ecs field set.
+type ECSVersionSet struct {
+	Version string `json:"version"`
+}
+
+// ECSEvent is the event field set.
+type ECSEvent struct {
+	Kind      string   `json:"kind"`
+	Category  []string `json:"category"`
+	Type      []string `json:"type"`
+	Module    string   `json:"module"`
+	Dataset   string   `json:"dataset"`
+	RiskScore *float64 `json:"risk_score,omitempty"`
+}
+
+// ECSEndpoint is the source field set.
+type ECSEndpoint struct {
+	IP  string  `json:"ip,omitempty"`
+	AS  *ECSAS  `json:"as,omitempty"`
+	Geo *ECSGeo `json:"geo,omitempty"`
+}
+
+// ECSAS is the as field set.
+type ECSAS struct {
+	Number       uint32           `json:"number"`
+	Organization *ECSOrganization `json:"organization,omitempty"`
+}
+
+// ECSOrganization names an AS.
+type ECSOrganization struct {
+	Name string `json:"name"`
+}
+
+// ECSGeo is the geo field set.
+type ECSGeo struct {
+	CountryISOCode string `json:"country_iso_code,omitempty"`
+	CountryName    string `json:"country_name,omitempty"`
+	RegionName     string `json:"region_name,omitempty"`
+	CityName       string `json:"city_name,omitempty"`
+}
+
+// ECSThreat holds the proxycheck.io findings as a threat indicator.
+type ECSThreat struct {
+	Indicator ECSIndicator `json:"indicator"`
+}
+
+// ECSIndicator is the threat.indicator field set.
+type ECSIndicator struct {
+	Type        string  `json:"type"`
+	IP          string  `json:"ip"`
+	Provider    string  `json:"provider"`
+	Confidence  string  `json:"confidence,omitempty"`
+	Description string  `json:"description"`
+	Geo         *ECSGeo `json:"geo,omitempty"`
+}
+
+// ECSIP2ASN holds the lookup fields without an ECS equivalent.
+type ECSIP2ASN struct {
+	BGPPrefix string       `json:"bgp_prefix,omitempty"`
+	Registry  string       `json:"registry,omitempty"`
+	Allocated string       `json:"allocated,omitempty"`
+	Method    model.Method `json:"method,omitempty"`
+	MOAS      bool         `json:"moas,omitempty"`
+	Origins   []model.ASN  `json:"origins,omitempty"`
+}
+
+// NewECSDocument maps r to ECS: the IP, AS and registry country go to
+// source.ip, source.as.number, source.as.organization.name and
+// source.geo.country_iso_code. With includeEnrichment, proxycheck.io data
+// becomes threat.indicator (with the risk level as its confidence and the
+// reported location as its geo), event.risk_score and tags such as "vpn".
+// @timestamp is when the result was retrieved, or now when it has no time.
+func NewECSDocument(r model.Result, includeEnrichment bool, now time.Time) ECSDocument {
+	doc := ECSDocument{
+		Timestamp: r.Retrieved.UTC(),
+		ECS:       ECSVersionSet{Version: ECSVersion},
+		Event: ECSEvent{
+			Kind:     "enrichment",
+			Category: []string{"network"},
+			Type:     []string{"info"},
+			Module:   "ip2asn",
+			Dataset:  "ip2asn.lookup",
+		},
+		Source: ECSEndpoint{IP: r.IPString()},
+		IP2ASN: ECSIP2ASN{
+			Registry:  r.Registry,
+			Allocated: r.Allocated.String(),
+			Method:    r.Method,
+		},
+	}
+	if r.Retrieved.IsZero() {
+		doc.Timestamp = now.UTC()
+	}
+	if r.ASN.Known() {
+		doc.Source.AS = &ECSAS{Number: uint32(r.ASN)}
+		if r.ASName != "" {
+			doc.Source.AS.Organization = &ECSOrganization{Name: r.ASName}
+		}
+	}
+	if r.CC != "" {
+		doc.Source.Geo = &ECSGeo{CountryISOCode: r.CC}
+	}
+	if r.BGPPrefix.IsValid() {
+		doc.IP2ASN.BGPPrefix = r.BGPPrefix.String()
+	}
+	if r.MOAS {
+		doc.IP2ASN.MOAS = true
+		doc.IP2ASN.Origins = r.OriginASNs()
+	}
+
+	pc := r.ProxyCheck
+	if !includeEnrichment || pc == nil || pc.IsEmpty() {
+		return doc
+	}
+	indicator := ECSIndicator{
+		Type:        "ipv4-addr",
+		IP:          r.IPString(),
+		Provider:    "proxycheck.io",
+		Description: proxycheckFindings(r.IPString(), pc),
+	}
+	if r.IP.Is6() && !r.IP.Is4In6() {
+		indicator.Type = "ipv6-addr"
+	}
+	if level := RiskLevel(pc); level != "" {
+		// ECS confidence values are capitalized: Low, Medium, High
+		indicator.Confidence = strings.ToUpper(level[:1]) + level[1:]
+		score := float64(*pc.Risk)
+		doc.Event.RiskScore = &score
+	}
+	if pc.City != "" || pc.State != "" || pc.Country != "" {
+		indicator.Geo = &ECSGeo{CountryName: pc.Country, RegionName: pc.State, CityName: pc.City}
+	}
+	doc.Threat = &ECSThreat{Indicator: indicator}
+	for _, label := range statusList(pc) {
+		doc.Tags = append(doc.Tags, detectionNames[label])
+	}
+	return doc
+}
+
+// CIMEvent is one result with Splunk CIM field names. CIM has no AS fields,
+// so the AS and prefix use src_-prefixed names alongside CIM's src and
+// src_ip.
+type CIMEvent struct {
+	Time           string      `json:"time"`
+	Src            string      `json:"src"`
+	SrcIP          string      `json:"src_ip"`
+	SrcASN         *uint32     `json:"src_asn,omitempty"`
+	SrcASName      string      `json:"src_as_name,omitempty"`
+	SrcBGPPrefix   string      `json:"src_bgp_prefix,omitempty"`
+	SrcCountry     string      `json:"src_country,omitempty"`
+	SrcRegistry    string      `json:"src_registry,omitempty"`
+	SrcAllocated   string      `json:"src_allocated,omitempty"`
+	SrcMOAS        bool        `json:"src_moas,omitempty"`
+	SrcOrigins     []model.ASN `json:"src_origins,omitempty"`
+	SrcCity        string      `json:"src_city,omitempty"`
+	SrcState       string      `json:"src_state,omitempty"`
+	SrcCountryName string      `json:"src_country_name,omitempty"`
+	Category       []string    `json:"category,omitempty"`
+	Severity       string      `json:"severity,omitempty"`
+	RiskScore      *int        `json:"risk_score,omitempty"`
+	Description    string      `json:"description,omitempty"`
+	VendorProduct  string      `json:"vendor_product"`
+}
+
+// NewCIMEvent maps r to CIM field names: the IP is src and src_ip and, with
+// includeEnrichment, proxycheck.io detections are the category values
+// ("vpn", "tor", ...), the risk level is the severity and the score is
+// risk_score, as in the Intrusion Detection and Risk data models.
+// vendor_product is "proxycheck.io" for enriched results and "ip2asn"
+// otherwise. time is when the result was retrieved, or now.
+func NewCIMEvent(r model.Result, includeEnrichment bool, now time.Time) CIMEvent {
+	retrieved := r.Retrieved
+	if retrieved.IsZero() {
+		retrieved = now
+	}
+	event := CIMEvent{
+		Time:          retrieved.UTC().Format(time.RFC3339),
+		Src:           r.IPString(),
+		SrcIP:         r.IPString(),
+		SrcASName:     r.ASName,
+		SrcCountry:    r.CC,
+		SrcRegistry:   r.Registry,
+		SrcAllocated:  r.Allocated.String(),
+		VendorProduct: "ip2asn",
+	}
+	if r.ASN.Known() {
+		asn := uint32(r.ASN)
+		event.SrcASN = &asn
+	}
+	if r.BGPPrefix.IsValid() {
+		event.SrcBGPPrefix = r.BGPPrefix.String()
+	}
+	if r.MOAS {
+		event.SrcMOAS = true
+		event.SrcOrigins = r.OriginASNs()
+	}
+
+	pc := r.ProxyCheck
+	if !includeEnrichment || pc == nil || pc.IsEmpty() {
+		return event
+	}
+	event.VendorProduct = "proxycheck.io"
+	for _, label := range statusList(pc) {
+		event.Category = append(event.Category, detectionNames[label])
+	}
+	event.Severity = RiskLevel(pc)
+	event.RiskScore = pc.Risk
+	event.Description = proxycheckFindings(r.IPString(), pc)
+	event.SrcCity, event.SrcState, event.SrcCountryName = pc.City, pc.State, pc.Country
+	return event
+}
@@ -0,0 +1,101 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSONWriterECS(t *testing.T) {
+	var b bytes.Buffer
+	nw := NewNDJSONWriter(&b, true)
+	nw.SetSchema(SchemaECS)
+	nw.SetBulkIndex("logs-ip2asn")
+	for _, r := range reportTestResults() {
+		if err := nw.Write(r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected an action and a document per result, got %d lines:\n%s", len(lines), b.String())
+	}
+	for i := 0; i < len(lines); i += 2 {
+		if lines[i] != `{"create":{"_index":"logs-ip2asn"}}` {
+			t.Fatalf("unexpected action line %s", lines[i])
+		}
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &doc); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	for path, want := range map[string]any{
+		"source.ip":                             "203.0.113.7",
+		"source.as.number":                      float64(64500),
+		"source.as.organization.name":           "TEST-NET <script>",
+		"source.geo.country_iso_code":           "US",
+		"threat.indicator.type":                 "ipv4-addr",
+		"threat.indicator.provider":             "proxycheck.io",
+		"threat.indicator.confidence":           "High",
+		"event.kind":                            "enrichment",
+		"event.risk_score":                      float64(80),
+		"ecs.version":                           ECSVersion,
+		"ip2asn.bgp_prefix":                     "203.0.113.0/24",
+		"threat.indicator.geo.city_name":        nil,
+		"threat.indicator.geo.country_iso_code": nil,
+	} {
+		if got := lookupPath(doc, path); got != want {
+			t.Fatalf("%s: expected %v, got %v", path, want, got)
+		}
+	}
+	if tags, _ := doc["tags"].([]any); len(tags) != 1 || tags[0] != "vpn" {
+		t.Fatalf("unexpected tags %v", doc["tags"])
+	}
+
+	var moas map[string]any
+	if err := json.Unmarshal([]byte(lines[5]), &moas); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	if origins, _ := lookupPath(moas, "ip2asn.origins").([]any); len(origins) != 2 || moas["threat"] != nil {
+		t.Fatalf("unexpected MOAS document %s", lines[5])
+	}
+}
+
+func TestNewCIMEvent(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	results := reportTestResults()
+
+	enriched := NewCIMEvent(results[0], true, now)
+	if enriched.Src != "203.0.113.7" || enriched.SrcIP != enriched.Src || enriched.SrcASN == nil || *enriched.SrcASN != 64500 ||
+		enriched.SrcCountry != "US" || enriched.Severity != RiskHigh || enriched.RiskScore == nil || *enriched.RiskScore != 80 ||
+		enriched.VendorProduct != "proxycheck.io" || len(enriched.Category) != 1 || enriched.Category[0] != "vpn" {
+		t.Fatalf("unexpected enriched event %+v", enriched)
+	}
+
+	plain := NewCIMEvent(results[0], false, now)
+	if plain.VendorProduct != "ip2asn" || plain.Severity != "" || plain.Category != nil || plain.Description != "" {
+		t.Fatalf("expected no proxycheck fields without enrichment, got %+v", plain)
+	}
+
+	results[0].Retrieved = time.Time{}
+	if got := NewCIMEvent(results[0], false, now).Time; got != "2026-10-18T12:00:00Z" {
+		t.Fatalf("expected the fallback time, got %s", got)
+	}
+}
+
+// lookupPath follows a dotted path through nested JSON objects.
+func lookupPath(doc map[string]any, path string) any {
+	var value any = doc
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
@@ -0,0 +1,263 @@
+package output
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// stixNamespace is the UUIDv5 namespace STIX 2.1 defines for deterministic
+// cyber-observable IDs.
+const stixNamespace = "00abedb4-aa42-466c-9c01-fed23315a9b7"
+
+// stixTime is the STIX timestamp layout, always UTC with milliseconds.
+const stixTime = "2006-01-02T15:04:05.000Z"
+
+type stixBundle struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Objects []any  `json:"objects"`
+}
+
+type stixIdentity struct {
+	Type          string `json:"type"`
+	SpecVersion   string `json:"spec_version"`
+	ID            string `json:"id"`
+	Created       string `json:"created"`
+	Modified      string `json:"modified"`
+	Name          string `json:"name"`
+	IdentityClass string `json:"identity_class"`
+}
+
+type stixAddress struct {
+	Type        string `json:"type"`
+	SpecVersion string `json:"spec_version"`
+	ID          string `json:"id"`
+	Value       string `json:"value"`
+}
+
+type stixAutonomousSystem struct {
+	Type        string `json:"type"`
+	SpecVersion string `json:"spec_version"`
+	ID          string `json:"id"`
+	Number      uint32 `json:"number"`
+	Name        string `json:"name,omitempty"`
+	RIR         string `json:"rir,omitempty"`
+}
+
+type stixRelationship struct {
+	Type             string `json:"type"`
+	SpecVersion      string `json:"spec_version"`
+	ID               string `json:"id"`
+	Created          string `json:"created"`
+	Modified         string `json:"modified"`
+	CreatedByRef     string `json:"created_by_ref"`
+	RelationshipType string `json:"relationship_type"`
+	SourceRef        string `json:"source_ref"`
+	TargetRef        string `json:"target_ref"`
+}
+
+type stixNote struct {
+	Type         string   `json:"type"`
+	SpecVersion  string   `json:"spec_version"`
+	ID           string   `json:"id"`
+	Created      string   `json:"created"`
+	Modified     string   `json:"modified"`
+	CreatedByRef string   `json:"created_by_ref"`
+	Abstract     string   `json:"abstract"`
+	Content      string   `json:"content"`
+	Labels       []string `json:"labels,omitempty"`
+	ObjectRefs   []string `json:"object_refs"`
+}
+
+// WriteSTIX writes results as an indented STIX 2.1 bundle: an ipv4-addr or
+// ipv6-addr observable per IP, an autonomous-system per origin AS and a
+// belongs-to relationship between them (one per origin of a MOAS prefix).
+// With includeEnrichment, proxycheck.io findings become a note on the
+// address. Every ID is derived from the data (UUIDv5, using the STIX
+// namespace for observables), so re-runs produce the same objects and
+// platforms deduplicate them on ingestion; relationships and notes are
+// versioned by run.Generated and credited to an "ip2asn" identity.
+func WriteSTIX(w io.Writer, results []model.Result, includeEnrichment bool, run RunInfo) error {
+	stamp := run.Generated.UTC().Format(stixTime)
+	if run.Generated.IsZero() {
+		stamp = time.Now().UTC().Format(stixTime)
+	}
+	identity := stixIdentity{
+		Type: "identity", SpecVersion: "2.1",
+		ID:      stixID("identity", stixJSON(map[string]any{"identity_class": "system", "name": "ip2asn"})),
+		Created: stamp, Modified: stamp,
+		Name: "ip2asn", IdentityClass: "system",
+	}
+
+	objects := []any{identity}
+	seen := make(map[string]struct{})
+	add := func(id string, object any) {
+		if _, dup := seen[id]; dup {
+			return
+		}
+		seen[id] = struct{}{}
+		objects = append(objects, object)
+	}
+	for _, r := range results {
+		if !r.IP.IsValid() {
+			continue
+		}
+		addrType := "ipv4-addr"
+		if r.IP.Is6() && !r.IP.Is4In6() {
+			addrType = "ipv6-addr"
+		}
+		value := r.IP.Unmap().String()
+		addrID := stixID(addrType, stixJSON(map[string]any{"value": value}))
+		add(addrID, stixAddress{Type: addrType, SpecVersion: "2.1", ID: addrID, Value: value})
+
+		for _, origin := range r.AllOrigins() {
+			if !origin.ASN.Known() {
+				continue
+			}
+			asID := stixID("autonomous-system", stixJSON(map[string]any{"number": uint32(origin.ASN)}))
+			add(asID, stixAutonomousSystem{
+				Type: "autonomous-system", SpecVersion: "2.1", ID: asID,
+				Number: uint32(origin.ASN), Name: origin.ASName, RIR: stixRIR(r.Registry),
+			})
+			relID := stixID("relationship", stixJSON(map[string]any{
+				"relationship_type": "belongs-to", "source_ref": addrID, "target_ref": asID,
+			}))
+			add(relID, stixRelationship{
+				Type: "relationship", SpecVersion: "2.1", ID: relID,
+				Created: stamp, Modified: stamp, CreatedByRef: identity.ID,
+				RelationshipType: "belongs-to", SourceRef: addrID, TargetRef: asID,
+			})
+		}
+
+		if includeEnrichment && r.ProxyCheck != nil && !r.ProxyCheck.IsEmpty() {
+			noteID := stixID("note", stixJSON(map[string]any{"abstract": "proxycheck.io", "object_refs": []string{addrID}}))
+			add(noteID, stixNote{
+				Type: "note", SpecVersion: "2.1", ID: noteID,
+				Created: stamp, Modified: stamp, CreatedByRef: identity.ID,
+				Abstract:   "proxycheck.io",
+				Content:    proxycheckFindings(value, r.ProxyCheck),
+				Labels:     stixLabels(r.ProxyCheck),
+				ObjectRefs: []string{addrID},
+			})
+		}
+	}
+
+	ids := make([]string, 0, len(objects))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	bundle := stixBundle{
+		Type:    "bundle",
+		ID:      stixID("bundle", strings.Join(ids, ",")),
+		Objects: objects,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bundle)
+}
+
+// stixID returns a "<type>--<UUIDv5>" identifier for name, which for
+// observables is the canonical JSON of their ID contributing properties.
+func stixID(objectType, name string) string {
+	return objectType + "--" + uuidV5(stixNamespace, name)
+}
+
+// stixJSON returns the canonical JSON of a flat object: encoding/json sorts
+// map keys and writes no whitespace, as RFC 8785 requires for these values.
+func stixJSON(properties map[string]any) string {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		panic("output: " + err.Error())
+	}
+	return string(data)
+}
+
+// uuidV5 returns the name-based SHA-1 UUID of name in namespace (RFC 9562).
+func uuidV5(namespace, name string) string {
+	ns, err := hex.DecodeString(strings.ReplaceAll(namespace, "-", ""))
+	if err != nil || len(ns) != 16 {
+		panic("output: invalid UUID namespace " + namespace)
+	}
+	h := sha1.New()
+	h.Write(ns)
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// stixRIR names the regional internet registry of a Team Cymru registry.
+func stixRIR(registry string) string {
+	switch strings.ToLower(registry) {
+	case "":
+		return ""
+	case "ripencc":
+		return "RIPE NCC"
+	default:
+		return strings.ToUpper(registry)
+	}
+}
+
+// proxycheckFindings describes the proxycheck.io data for value in a sentence.
+func proxycheckFindings(value string, pc *model.ProxyCheck) string {
+	var detections []string
+	for _, flag := range []struct {
+		value *bool
+		name  string
+	}{
+		{pc.VPN, "VPN"},
+		{pc.Proxy, "proxy"},
+		{pc.Compromised, "compromised"},
+		{pc.TOR, "TOR exit"},
+		{pc.Hosting, "hosting"},
+	} {
+		if flag.value != nil && *flag.value {
+			detections = append(detections, flag.name)
+		}
+	}
+	content := "proxycheck.io reports " + value
+	if len(detections) > 0 {
+		content += " as " + strings.Join(detections, ", ")
+	} else {
+		content += " with no detections"
+	}
+	if pc.VPNProvider != "" {
+		content += "; provider " + pc.VPNProvider
+	}
+	if pc.Risk != nil {
+		content += fmt.Sprintf("; risk %d (%s)", *pc.Risk, RiskLevel(pc))
+	}
+	var place []string
+	for _, part := range []string{pc.City, pc.State, pc.Country} {
+		if part != "" {
+			place = append(place, part)
+		}
+	}
+	if len(place) > 0 {
+		content += "; located in " + strings.Join(place, ", ")
+	}
+	return content + "."
+}
+
+// stixLabels returns the note labels: lowercase detection names and the
+// risk level, such as ["vpn", "hosting", "risk-high"].
+func stixLabels(pc *model.ProxyCheck) []string {
+	labels := make([]string, 0, 6)
+	for _, label := range statusList(pc) {
+		labels = append(labels, detectionNames[label])
+	}
+	if level := RiskLevel(pc); level != "" {
+		labels = append(labels, "risk-"+level)
+	}
+	return labels
+}
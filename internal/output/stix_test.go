@@ -0,0 +1,104 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUUIDv5(t *testing.T) {
+	// Matches Python's uuid.uuid5 for the STIX namespace
+	got := uuidV5(stixNamespace, `{"value":"198.51.100.3"}`)
+	if want := "28bb3599-77cd-5a82-a950-b5bc3caf07c4"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestWriteSTIX(t *testing.T) {
+	run := RunInfo{Version: "9.9", Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Enriched: true}
+	var b bytes.Buffer
+	if err := WriteSTIX(&b, reportTestResults(), true, run); err != nil {
+		t.Fatalf("WriteSTIX: %v", err)
+	}
+
+	var bundle struct {
+		Type    string           `json:"type"`
+		ID      string           `json:"id"`
+		Objects []map[string]any `json:"objects"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &bundle); err != nil {
+		t.Fatalf("invalid bundle: %v\n%s", err, b.String())
+	}
+	if bundle.Type != "bundle" || !strings.HasPrefix(bundle.ID, "bundle--") {
+		t.Fatalf("unexpected bundle header: %s %s", bundle.Type, bundle.ID)
+	}
+
+	counts := make(map[string]int)
+	byID := make(map[string]map[string]any)
+	for _, object := range bundle.Objects {
+		objectType := object["type"].(string)
+		counts[objectType]++
+		id := object["id"].(string)
+		if !strings.HasPrefix(id, objectType+"--") {
+			t.Fatalf("id %s does not match type %s", id, objectType)
+		}
+		if object["spec_version"] != "2.1" {
+			t.Fatalf("expected spec_version 2.1 on %s", id)
+		}
+		byID[id] = object
+	}
+	// 203.0.113.7 and .8 share AS64500; the MOAS IPv6 address belongs to
+	// AS64501 and AS64502. Both IPv4 results have proxycheck data.
+	want := map[string]int{"identity": 1, "ipv4-addr": 2, "ipv6-addr": 1, "autonomous-system": 3, "relationship": 4, "note": 2}
+	for objectType, count := range want {
+		if counts[objectType] != count {
+			t.Fatalf("expected %d %s objects, got %v", count, objectType, counts)
+		}
+	}
+
+	addrID := "ipv4-addr--" + uuidV5(stixNamespace, `{"value":"203.0.113.7"}`)
+	asID := "autonomous-system--" + uuidV5(stixNamespace, `{"number":64500}`)
+	as := byID[asID]
+	if as == nil || as["number"] != float64(64500) || as["name"] != "TEST-NET <script>" || as["rir"] != "ARIN" {
+		t.Fatalf("unexpected autonomous-system %s: %v", asID, as)
+	}
+	var linked bool
+	for _, object := range bundle.Objects {
+		if object["type"] == "relationship" && object["source_ref"] == addrID && object["target_ref"] == asID {
+			linked = object["relationship_type"] == "belongs-to" && object["created"] == "2026-10-18T12:00:00.000Z"
+		}
+		if object["type"] == "note" && object["object_refs"].([]any)[0] == addrID {
+			content := object["content"].(string)
+			if content != "proxycheck.io reports 203.0.113.7 as VPN; provider IVPN; risk 80 (high)." {
+				t.Fatalf("unexpected note content %q", content)
+			}
+			if labels := object["labels"].([]any); len(labels) != 2 || labels[0] != "vpn" || labels[1] != "risk-high" {
+				t.Fatalf("unexpected note labels %v", labels)
+			}
+		}
+	}
+	if !linked {
+		t.Fatalf("expected a belongs-to relationship from %s to %s", addrID, asID)
+	}
+
+	// The same results give the same bundle
+	var again bytes.Buffer
+	if err := WriteSTIX(&again, reportTestResults(), true, run); err != nil {
+		t.Fatalf("WriteSTIX: %v", err)
+	}
+	if again.String() != b.String() {
+		t.Fatalf("expected a deterministic bundle")
+	}
+}
+
+func TestWriteSTIXWithoutEnrichment(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteSTIX(&b, reportTestResults(), false, RunInfo{Generated: time.Now()}); err != nil {
+		t.Fatalf("WriteSTIX: %v", err)
+	}
+	if strings.Contains(b.String(), `"type": "note"`) {
+		t.Fatalf("expected no notes without enrichment:\n%s", b.String())
+	}
+}
@@ -0,0 +1,154 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// RunInfo describes the run that produced a set of results.
+type RunInfo struct {
+	Version   string    `json:"version"`
+	Generated time.Time `json:"generated"`
+	Enriched  bool      `json:"enriched"`
+	// Source names the input: a file, "stdin" or "--ip".
+	Source string `json:"source,omitempty"`
+	// Partial says why results are incomplete, or is empty.
+	Partial         string `json:"partial,omitempty"`
+	EnrichmentError string `json:"enrichment_error,omitempty"`
+}
+
+// TemplateRecord is the data a template is executed with for each result.
+// The result's fields and methods are promoted, so {{.IP}} and {{.ASN}}
+// work directly.
+type TemplateRecord struct {
+	model.Result
+	// Index counts results from 0.
+	Index int
+	Run   RunInfo
+}
+
+// TemplateDocument is the data the optional "header" and "footer"
+// templates are executed with, once per run.
+type TemplateDocument struct {
+	Results []model.Result
+	Groups  []JSONASNGroup
+	Run     RunInfo
+}
+
+// ParseTemplate parses text for WriteTemplate, with the helper functions
+// join, pad, default, risk and status available.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// WriteTemplate executes tmpl once per result. A "header" template defined
+// in it runs once before the results and a "footer" once after, both with
+// a TemplateDocument, so whole-run templates can be written as a header
+// alone.
+func WriteTemplate(w io.Writer, tmpl *template.Template, results []model.Result, run RunInfo) error {
+	doc := TemplateDocument{Results: results, Groups: GroupResultsByASN(results, run.Enriched), Run: run}
+	if header := tmpl.Lookup("header"); header != nil {
+		if err := header.Execute(w, doc); err != nil {
+			return err
+		}
+	}
+	for i, result := range results {
+		if err := tmpl.Execute(w, TemplateRecord{Result: result, Index: i, Run: run}); err != nil {
+			return err
+		}
+	}
+	if footer := tmpl.Lookup("footer"); footer != nil {
+		return footer.Execute(w, doc)
+	}
+	return nil
+}
+
+var templateFuncs = template.FuncMap{
+	"join":    templateJoin,
+	"pad":     templatePad,
+	"default": templateDefault,
+	"risk":    templateRisk,
+	"status":  templateStatus,
+}
+
+// templateJoin joins the elements of list with sep: {{.OriginASNs | join ","}}.
+func templateJoin(sep string, list any) string {
+	value := reflect.ValueOf(list)
+	switch value.Kind() {
+	case reflect.Invalid:
+		return ""
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, 0, value.Len())
+		for i := range value.Len() {
+			parts = append(parts, fmt.Sprint(value.Index(i).Interface()))
+		}
+		return strings.Join(parts, sep)
+	default:
+		return fmt.Sprint(list)
+	}
+}
+
+// templatePad pads value with spaces to width columns, on the right; a
+// negative width pads on the left, aligning the value right. Longer values
+// are left as they are.
+func templatePad(width int, value any) string {
+	s := fmt.Sprint(value)
+	fill := max(width, -width) - text.StringWidthWithoutEscSequences(s)
+	if fill <= 0 {
+		return s
+	}
+	if width < 0 {
+		return strings.Repeat(" ", fill) + s
+	}
+	return s + strings.Repeat(" ", fill)
+}
+
+// templateDefault returns value, or def when value is empty (nil, zero, an
+// empty string or list): {{.ASName | default "-"}}.
+func templateDefault(def, value any) any {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.IsZero() {
+		return def
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+		return def
+	}
+	return value
+}
+
+// templateRisk returns the risk level (high, medium or low) of a record,
+// result or its ProxyCheck, or "" when there is no score.
+func templateRisk(value any) (string, error) {
+	proxyCheck, err := templateProxyCheck("risk", value)
+	return RiskLevel(proxyCheck), err
+}
+
+// templateStatus returns the detection labels, such as "VPN HST", of a
+// record, result or its ProxyCheck.
+func templateStatus(value any) (string, error) {
+	proxyCheck, err := templateProxyCheck("status", value)
+	return strings.Join(statusList(proxyCheck), " "), err
+}
+
+func templateProxyCheck(fn string, value any) (*model.ProxyCheck, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *model.ProxyCheck:
+		return v, nil
+	case model.Result:
+		return v.ProxyCheck, nil
+	case TemplateRecord:
+		return v.ProxyCheck, nil
+	default:
+		return nil, fmt.Errorf("%s: want a result or its ProxyCheck, got %T", fn, value)
+	}
+}
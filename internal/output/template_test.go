@@ -0,0 +1,81 @@
+package output
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	riskValue := 80
+	trueValue := true
+	results := []model.Result{
+		{
+			ASN:    13335,
+			IP:     netip.MustParseAddr("1.1.1.1"),
+			ASName: "CLOUDFLARENET",
+			ProxyCheck: &model.ProxyCheck{
+				VPN:  &trueValue,
+				Risk: &riskValue,
+			},
+		},
+		{
+			ASN:     64500,
+			IP:      netip.MustParseAddr("192.0.2.1"),
+			MOAS:    true,
+			Origins: []model.Origin{{ASN: 64500}, {ASN: 64501}},
+		},
+	}
+	run := RunInfo{Version: "2.2", Generated: time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC), Enriched: true}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "one line per result",
+			text: `{{.IP}} AS{{.ASN}} {{.ASName}}{{"\n"}}`,
+			want: "1.1.1.1 AS13335 CLOUDFLARENET\n192.0.2.1 AS64500 \n",
+		},
+		{
+			name: "helpers",
+			text: `{{pad 10 .IP}}|{{pad -3 .Index}}|{{.ASName | default "-"}}|{{.OriginASNs | join ","}}|{{risk .}}|{{status .ProxyCheck}}{{"\n"}}`,
+			want: "1.1.1.1   |  0|CLOUDFLARENET|13335|high|VPN\n192.0.2.1 |  1|-|64500,64501||\n",
+		},
+		{
+			name: "header and footer with groups and run metadata",
+			text: `{{define "header"}}ip2asn {{.Run.Version}} {{.Run.Generated.Format "2006-01-02"}}: {{len .Groups}} ASNs{{"\n"}}{{end}}` +
+				`{{define "footer"}}{{range .Groups}}AS{{.ASN}}={{len .IPs}} {{end}}{{"\n"}}{{end}}` +
+				`- {{.IP}}{{"\n"}}`,
+			want: "ip2asn 2.2 2024-03-14: 3 ASNs\n- 1.1.1.1\n- 192.0.2.1\nAS13335=1 AS64500=1 AS64501=1 \n",
+		},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate("test", tt.text)
+		if err != nil {
+			t.Fatalf("%s: ParseTemplate: %v", tt.name, err)
+		}
+		var buf bytes.Buffer
+		if err := WriteTemplate(&buf, tmpl, results, run); err != nil {
+			t.Fatalf("%s: WriteTemplate: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Fatalf("%s:\n got %q\nwant %q", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestWriteTemplateReportsExecutionErrors(t *testing.T) {
+	tmpl, err := ParseTemplate("test", `{{risk .IP}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteTemplate(&buf, tmpl, []model.Result{{IP: netip.MustParseAddr("1.1.1.1")}}, RunInfo{}); err == nil {
+		t.Fatal("expected an error for risk applied to an IP")
+	}
+}
@@ -0,0 +1,177 @@
+// Package parquetfile writes lookup runs as Parquet files for data lakes. It
+// uses a pure-Go encoder, so builds need no cgo.
+package parquetfile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+// SchemaVersion is stored in the file metadata under "ip2asn.schema_version"
+// and changes whenever a column is renamed, retyped or removed.
+const SchemaVersion = "1"
+
+// DefaultName is the file name used when the output path is a directory. It
+// partitions runs by their UTC date, Hive style.
+const DefaultName = "date={date}/ip2asn-{run}.parquet"
+
+// Row is one result. Unknown values, such as the ASN of an unannounced IP or
+// proxycheck.io fields without --enrich, are null rather than "-" or 0.
+type Row struct {
+	RunID        string    `parquet:"run_id,dict"`
+	RunGenerated time.Time `parquet:"run_generated,timestamp(millisecond)"`
+	RunVersion   string    `parquet:"run_version,dict"`
+	RunSource    *string   `parquet:"run_source,optional,dict"`
+	RunEnriched  bool      `parquet:"run_enriched"`
+	RunPartial   *string   `parquet:"run_partial,optional,dict"`
+
+	IP           string     `parquet:"ip"`
+	IPVersion    int32      `parquet:"ip_version"`
+	ASN          *int64     `parquet:"asn,optional"`
+	ASName       *string    `parquet:"as_name,optional,dict"`
+	Prefix       *string    `parquet:"prefix,optional,dict"`
+	PrefixLength *int32     `parquet:"prefix_length,optional"`
+	CC           *string    `parquet:"cc,optional,dict"`
+	Registry     *string    `parquet:"registry,optional,dict"`
+	Allocated    *int32     `parquet:"allocated,optional,date"` // days since 1970-01-01
+	MOAS         bool       `parquet:"moas"`
+	Origins      []int64    `parquet:"origins,list"`
+	Method       *string    `parquet:"method,optional,dict"`
+	Retrieved    *time.Time `parquet:"retrieved,optional,timestamp(millisecond)"`
+
+	Proxy       *bool   `parquet:"proxy,optional"`
+	VPN         *bool   `parquet:"vpn,optional"`
+	Compromised *bool   `parquet:"compromised,optional"`
+	Hosting     *bool   `parquet:"hosting,optional"`
+	TOR         *bool   `parquet:"tor,optional"`
+	Risk        *int32  `parquet:"risk,optional"`
+	VPNProvider *string `parquet:"vpn_provider,optional,dict"`
+	City        *string `parquet:"city,optional"`
+	State       *string `parquet:"state,optional"`
+	Country     *string `parquet:"country,optional,dict"`
+}
+
+// NewRunID returns a run ID that sorts by time and is unique across hosts,
+// such as "20261018T120000Z-1a2b3c4d".
+func NewRunID(generated time.Time) (string, error) {
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", fmt.Errorf("run ID: %w", err)
+	}
+	return generated.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix[:]), nil
+}
+
+// Path expands the placeholders of an output path: {date} (2026-10-18),
+// {hour} (00 to 23), both in UTC, and {run} (the run ID). A path naming a
+// directory, that is ending in a separator or isDir, gets DefaultName.
+func Path(pattern string, isDir bool, generated time.Time, runID string) string {
+	if isDir || strings.HasSuffix(pattern, "/") || strings.HasSuffix(pattern, string(filepath.Separator)) {
+		pattern = filepath.Join(pattern, DefaultName)
+	}
+	generated = generated.UTC()
+	return strings.NewReplacer(
+		"{date}", generated.Format(time.DateOnly),
+		"{hour}", generated.Format("15"),
+		"{run}", runID,
+	).Replace(pattern)
+}
+
+// Write writes results as one Parquet file with Snappy-compressed columns.
+// Every row carries the run metadata; the file's key-value metadata also
+// holds the run as JSON under "ip2asn.run".
+func Write(w io.Writer, results []model.Result, run output.RunInfo, runID string) error {
+	meta, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	pw := parquet.NewGenericWriter[Row](w,
+		parquet.Compression(&parquet.Snappy),
+		parquet.CreatedBy("ip2asn", run.Version, ""),
+		parquet.KeyValueMetadata("ip2asn.schema_version", SchemaVersion),
+		parquet.KeyValueMetadata("ip2asn.run_id", runID),
+		parquet.KeyValueMetadata("ip2asn.run", string(meta)),
+	)
+	rows := make([]Row, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, newRow(r, run, runID))
+	}
+	if _, err := pw.Write(rows); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+func newRow(r model.Result, run output.RunInfo, runID string) Row {
+	row := Row{
+		RunID:        runID,
+		RunGenerated: run.Generated.UTC(),
+		RunVersion:   run.Version,
+		RunSource:    optionalString(run.Source),
+		RunEnriched:  run.Enriched,
+		RunPartial:   optionalString(run.Partial),
+
+		IP:        r.IPString(),
+		IPVersion: 4,
+		ASName:    optionalString(r.ASName),
+		CC:        optionalString(r.CC),
+		Registry:  optionalString(r.Registry),
+		MOAS:      r.MOAS,
+		Method:    optionalString(r.Method.String()),
+	}
+	if r.IP.Is6() && !r.IP.Is4In6() {
+		row.IPVersion = 6
+	}
+	if r.ASN.Known() {
+		asn := int64(r.ASN)
+		row.ASN = &asn
+	}
+	if r.BGPPrefix.IsValid() {
+		prefix, bits := r.BGPPrefix.String(), int32(r.BGPPrefix.Bits())
+		row.Prefix, row.PrefixLength = &prefix, &bits
+	}
+	if !r.Allocated.IsZero() {
+		days := int32(r.Allocated.Time().Unix() / 86400)
+		row.Allocated = &days
+	}
+	if !r.Retrieved.IsZero() {
+		retrieved := r.Retrieved.UTC()
+		row.Retrieved = &retrieved
+	}
+	for _, origin := range r.AllOrigins() {
+		if origin.ASN.Known() {
+			row.Origins = append(row.Origins, int64(origin.ASN))
+		}
+	}
+
+	if pc := r.ProxyCheck; pc != nil {
+		row.Proxy, row.VPN, row.Compromised, row.Hosting, row.TOR = pc.Proxy, pc.VPN, pc.Compromised, pc.Hosting, pc.TOR
+		if pc.Risk != nil {
+			risk := int32(*pc.Risk)
+			row.Risk = &risk
+		}
+		row.VPNProvider = optionalString(pc.VPNProvider)
+		row.City = optionalString(pc.City)
+		row.State = optionalString(pc.State)
+		row.Country = optionalString(pc.Country)
+	}
+	return row
+}
+
+// optionalString stores "" as null.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
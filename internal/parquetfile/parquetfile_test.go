@@ -0,0 +1,102 @@
+package parquetfile
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/hink/ip2asn/internal/model/modeltest"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+func TestWrite(t *testing.T) {
+	run := output.RunInfo{Version: "9.9", Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Enriched: true, Source: "iocs.txt"}
+	var b bytes.Buffer
+	if err := Write(&b, modeltest.Results(), run, "run-1"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if v, _ := file.Lookup("ip2asn.schema_version"); v != SchemaVersion {
+		t.Fatalf("expected schema version %s, got %q", SchemaVersion, v)
+	}
+	for _, c := range []struct{ column, want string }{
+		{"asn", "INT64"},
+		{"allocated", "INT32"},
+		{"retrieved", "INT64"},
+		{"risk", "INT32"},
+		{"vpn", "BOOLEAN"},
+	} {
+		column, ok := file.Schema().Lookup(c.column)
+		if !ok {
+			t.Fatalf("missing column %s", c.column)
+		}
+		if got := column.Node.Type().Kind().String(); got != c.want {
+			t.Fatalf("column %s: expected %s, got %s", c.column, c.want, got)
+		}
+	}
+
+	rows, err := parquet.Read[Row](bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	first := rows[0]
+	if first.RunID != "run-1" || !first.RunGenerated.Equal(run.Generated) || first.RunSource == nil || *first.RunSource != "iocs.txt" || !first.RunEnriched {
+		t.Fatalf("unexpected run metadata %+v", first)
+	}
+	if first.ASN == nil || *first.ASN != 64500 || *first.Prefix != "203.0.113.0/24" || *first.PrefixLength != 24 ||
+		first.Allocated == nil || *first.Allocated != 18262 || *first.Method != "whois" {
+		t.Fatalf("unexpected lookup columns %+v", first)
+	}
+	if first.VPN == nil || !*first.VPN || first.Proxy != nil || first.Risk == nil || *first.Risk != 80 || *first.VPNProvider != "IVPN" {
+		t.Fatalf("unexpected proxycheck columns %+v", first)
+	}
+	moas := rows[1]
+	if moas.IPVersion != 6 || !moas.MOAS || !slices.Equal(moas.Origins, []int64{64501, 64502}) || moas.Risk != nil {
+		t.Fatalf("unexpected MOAS row %+v", moas)
+	}
+	unannounced := rows[2]
+	if unannounced.ASN != nil || unannounced.Prefix != nil || unannounced.Allocated != nil || len(unannounced.Origins) != 0 {
+		t.Fatalf("expected nulls for an unannounced IP, got %+v", unannounced)
+	}
+}
+
+func TestPath(t *testing.T) {
+	generated := time.Date(2026, 10, 18, 23, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+	tests := []struct {
+		pattern string
+		isDir   bool
+		want    string
+	}{
+		{"out.parquet", false, "out.parquet"},
+		{"lake/dt={date}/h={hour}/{run}.parquet", false, "lake/dt=2026-10-18/h=21/r1.parquet"},
+		{"lake/", false, "lake/date=2026-10-18/ip2asn-r1.parquet"},
+		{"lake", true, "lake/date=2026-10-18/ip2asn-r1.parquet"},
+	}
+	for _, tt := range tests {
+		if got := Path(tt.pattern, tt.isDir, generated, "r1"); got != filepath.FromSlash(tt.want) {
+			t.Fatalf("Path(%q, %v) = %q, want %q", tt.pattern, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	id, err := NewRunID(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("NewRunID: %v", err)
+	}
+	if !regexp.MustCompile(`^20261018T120000Z-[0-9a-f]{8}$`).MatchString(id) {
+		t.Fatalf("unexpected run ID %q", id)
+	}
+}
@@ -1,7 +1,6 @@
 package parser
 
 import (
-    "bufio"
     "io"
     "net/netip"
     "regexp"
@@ -19,23 +18,7 @@ var ipv6Re = regexp.MustCompile(`(?i)\b(?:(?:[0-9a-f]{1,4}:){1,6}:(?:[0-9a-f]{1,
 // ParseIPs reads from r, extracts IPv4/IPv6 addresses using regex, validates with netip,
 // de-duplicates while preserving first-seen order, and returns them as canonical strings.
 func ParseIPs(r io.Reader) ([]string, error) {
-    br := bufio.NewReader(r)
-    var b strings.Builder
-    const chunk = 32 * 1024
-    tmp := make([]byte, chunk)
-    for {
-        n, err := br.Read(tmp)
-        if n > 0 {
-            b.Write(tmp[:n])
-        }
-        if err == io.EOF {
-            break
-        }
-        if err != nil {
-            return nil, err
-        }
-    }
-    return ParseIPsFromString(b.String())
+    return ParseIPsProgress(r, nil)
 }
 
 func ParseIPsFromString(s string) ([]string, error) {
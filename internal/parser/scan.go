@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"bufio"
+	"container/list"
+	"io"
+	"regexp"
+)
+
+// ParseIPsProgress is ParseIPs calling progress, when not nil, with the
+// number of unique addresses found so far whenever a line adds to it. Input
+// is read a line at a time rather than whole; the result is ordered as by
+// ParseIPsFromString, IPv4 addresses first.
+func ParseIPsProgress(r io.Reader, progress func(unique int)) ([]string, error) {
+	br := bufio.NewReader(r)
+	v4, v6 := make([]string, 0, 16), []string(nil)
+	seen := make(map[string]struct{}, 32)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			before := len(seen)
+			for _, found := range []struct {
+				re  *regexp.Regexp
+				ips *[]string
+			}{{ipv4Re, &v4}, {ipv6Re, &v6}} {
+				for _, m := range found.re.FindAllString(line, -1) {
+					addr, ok := parseAddr(m)
+					if !ok {
+						continue
+					}
+					cs := addr.String()
+					if _, exists := seen[cs]; !exists {
+						seen[cs] = struct{}{}
+						*found.ips = append(*found.ips, cs)
+					}
+				}
+			}
+			if progress != nil && len(seen) > before {
+				progress(len(seen))
+			}
+		}
+		if err == io.EOF {
+			return append(v4, v6...), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// scanWindow is how many distinct addresses ScanIPs remembers.
+const scanWindow = 100_000
+
+// ScanIPs reads r line by line and calls fn with each IPv4/IPv6 address the
+// first time it is seen, in canonical form, as soon as its line has been
+// read. Unlike ParseIPs it holds one line and the 100,000 most recently seen
+// addresses in memory, so it suits large or unending input; an address that
+// has dropped out of that window is reported again. Within a line, IPv4
+// addresses are reported before IPv6 ones. An error from fn stops the scan
+// and is returned.
+func ScanIPs(r io.Reader, fn func(ip string) error) error {
+	return scanIPs(r, scanWindow, fn)
+}
+
+// scanIPs is ScanIPs remembering up to window addresses, evicting the least
+// recently seen.
+func scanIPs(r io.Reader, window int, fn func(ip string) error) error {
+	br := bufio.NewReader(r)
+	seen := make(map[string]*list.Element, 32)
+	recent := list.New()
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			for _, re := range []*regexp.Regexp{ipv4Re, ipv6Re} {
+				for _, m := range re.FindAllString(line, -1) {
+					addr, ok := parseAddr(m)
+					if !ok {
+						continue
+					}
+					cs := addr.String()
+					if e, exists := seen[cs]; exists {
+						recent.MoveToFront(e)
+						continue
+					}
+					seen[cs] = recent.PushFront(cs)
+					if recent.Len() > window {
+						delete(seen, recent.Remove(recent.Back()).(string))
+					}
+					if err := fn(cs); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestScanIPs(t *testing.T) {
+	input := "first 10.0.0.1 and 2001:DB8::1\n" +
+		"again 10.0.0.1, then 192.168.1.1\n" +
+		"no newline at the end 2001:db8::1 203.0.113.9"
+	var got []string
+	if err := ScanIPs(strings.NewReader(input), func(ip string) error {
+		got = append(got, ip)
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanIPs: %v", err)
+	}
+	want := []string{"10.0.0.1", "2001:db8::1", "192.168.1.1", "203.0.113.9"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ScanIPs = %v, want %v", got, want)
+	}
+}
+
+func TestScanIPsForgetsLeastRecentlySeen(t *testing.T) {
+	// 10.0.0.1 stays in the window of two by recurring; 10.0.0.2 drops out
+	input := "10.0.0.1 10.0.0.2\n10.0.0.1 10.0.0.3\n10.0.0.1 10.0.0.2\n"
+	var got []string
+	if err := scanIPs(strings.NewReader(input), 2, func(ip string) error {
+		got = append(got, ip)
+		return nil
+	}); err != nil {
+		t.Fatalf("scanIPs: %v", err)
+	}
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.2"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("scanIPs = %v, want %v", got, want)
+	}
+}
+
+func TestScanIPsReportsEachLineBeforeReadingTheNext(t *testing.T) {
+	pr, pw := io.Pipe()
+	found := make(chan string)
+	done := make(chan error, 1)
+	go func() {
+		done <- ScanIPs(pr, func(ip string) error {
+			found <- ip
+			return nil
+		})
+	}()
+
+	// The writer blocks until the scanner has read the line, and the IP must
+	// arrive without any further input
+	go func() { _, _ = io.WriteString(pw, "198.51.100.1\n") }()
+	if ip := <-found; ip != "198.51.100.1" {
+		t.Fatalf("unexpected IP %q", ip)
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("ScanIPs: %v", err)
+	}
+}
+
+func TestScanIPsStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ScanIPs(strings.NewReader("10.0.0.1\n10.0.0.2\n"), func(string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected the callback error after one call, got %v after %d", err, calls)
+	}
+}
+
+func TestParseIPsProgress(t *testing.T) {
+	input := "10.0.0.1 2001:db8::1\nnothing here\n10.0.0.1 10.0.0.2\n10.0.0.2"
+	var counts []int
+	got, err := ParseIPsProgress(strings.NewReader(input), func(unique int) { counts = append(counts, unique) })
+	if err != nil {
+		t.Fatalf("ParseIPsProgress: %v", err)
+	}
+	if want := "10.0.0.1,10.0.0.2,2001:db8::1"; strings.Join(got, ",") != want {
+		t.Fatalf("ParseIPsProgress = %v, want IPv4 first: %s", got, want)
+	}
+	if len(counts) != 2 || counts[0] != 2 || counts[1] != 3 {
+		t.Fatalf("expected progress after the lines adding IPs, got %v", counts)
+	}
+}
@@ -0,0 +1,194 @@
+// Package progress reports how far a run has got, as a status line on a
+// terminal and as JSON lines for wrappers.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages of a run, in order.
+const (
+	StageParse  = "parse"
+	StageLookup = "lookup"
+	StageEnrich = "enrich"
+	StageDone   = "done"
+)
+
+// interval is the minimum time between updates within a stage.
+const interval = 100 * time.Millisecond
+
+// Event is one progress update. It is also the JSON line written for it.
+type Event struct {
+	Time  time.Time `json:"time"`
+	Stage string    `json:"stage"`
+	// Bytes of input read and unique IPs found so far (parse).
+	Bytes int64 `json:"bytes,omitempty"`
+	IPs   int   `json:"ips,omitempty"`
+	// Done and Total count IPs (lookup and enrich).
+	Done  int `json:"done,omitempty"`
+	Total int `json:"total,omitempty"`
+	// Session is the current one of Sessions: a WHOIS connection, a DNS
+	// lookup or a proxycheck.io batch.
+	Session  int `json:"session,omitempty"`
+	Sessions int `json:"sessions,omitempty"`
+	// ETASeconds estimates the time left in the stage from its rate so far;
+	// zero when unknown.
+	ETASeconds float64 `json:"eta_seconds,omitempty"`
+}
+
+// Reporter throttles updates and renders them. The methods of a nil
+// *Reporter do nothing, so callers need not check whether progress is on.
+// A Reporter is safe for concurrent use.
+type Reporter struct {
+	term   io.Writer
+	events *json.Encoder
+	now    func() time.Time
+
+	mu         sync.Mutex
+	stage      string
+	stageStart time.Time
+	startDone  int
+	last       time.Time
+	drawn      bool
+}
+
+// New returns a Reporter drawing a status line on term and writing JSON
+// lines to events; either may be nil. It returns nil when both are.
+func New(term, events io.Writer) *Reporter {
+	if term == nil && events == nil {
+		return nil
+	}
+	r := &Reporter{term: term, now: time.Now}
+	if events != nil {
+		r.events = json.NewEncoder(events)
+	}
+	return r
+}
+
+// Update reports e. Updates within a stage are dropped when they come faster
+// than every 100ms, except one whose count reaches its total.
+func (r *Reporter) Update(e Event) {
+	r.update(e, e.Total > 0 && e.Done >= e.Total)
+}
+
+// Complete reports e as the last update of its stage, so it is never
+// dropped. It ends the parse stage, which has no total to reach.
+func (r *Reporter) Complete(e Event) {
+	r.update(e, true)
+}
+
+func (r *Reporter) update(e Event, final bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if e.Stage != r.stage {
+		r.stage, r.stageStart, r.startDone = e.Stage, now, e.Done
+	} else if now.Sub(r.last) < interval && !final {
+		return
+	}
+	r.last = now
+
+	e.Time = now
+	if e.Total > e.Done && e.Done > r.startDone {
+		elapsed := now.Sub(r.stageStart)
+		remaining := elapsed * time.Duration(e.Total-e.Done) / time.Duration(e.Done-r.startDone)
+		e.ETASeconds = remaining.Round(time.Second).Seconds()
+	}
+	if r.events != nil {
+		_ = r.events.Encode(e)
+	}
+	if r.term != nil {
+		fmt.Fprintf(r.term, "\r%s\x1b[K", Line(e))
+		r.drawn = true
+	}
+}
+
+// Clear erases the status line, so other messages can be printed. The next
+// update draws it again.
+func (r *Reporter) Clear() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clear()
+}
+
+// Finish erases the status line and reports the run as done.
+func (r *Reporter) Finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clear()
+	if r.events != nil && r.stage != StageDone {
+		_ = r.events.Encode(Event{Time: r.now(), Stage: StageDone})
+	}
+	r.stage = StageDone
+}
+
+func (r *Reporter) clear() {
+	if r.drawn {
+		fmt.Fprint(r.term, "\r\x1b[K")
+		r.drawn = false
+	}
+}
+
+// Line is the status line for e.
+func Line(e Event) string {
+	var parts []string
+	switch e.Stage {
+	case StageParse:
+		line := "Reading input: " + formatBytes(e.Bytes)
+		if e.IPs > 0 {
+			line += fmt.Sprintf(", %d unique IPs", e.IPs)
+		}
+		return line
+	case StageLookup:
+		parts = append(parts, "Looking up "+counts(e))
+		if e.Sessions > 1 {
+			parts = append(parts, fmt.Sprintf("session %d/%d", e.Session, e.Sessions))
+		}
+	case StageEnrich:
+		parts = append(parts, "Enriching "+counts(e))
+		if e.Sessions > 1 {
+			parts = append(parts, fmt.Sprintf("batch %d/%d", e.Session, e.Sessions))
+		}
+	default:
+		return e.Stage
+	}
+	if e.ETASeconds > 0 {
+		parts = append(parts, "ETA "+(time.Duration(e.ETASeconds)*time.Second).String())
+	}
+	return strings.Join(parts, " · ")
+}
+
+func counts(e Event) string {
+	if e.Total == 0 {
+		return "0 IPs"
+	}
+	return fmt.Sprintf("%d/%d IPs (%d%%)", e.Done, e.Total, e.Done*100/e.Total)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
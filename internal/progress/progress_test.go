@@ -0,0 +1,100 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReporterThrottlesAndEstimates(t *testing.T) {
+	var term, events bytes.Buffer
+	r := New(&term, &events)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	steps := []struct {
+		after time.Duration
+		event Event
+	}{
+		{0, Event{Stage: StageLookup, Done: 0, Total: 100, Session: 1, Sessions: 1}},
+		{10 * time.Millisecond, Event{Stage: StageLookup, Done: 1, Total: 100, Session: 1, Sessions: 1}}, // Throttled
+		{2 * time.Second, Event{Stage: StageLookup, Done: 25, Total: 100, Session: 1, Sessions: 1}},
+		{10 * time.Millisecond, Event{Stage: StageLookup, Done: 100, Total: 100, Session: 1, Sessions: 1}}, // Completes the stage
+		{10 * time.Millisecond, Event{Stage: StageEnrich, Done: 0, Total: 100, Session: 1, Sessions: 1}},   // New stage
+	}
+	for _, step := range steps {
+		clock = clock.Add(step.after)
+		r.Update(step.event)
+	}
+	r.Finish()
+
+	var got []Event
+	scanner := bufio.NewScanner(&events)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("decode %q: %v", scanner.Text(), err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != 5 {
+		t.Fatalf("expected 5 events, got %+v", got)
+	}
+	if got[1].Done != 25 || got[1].ETASeconds != 6 {
+		t.Fatalf("expected 25 done with a 6s ETA, got %+v", got[1])
+	}
+	if got[2].Done != 100 || got[3].Stage != StageEnrich || got[4].Stage != StageDone {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+	if !strings.HasSuffix(term.String(), "\r\x1b[K") {
+		t.Fatalf("expected the status line to be cleared, got %q", term.String())
+	}
+}
+
+func TestReporterCompleteIsNotThrottled(t *testing.T) {
+	var events bytes.Buffer
+	r := New(nil, &events)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	r.Update(Event{Stage: StageParse, Bytes: 4096, IPs: 10})
+	clock = clock.Add(10 * time.Millisecond)
+	r.Update(Event{Stage: StageParse, Bytes: 8192, IPs: 20}) // Throttled
+	r.Complete(Event{Stage: StageParse, Bytes: 9000, IPs: 21})
+
+	lines := strings.Split(strings.TrimSpace(events.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"ips":10`) || !strings.Contains(lines[1], `"ips":21`) {
+		t.Fatalf("expected the first and the completing parse event, got %q", events.String())
+	}
+}
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Stage: StageParse, Bytes: 3 << 20, IPs: 42}, "Reading input: 3.0 MiB, 42 unique IPs"},
+		{Event{Stage: StageParse, Bytes: 512}, "Reading input: 512 B"},
+		{Event{Stage: StageLookup, Done: 30, Total: 120, Session: 1, Sessions: 1, ETASeconds: 90}, "Looking up 30/120 IPs (25%) · ETA 1m30s"},
+		{Event{Stage: StageLookup, Done: 3, Total: 10, Session: 4, Sessions: 10}, "Looking up 3/10 IPs (30%) · session 4/10"},
+		{Event{Stage: StageEnrich, Done: 1000, Total: 2500, Session: 2, Sessions: 3}, "Enriching 1000/2500 IPs (40%) · batch 2/3"},
+	}
+	for _, tt := range tests {
+		if got := Line(tt.event); got != tt.want {
+			t.Fatalf("Line(%+v) = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestNilReporter(t *testing.T) {
+	r := New(nil, nil)
+	if r != nil {
+		t.Fatal("expected nil reporter without outputs")
+	}
+	r.Update(Event{Stage: StageLookup})
+	r.Clear()
+	r.Finish()
+}
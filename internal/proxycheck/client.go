@@ -8,16 +8,14 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
 )
 
 const (
 	defaultBaseURL = "https://proxycheck.io/v3/"
 	defaultVersion = "11-February-2026"
 	maxBatchSize   = 1000
-	defaultTimeout = 8 * time.Second
 )
 
 // Client wraps proxycheck.io v3 lookups.
@@ -27,22 +25,27 @@ type Client struct {
 	Version    string
 	BatchSize  int
 	HTTPClient *http.Client
+	// Progress, if set, is called as each batch starts and once all are done,
+	// with the number of IPs looked up so far, the total, and the current
+	// batch number and the batch count.
+	Progress func(done, total, batch, batches int)
 }
 
 // NewClient builds a client with conservative defaults for the current v3 API.
+// Requests are bounded by the context passed to Lookup.
 func NewClient(apiKey string) *Client {
 	return &Client{
-		APIKey:    apiKey,
-		BaseURL:   defaultBaseURL,
-		Version:   defaultVersion,
-		BatchSize: maxBatchSize,
-		HTTPClient: &http.Client{
-			Timeout: defaultTimeout,
-		},
+		APIKey:     apiKey,
+		BaseURL:    defaultBaseURL,
+		Version:    defaultVersion,
+		BatchSize:  maxBatchSize,
+		HTTPClient: http.DefaultClient,
 	}
 }
 
-// Lookup enriches the supplied IPs using proxycheck.io batch requests.
+// Lookup enriches the supplied IPs using proxycheck.io batch requests. If a
+// batch fails, for example because ctx ended, the enrichments of the batches
+// before it are returned along with the error.
 func (c *Client) Lookup(ctx context.Context, ips []string) (map[string]model.ProxyCheck, string, error) {
 	uniqueIPs := uniqueStrings(ips)
 	if len(uniqueIPs) == 0 {
@@ -56,15 +59,19 @@ func (c *Client) Lookup(ctx context.Context, ips []string) (map[string]model.Pro
 
 	enrichments := make(map[string]model.ProxyCheck, len(uniqueIPs))
 	warnings := make([]string, 0, 1)
+	batches := (len(uniqueIPs) + batchSize - 1) / batchSize
 	for start := 0; start < len(uniqueIPs); start += batchSize {
 		end := start + batchSize
 		if end > len(uniqueIPs) {
 			end = len(uniqueIPs)
 		}
+		if c.Progress != nil {
+			c.Progress(start, len(uniqueIPs), start/batchSize+1, batches)
+		}
 
 		batchEnrichments, warningMessage, err := c.lookupBatch(ctx, uniqueIPs[start:end])
 		if err != nil {
-			return nil, "", err
+			return enrichments, strings.Join(warnings, "; "), err
 		}
 		for ip, enrichment := range batchEnrichments {
 			enrichments[ip] = enrichment
@@ -73,6 +80,9 @@ func (c *Client) Lookup(ctx context.Context, ips []string) (map[string]model.Pro
 			warnings = appendUnique(warnings, warningMessage)
 		}
 	}
+	if c.Progress != nil {
+		c.Progress(len(uniqueIPs), len(uniqueIPs), batches, batches)
+	}
 
 	return enrichments, strings.Join(warnings, "; "), nil
 }
@@ -80,7 +90,7 @@ func (c *Client) Lookup(ctx context.Context, ips []string) (map[string]model.Pro
 // Apply copies enrichment data onto matching results by IP string.
 func Apply(results []model.Result, enrichments map[string]model.ProxyCheck) {
 	for idx := range results {
-		enrichment, ok := enrichments[results[idx].IP]
+		enrichment, ok := enrichments[results[idx].IPString()]
 		if !ok || enrichment.IsEmpty() {
 			continue
 		}
@@ -177,7 +187,7 @@ func (c *Client) httpClient() *http.Client {
 	if c.HTTPClient != nil {
 		return c.HTTPClient
 	}
-	return &http.Client{Timeout: defaultTimeout}
+	return http.DefaultClient
 }
 
 func apiError(statusCode int, status, message string) error {
@@ -2,13 +2,15 @@ package proxycheck
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
 
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
 )
 
 func TestLookupBatchesAndParsesV3Response(t *testing.T) {
@@ -137,13 +139,44 @@ func TestLookupDeniedResponseReturnsError(t *testing.T) {
 	}
 }
 
+func TestLookupKeepsCompletedBatchesOnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		ip := r.PostForm.Get("ips")
+		if ip == "203.0.113.11" {
+			// Second batch: the user interrupts while it is in flight
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok","` + ip + `":{"detections":{"vpn":true}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+	client.BatchSize = 1
+
+	enrichments, _, err := client.Lookup(ctx, []string{"203.0.113.10", "203.0.113.11"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(enrichments) != 1 || enrichments["203.0.113.10"].VPN == nil {
+		t.Fatalf("expected the first batch to be kept, got %+v", enrichments)
+	}
+}
+
 func TestApplyCopiesEnrichmentByIP(t *testing.T) {
 	trueValue := true
 	riskValue := 70
 	results := []model.Result{
-		{IP: "203.0.113.1"},
-		{IP: "203.0.113.1"},
-		{IP: "203.0.113.2"},
+		{IP: netip.MustParseAddr("203.0.113.1")},
+		{IP: netip.MustParseAddr("203.0.113.1")},
+		{IP: netip.MustParseAddr("203.0.113.2")},
 	}
 	enrichments := map[string]model.ProxyCheck{
 		"203.0.113.1": {
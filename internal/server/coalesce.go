@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+const (
+	defaultCoalesceWindow = 50 * time.Millisecond
+	defaultMaxBatch       = 1000
+)
+
+// coalescer merges lookups from concurrent requests into shared upstream
+// queries, so a busy server still follows Team Cymru's guidance of one bulk
+// session for many IPs rather than one query per IP.
+//
+// IPs arriving within window are batched (up to maxBatch per query), an IP
+// already queued or in flight is never queried twice, and every upstream
+// query first waits on the global limiter. When perIP is set the backend
+// sends one query per IP, so a batch waits on the limiter once per IP.
+type coalescer struct {
+	lookup   func(ctx context.Context, ips []string) ([]model.Result, error)
+	window   time.Duration
+	maxBatch int
+	timeout  time.Duration // Budget for one upstream query
+	limiter  *rate.Limiter
+	perIP    bool
+
+	mu      sync.Mutex
+	pending map[string]*flight // Queued or in-flight, by IP
+	queue   []string
+	timer   *time.Timer
+}
+
+// flight is one IP's pending answer, shared by every waiting caller.
+type flight struct {
+	done    chan struct{}
+	results []model.Result
+	err     error
+}
+
+func newCoalescer(lookup func(context.Context, []string) ([]model.Result, error), window time.Duration, maxBatch int, timeout time.Duration, limiter *rate.Limiter, perIP bool) *coalescer {
+	return &coalescer{
+		lookup:   lookup,
+		window:   window,
+		maxBatch: maxBatch,
+		timeout:  timeout,
+		limiter:  limiter,
+		perIP:    perIP,
+		pending:  make(map[string]*flight),
+	}
+}
+
+// Lookup returns results for ips once their batches complete. If ctx ends
+// first, Lookup returns ctx.Err() while the shared batches carry on for the
+// other callers.
+func (c *coalescer) Lookup(ctx context.Context, ips []string) ([]model.Result, error) {
+	flights := make([]*flight, 0, len(ips))
+
+	c.mu.Lock()
+	for _, ip := range ips {
+		f, exists := c.pending[ip]
+		if !exists {
+			f = &flight{done: make(chan struct{})}
+			c.pending[ip] = f
+			c.queue = append(c.queue, ip)
+		}
+		flights = append(flights, f)
+	}
+	for len(c.queue) >= c.maxBatch {
+		c.flushLocked(c.maxBatch)
+	}
+	if len(c.queue) > 0 && c.timer == nil {
+		c.timer = time.AfterFunc(c.window, c.flushTimer)
+	}
+	c.mu.Unlock()
+
+	var results []model.Result
+	for _, f := range flights {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		results = append(results, f.results...)
+	}
+	return results, nil
+}
+
+// Wait blocks until the upstream limiter admits one query, for lookups that
+// bypass batching such as AS name queries.
+func (c *coalescer) Wait(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.Wait(ctx)
+}
+
+func (c *coalescer) flushTimer() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer = nil
+	for len(c.queue) > 0 {
+		c.flushLocked(c.maxBatch)
+	}
+}
+
+// flushLocked starts an upstream query for up to n queued IPs.
+func (c *coalescer) flushLocked(n int) {
+	if n > len(c.queue) {
+		n = len(c.queue)
+	}
+	batch := append([]string(nil), c.queue[:n]...)
+	c.queue = c.queue[n:]
+	if len(c.queue) == 0 && c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	flights := make(map[string]*flight, len(batch))
+	for _, ip := range batch {
+		flights[ip] = c.pending[ip]
+	}
+	go c.run(batch, flights)
+}
+
+func (c *coalescer) run(batch []string, flights map[string]*flight) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var results []model.Result
+	err := c.Wait(ctx)
+	for i := 1; c.perIP && err == nil && i < len(batch); i++ {
+		err = c.Wait(ctx)
+	}
+	if err == nil {
+		results, err = c.lookup(ctx, batch)
+	}
+
+	byIP := make(map[string][]model.Result, len(batch))
+	for _, result := range results {
+		ip := result.IPString()
+		byIP[ip] = append(byIP[ip], result)
+	}
+
+	c.mu.Lock()
+	for _, ip := range batch {
+		delete(c.pending, ip)
+	}
+	c.mu.Unlock()
+
+	for ip, f := range flights {
+		f.results = byIP[ip]
+		f.err = err
+		close(f.done)
+	}
+}
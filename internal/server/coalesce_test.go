@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+type recordingLookup struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (r *recordingLookup) lookup(_ context.Context, ips []string) ([]model.Result, error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, append([]string(nil), ips...))
+	r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	results := make([]model.Result, 0, len(ips))
+	for _, ip := range ips {
+		results = append(results, model.Result{ASN: 64500, IP: netip.MustParseAddr(ip)})
+	}
+	return results, nil
+}
+
+func (r *recordingLookup) snapshot() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]string(nil), r.batches...)
+}
+
+func TestCoalescerBatchesConcurrentRequestsAndDeduplicates(t *testing.T) {
+	upstream := &recordingLookup{}
+	c := newCoalescer(upstream.lookup, 50*time.Millisecond, 100, time.Second, nil, false)
+
+	requests := [][]string{
+		{"198.51.100.1"},
+		{"198.51.100.2"},
+		{"198.51.100.1", "198.51.100.3"},
+		{"198.51.100.1"},
+	}
+	var wg sync.WaitGroup
+	got := make([][]model.Result, len(requests))
+	for idx, ips := range requests {
+		wg.Add(1)
+		go func(idx int, ips []string) {
+			defer wg.Done()
+			results, err := c.Lookup(context.Background(), ips)
+			if err != nil {
+				t.Errorf("Lookup(%v) error: %v", ips, err)
+			}
+			got[idx] = results
+		}(idx, ips)
+	}
+	wg.Wait()
+
+	batches := upstream.snapshot()
+	if len(batches) != 1 {
+		t.Fatalf("expected one upstream session, got %v", batches)
+	}
+	sort.Strings(batches[0])
+	if strings.Join(batches[0], ",") != "198.51.100.1,198.51.100.2,198.51.100.3" {
+		t.Fatalf("expected de-duplicated batch, got %v", batches[0])
+	}
+	for idx, results := range got {
+		if len(results) != len(requests[idx]) {
+			t.Fatalf("request %d: expected %d results, got %+v", idx, len(requests[idx]), results)
+		}
+		for i, result := range results {
+			if result.IPString() != requests[idx][i] {
+				t.Fatalf("request %d: expected result for %s, got %s", idx, requests[idx][i], result.IPString())
+			}
+		}
+	}
+}
+
+func TestCoalescerSplitsAtMaxBatch(t *testing.T) {
+	upstream := &recordingLookup{}
+	c := newCoalescer(upstream.lookup, time.Hour, 2, time.Second, nil, false)
+
+	// A full batch is flushed immediately; the remainder waits for the window
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := c.Lookup(ctx, []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected remainder to wait for the window, got %v", err)
+	}
+	batches := upstream.snapshot()
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("expected one full batch of 2, got %v", batches)
+	}
+}
+
+func TestCoalescerSharesUpstreamErrors(t *testing.T) {
+	upstream := &recordingLookup{err: errors.New("connection refused")}
+	c := newCoalescer(upstream.lookup, time.Millisecond, 10, time.Second, nil, false)
+
+	if _, err := c.Lookup(context.Background(), []string{"198.51.100.1"}); err == nil || err.Error() != "connection refused" {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+	// Failed lookups are not remembered
+	if _, err := c.Lookup(context.Background(), []string{"198.51.100.1"}); err == nil {
+		t.Fatal("expected second lookup to query upstream again")
+	}
+	if batches := upstream.snapshot(); len(batches) != 2 {
+		t.Fatalf("expected 2 upstream sessions, got %v", batches)
+	}
+}
+
+func TestCoalescerRateLimitsUpstreamSessions(t *testing.T) {
+	upstream := &recordingLookup{}
+	limiter := rate.NewLimiter(rate.Every(200*time.Millisecond), 1)
+	c := newCoalescer(upstream.lookup, time.Millisecond, 10, time.Second, limiter, false)
+
+	start := time.Now()
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		if _, err := c.Lookup(context.Background(), []string{ip}); err != nil {
+			t.Fatalf("Lookup error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected second session to wait for the limiter, took %v", elapsed)
+	}
+}
+
+func TestCoalescerRateLimitsPerIPBackends(t *testing.T) {
+	upstream := &recordingLookup{}
+	limiter := rate.NewLimiter(rate.Every(100*time.Millisecond), 1)
+	c := newCoalescer(upstream.lookup, time.Millisecond, 10, time.Second, limiter, true)
+
+	// One batch of three IPs spends three tokens
+	start := time.Now()
+	if _, err := c.Lookup(context.Background(), []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"}); err != nil {
+		t.Fatalf("Lookup error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected the batch to wait for a token per IP, took %v", elapsed)
+	}
+	if batches := upstream.snapshot(); len(batches) != 1 {
+		t.Fatalf("expected 1 upstream batch, got %v", batches)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// serveConns runs handle for each connection accepted on ln until ctx is
+// done, then stops accepting and waits up to shutdownTimeout for open
+// connections before closing them. handle need not close its connection.
+func serveConns(ctx context.Context, ln net.Listener, shutdownTimeout time.Duration, handle func(context.Context, net.Conn)) error {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		conns = make(map[net.Conn]struct{})
+	)
+
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			mu.Lock()
+			conns[conn] = struct{}{}
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handle(ctx, conn)
+				conn.Close()
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		wg.Wait()
+		return err
+	case <-ctx.Done():
+	}
+
+	ln.Close()
+	<-errCh
+	if waitTimeout(&wg, shutdownTimeout) {
+		return nil
+	}
+	mu.Lock()
+	for conn := range conns {
+		conn.Close()
+	}
+	mu.Unlock()
+	wg.Wait()
+	return errors.New("shutdown: connections still open after shutdown timeout")
+}
+
+// waitTimeout waits for wg and reports whether it finished within timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,376 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+const (
+	// dnsTTL is the TTL of answers; origin data changes slowly.
+	dnsTTL = 3600
+
+	dnsMaxUDPSize     = 512
+	dnsMaxTXTString   = 255
+	dnsTCPIdleTimeout = 10 * time.Second
+
+	originZone  = "origin.asn.cymru.com."
+	origin6Zone = "origin6.asn.cymru.com."
+	asnZone     = "asn.cymru.com."
+)
+
+// DNSServer answers TXT queries in Team Cymru's zones from local data, so
+// tools that resolve them can use an internal resolver:
+//
+//	4.3.2.1.origin.asn.cymru.com      "<ASN(s)> | <BGP Prefix> | <CC> | <Registry> | <Allocated>"
+//	<nibbles>.origin6.asn.cymru.com   same, for IPv6
+//	AS13335.asn.cymru.com             "<ASN> | <CC> | <Registry> | <Allocated> | <AS Name>"
+//
+// The formats match what cymru.LookupDNS parses; MOAS prefixes list every
+// origin ASN space-separated. Unrouted IPs and unknown ASNs get NXDOMAIN,
+// lookup failures SERVFAIL and names outside the zones REFUSED. It serves UDP
+// and TCP; lookups share the coalescer and upstream rate limit.
+type DNSServer struct {
+	cfg       Config
+	client    Client
+	coalescer *coalescer
+}
+
+// NewDNS returns a DNSServer with defaults filled in for unset Config fields.
+// Only the lookup, timeout and upstream settings apply.
+func NewDNS(client Client, cfg Config) *DNSServer {
+	cfg = cfg.withDefaults()
+	return &DNSServer{
+		cfg:       cfg,
+		client:    client,
+		coalescer: newCoalescer(client.Lookup, cfg.CoalesceWindow, cfg.MaxBatch, cfg.RequestTimeout, cfg.limiter(), cfg.UpstreamPerIP),
+	}
+}
+
+// ListenAndServe listens on cfg.Addr over UDP and TCP and serves until ctx is
+// done.
+func (s *DNSServer) ListenAndServe(ctx context.Context) error {
+	pc, err := net.ListenPacket("udp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return err
+	}
+	return s.Serve(ctx, pc, ln)
+}
+
+// Serve answers queries on pc and ln until ctx is done, then stops reading
+// queries and waits up to ShutdownTimeout for those in progress.
+func (s *DNSServer) Serve(ctx context.Context, pc net.PacketConn, ln net.Listener) error {
+	var (
+		wg     sync.WaitGroup
+		tcpErr = make(chan error, 1)
+	)
+	go func() {
+		tcpErr <- serveConns(ctx, ln, s.cfg.ShutdownTimeout, s.serveTCP)
+	}()
+
+	udpErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				udpErr <- err
+				return
+			}
+			query := append([]byte(nil), buf[:n]...)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := s.handle(ctx, query, true); resp != nil {
+					_, _ = pc.WriteTo(resp, addr)
+				}
+			}()
+		}
+	}()
+
+	select {
+	case err := <-udpErr:
+		return err
+	case err := <-tcpErr:
+		pc.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting queries before waiting: once the read loop has exited
+	// nothing calls wg.Add. Queries in progress still finish, but their UDP
+	// answers are dropped, as the client would time out and retry anyway.
+	pc.Close()
+	<-udpErr
+	finished := waitTimeout(&wg, s.cfg.ShutdownTimeout)
+	if err := <-tcpErr; err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	if !finished {
+		return errors.New("shutdown: queries still in progress after shutdown timeout")
+	}
+	return nil
+}
+
+// serveTCP answers length-prefixed queries until the client goes idle.
+func (s *DNSServer) serveTCP(ctx context.Context, conn net.Conn) {
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(dnsTCPIdleTimeout))
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		resp := s.handle(ctx, query, false)
+		if resp == nil {
+			return
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(s.cfg.RequestTimeout))
+		out := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// handle returns the packed response to query, or nil if it cannot be
+// answered at all.
+func (s *DNSServer) handle(ctx context.Context, query []byte, udp bool) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               header.ID,
+			Response:         true,
+			OpCode:           header.OpCode,
+			Authoritative:    true,
+			RecursionDesired: header.RecursionDesired,
+			RCode:            dnsmessage.RCodeSuccess,
+		},
+	}
+	questions, err := parser.AllQuestions()
+	if err != nil || len(questions) != 1 || header.OpCode != 0 {
+		resp.Header.RCode = dnsmessage.RCodeFormatError
+		if header.OpCode != 0 {
+			resp.Header.RCode = dnsmessage.RCodeNotImplemented
+		}
+		return pack(resp, udp)
+	}
+	question := questions[0]
+	resp.Questions = questions
+
+	name, rcode := parseName(strings.ToLower(question.Name.String()))
+	if rcode != dnsmessage.RCodeSuccess || question.Type != dnsmessage.TypeTXT || question.Class != dnsmessage.ClassINET {
+		// Only TXT is looked up; other types for a valid name get no data
+		resp.Header.RCode = rcode
+		return pack(resp, udp)
+	}
+
+	// The session outlives shutdown for up to ShutdownTimeout
+	lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.RequestTimeout)
+	defer cancel()
+
+	txt, rcode := s.answer(lookupCtx, name)
+	resp.Header.RCode = rcode
+	if rcode == dnsmessage.RCodeSuccess {
+		resp.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{
+				Name:  question.Name,
+				Type:  dnsmessage.TypeTXT,
+				Class: dnsmessage.ClassINET,
+				TTL:   dnsTTL,
+			},
+			Body: &dnsmessage.TXTResource{TXT: splitTXT(txt)},
+		}}
+	}
+	return pack(resp, udp)
+}
+
+// dnsName is a parsed query name: an IP in an origin zone or an AS number.
+type dnsName struct {
+	addr netip.Addr
+	asn  model.ASN
+}
+
+// parseName parses a query name without any lookup, returning the response
+// code explaining why it cannot be answered when it is not valid.
+func parseName(name string) (dnsName, dnsmessage.RCode) {
+	switch {
+	case strings.HasSuffix(name, "."+originZone):
+		addr, ok := reverseIPv4Name(strings.TrimSuffix(name, "."+originZone))
+		if !ok {
+			return dnsName{}, dnsmessage.RCodeNameError
+		}
+		return dnsName{addr: addr}, dnsmessage.RCodeSuccess
+	case strings.HasSuffix(name, "."+origin6Zone):
+		addr, ok := reverseIPv6Name(strings.TrimSuffix(name, "."+origin6Zone))
+		if !ok {
+			return dnsName{}, dnsmessage.RCodeNameError
+		}
+		return dnsName{addr: addr}, dnsmessage.RCodeSuccess
+	case strings.HasSuffix(name, "."+asnZone):
+		label := strings.TrimSuffix(name, "."+asnZone)
+		if strings.Contains(label, ".") || !strings.HasPrefix(label, "as") {
+			return dnsName{}, dnsmessage.RCodeNameError
+		}
+		asn, err := model.ParseASN(label)
+		if err != nil || !asn.Known() {
+			return dnsName{}, dnsmessage.RCodeNameError
+		}
+		return dnsName{asn: asn}, dnsmessage.RCodeSuccess
+	case name == asnZone:
+		return dnsName{}, dnsmessage.RCodeNameError
+	default:
+		return dnsName{}, dnsmessage.RCodeRefused
+	}
+}
+
+// answer returns the TXT data for name, or the response code explaining why
+// there is none.
+func (s *DNSServer) answer(ctx context.Context, name dnsName) (string, dnsmessage.RCode) {
+	if name.addr.IsValid() {
+		return s.answerOrigin(ctx, name.addr)
+	}
+	return s.answerASN(ctx, name.asn)
+}
+
+func (s *DNSServer) answerOrigin(ctx context.Context, addr netip.Addr) (string, dnsmessage.RCode) {
+	results, err := s.coalescer.Lookup(ctx, []string{addr.String()})
+	if err != nil {
+		return "", dnsmessage.RCodeServerFailure
+	}
+	for _, result := range results {
+		if !result.ASN.Known() {
+			continue
+		}
+		return originTXT(result), dnsmessage.RCodeSuccess
+	}
+	return "", dnsmessage.RCodeNameError
+}
+
+func (s *DNSServer) answerASN(ctx context.Context, asn model.ASN) (string, dnsmessage.RCode) {
+	if err := s.coalescer.Wait(ctx); err != nil {
+		return "", dnsmessage.RCodeServerFailure
+	}
+	info, err := s.client.LookupASN(ctx, asn)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "", dnsmessage.RCodeNameError
+		}
+		return "", dnsmessage.RCodeServerFailure
+	}
+	return asnTXT(info), dnsmessage.RCodeSuccess
+}
+
+// originTXT formats an origin answer; MOAS origins are space-separated.
+func originTXT(result model.Result) string {
+	asns := make([]string, 0, len(result.AllOrigins()))
+	for _, asn := range result.OriginASNs() {
+		asns = append(asns, asn.String())
+	}
+	prefix := "NA"
+	if result.BGPPrefix.IsValid() {
+		prefix = result.BGPPrefix.String()
+	}
+	return fmt.Sprintf("%s | %s | %s | %s | %s", strings.Join(asns, " "), prefix, result.CC, result.Registry, result.Allocated)
+}
+
+func asnTXT(info model.ASInfo) string {
+	return fmt.Sprintf("%s | %s | %s | %s | %s", info.ASN, info.CC, info.Registry, info.Allocated, info.ASName)
+}
+
+// reverseIPv4Name parses the reversed octets of an origin query.
+func reverseIPv4Name(labels string) (netip.Addr, bool) {
+	parts := strings.Split(labels, ".")
+	if len(parts) != 4 {
+		return netip.Addr{}, false
+	}
+	var octets [4]byte
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 8)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		octets[3-i] = byte(n)
+	}
+	return netip.AddrFrom4(octets), true
+}
+
+// reverseIPv6Name parses the 32 reversed nibbles of an origin6 query.
+func reverseIPv6Name(labels string) (netip.Addr, bool) {
+	parts := strings.Split(labels, ".")
+	if len(parts) != 32 {
+		return netip.Addr{}, false
+	}
+	var b [16]byte
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 16, 4)
+		if err != nil || len(part) != 1 {
+			return netip.Addr{}, false
+		}
+		idx := 31 - i
+		if idx%2 == 0 {
+			b[idx/2] |= byte(n) << 4
+		} else {
+			b[idx/2] |= byte(n)
+		}
+	}
+	return netip.AddrFrom16(b), true
+}
+
+// splitTXT splits s into character-strings of at most 255 bytes; resolvers
+// join them back together.
+func splitTXT(s string) []string {
+	var parts []string
+	for len(s) > dnsMaxTXTString {
+		parts = append(parts, s[:dnsMaxTXTString])
+		s = s[dnsMaxTXTString:]
+	}
+	return append(parts, s)
+}
+
+// pack encodes resp, truncating UDP responses that exceed 512 bytes so the
+// client retries over TCP.
+func pack(resp dnsmessage.Message, udp bool) []byte {
+	out, err := resp.Pack()
+	if err != nil {
+		resp.Answers = nil
+		resp.Header.RCode = dnsmessage.RCodeServerFailure
+		out, _ = resp.Pack()
+		return out
+	}
+	if udp && len(out) > dnsMaxUDPSize {
+		resp.Answers = nil
+		resp.Header.Truncated = true
+		out, _ = resp.Pack()
+	}
+	return out
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+// staticClient answers from fixed results.
+type staticClient struct {
+	results map[string]model.Result
+}
+
+func (c staticClient) Lookup(_ context.Context, ips []string) ([]model.Result, error) {
+	var results []model.Result
+	for _, ip := range ips {
+		if ip == "203.0.113.66" {
+			return nil, errors.New("connection refused")
+		}
+		if result, ok := c.results[ip]; ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func (c staticClient) LookupASN(_ context.Context, asn model.ASN) (model.ASInfo, error) {
+	if asn != 64500 {
+		return model.ASInfo{}, &net.DNSError{Err: "no such host", IsNotFound: true}
+	}
+	return model.ASInfo{ASN: asn, CC: "US", Registry: "arin", Allocated: model.MustParseDate("2010-05-01"), ASName: "EXAMPLE, US"}, nil
+}
+
+func (c staticClient) Enrich(context.Context, []model.Result) (string, error) {
+	return "", nil
+}
+
+func startDNS(t *testing.T, network string) *net.Resolver {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := staticClient{results: map[string]model.Result{
+		"192.0.2.1": {
+			ASN:       64500,
+			IP:        netip.MustParseAddr("192.0.2.1"),
+			BGPPrefix: netip.MustParsePrefix("192.0.2.0/24"),
+			CC:        "US",
+			Registry:  "arin",
+			Allocated: model.MustParseDate("2010-05-01"),
+			Origins:   []model.Origin{{ASN: 64500}, {ASN: 64501}},
+			MOAS:      true,
+		},
+		"2001:db8::1": {
+			ASN:       64502,
+			IP:        netip.MustParseAddr("2001:db8::1"),
+			BGPPrefix: netip.MustParsePrefix("2001:db8::/32"),
+			CC:        "NL",
+			Registry:  "ripencc",
+		},
+		"198.51.100.9": {ASN: model.ASNUnknown, IP: netip.MustParseAddr("198.51.100.9")},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewDNS(client, Config{}).Serve(ctx, pc, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned error: %v", err)
+		}
+	})
+
+	addr := pc.LocalAddr().String()
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func TestDNSAnswersCymruZones(t *testing.T) {
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			resolver := startDNS(t, network)
+			tests := []struct {
+				name string
+				want string
+			}{
+				{"1.2.0.192.origin.asn.cymru.com", "64500 64501 | 192.0.2.0/24 | US | arin | 2010-05-01"},
+				{"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com", "64502 | 2001:db8::/32 | NL | ripencc | "},
+				{"AS64500.asn.cymru.com", "64500 | US | arin | 2010-05-01 | EXAMPLE, US"},
+			}
+			for _, tt := range tests {
+				txts, err := resolver.LookupTXT(context.Background(), tt.name)
+				if err != nil {
+					t.Fatalf("LookupTXT(%s) error: %v", tt.name, err)
+				}
+				if got := strings.Join(txts, ""); got != tt.want {
+					t.Fatalf("LookupTXT(%s) = %q, want %q", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDNSErrors(t *testing.T) {
+	resolver := startDNS(t, "udp")
+	tests := []struct {
+		name     string
+		notFound bool
+	}{
+		{"9.100.51.198.origin.asn.cymru.com", true}, // unrouted
+		{"1.2.3.origin.asn.cymru.com", true},        // malformed
+		{"AS64999.asn.cymru.com", true},
+		{"66.113.0.203.origin.asn.cymru.com", false}, // upstream failure
+		{"example.com", false},                       // outside the zones
+	}
+	for _, tt := range tests {
+		_, err := resolver.LookupTXT(context.Background(), tt.name)
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) {
+			t.Fatalf("LookupTXT(%s): expected DNS error, got %v", tt.name, err)
+		}
+		if dnsErr.IsNotFound != tt.notFound {
+			t.Fatalf("LookupTXT(%s): IsNotFound = %v, want %v (%v)", tt.name, dnsErr.IsNotFound, tt.notFound, err)
+		}
+	}
+}
+
+// countingClient counts the lookups that reach it.
+type countingClient struct {
+	staticClient
+	lookups atomic.Int32
+}
+
+func (c *countingClient) Lookup(ctx context.Context, ips []string) ([]model.Result, error) {
+	c.lookups.Add(1)
+	return c.staticClient.Lookup(ctx, ips)
+}
+
+func (c *countingClient) LookupASN(ctx context.Context, asn model.ASN) (model.ASInfo, error) {
+	c.lookups.Add(1)
+	return c.staticClient.LookupASN(ctx, asn)
+}
+
+func TestDNSAnswersOtherTypesWithoutLookup(t *testing.T) {
+	client := &countingClient{}
+	srv := NewDNS(client, Config{})
+	tests := []struct {
+		name  string
+		qtype dnsmessage.Type
+		rcode dnsmessage.RCode
+	}{
+		{"1.2.0.192.origin.asn.cymru.com.", dnsmessage.TypeA, dnsmessage.RCodeSuccess},
+		{"AS64500.asn.cymru.com.", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess},
+		{"1.2.3.origin.asn.cymru.com.", dnsmessage.TypeA, dnsmessage.RCodeNameError},
+		{"example.com.", dnsmessage.TypeA, dnsmessage.RCodeRefused},
+	}
+	for _, tt := range tests {
+		query, err := (&dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: 1},
+			Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(tt.name), Type: tt.qtype, Class: dnsmessage.ClassINET}},
+		}).Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp dnsmessage.Message
+		if err := resp.Unpack(srv.handle(context.Background(), query, true)); err != nil {
+			t.Fatalf("%s: unpack response: %v", tt.name, err)
+		}
+		if resp.Header.RCode != tt.rcode || len(resp.Answers) != 0 {
+			t.Fatalf("%s %v: got %v with %d answers, want %v and none", tt.name, tt.qtype, resp.Header.RCode, len(resp.Answers), tt.rcode)
+		}
+	}
+	if n := client.lookups.Load(); n != 0 {
+		t.Fatalf("expected no upstream lookups, got %d", n)
+	}
+}
+
+func TestReverseNamesRoundTrip(t *testing.T) {
+	for _, ip := range []string{"192.0.2.1", "2001:db8:85a3::8a2e:370:7334"} {
+		addr := netip.MustParseAddr(ip)
+		var got netip.Addr
+		var ok bool
+		if addr.Is4() {
+			b := addr.As4()
+			got, ok = reverseIPv4Name(fmt.Sprintf("%d.%d.%d.%d", b[3], b[2], b[1], b[0]))
+		} else {
+			const hexdigits = "0123456789abcdef"
+			b := addr.As16()
+			labels := make([]string, 0, 32)
+			for i := 15; i >= 0; i-- {
+				labels = append(labels, string(hexdigits[b[i]&0xF]), string(hexdigits[b[i]>>4]))
+			}
+			got, ok = reverseIPv6Name(strings.Join(labels, "."))
+		}
+		if !ok || got != addr {
+			t.Fatalf("reverse name for %s parsed as %v (%v)", ip, got, ok)
+		}
+	}
+}
+
+// slowClient delays each lookup so queries are in progress at shutdown.
+type slowClient struct {
+	staticClient
+}
+
+func (c slowClient) Lookup(ctx context.Context, ips []string) ([]model.Result, error) {
+	time.Sleep(5 * time.Millisecond)
+	return c.staticClient.Lookup(ctx, ips)
+}
+
+func TestDNSShutdownWhileQueriesArrive(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewDNS(slowClient{}, Config{}).Serve(ctx, pc, ln) }()
+
+	// Keep sending queries from several clients until Serve returns
+	query := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 1, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("1.2.0.192.origin.asn.cymru.com."), Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET}},
+	})
+	packed, err := query.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var senders sync.WaitGroup
+	for range 4 {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			conn, err := net.Dial("udp", pc.LocalAddr().String())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_, _ = conn.Write(packed)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after shutdown")
+	}
+	close(stop)
+	senders.Wait()
+}
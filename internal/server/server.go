@@ -0,0 +1,363 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
+	"github.com/hink/ip2asn/internal/parser"
+	"github.com/hink/ip2asn/internal/sortutil"
+)
+
+const (
+	defaultRequestTimeout  = 15 * time.Second
+	defaultShutdownTimeout = 10 * time.Second
+	defaultMaxBodyBytes    = 1 << 20
+	defaultMaxIPs          = 10000
+)
+
+// Client performs the lookups behind the API. *ip2asn.Client satisfies it.
+type Client interface {
+	Lookup(ctx context.Context, ips []string) ([]model.Result, error)
+	LookupASN(ctx context.Context, asn model.ASN) (model.ASInfo, error)
+	Enrich(ctx context.Context, results []model.Result) (string, error)
+}
+
+// Config controls the HTTP server.
+type Config struct {
+	Addr            string
+	RequestTimeout  time.Duration // Per-request lookup budget
+	ShutdownTimeout time.Duration // Grace period for in-flight requests
+	MaxBodyBytes    int64
+	MaxIPs          int
+	Enrich          bool // Client has enrichment configured; allows ?enrich=true (HTTP only)
+
+	// CoalesceWindow is how long a lookup waits for others to share its
+	// upstream query; MaxBatch caps the IPs per query.
+	CoalesceWindow time.Duration
+	MaxBatch       int
+	// UpstreamRate limits queries to Team Cymru per second across all
+	// requests; zero means unlimited. UpstreamBurst defaults to 1.
+	UpstreamRate  float64
+	UpstreamBurst int
+	// UpstreamPerIP charges the limiter once per IP rather than once per
+	// batch, for a Client that queries each IP separately (the DNS backend).
+	UpstreamPerIP bool
+}
+
+// Server exposes lookups over HTTP.
+//
+//	GET  /v1/ip/{ip}     one IP
+//	POST /v1/lookup      text (IPs are extracted) or JSON ({"ips": [...]} or [...])
+//	GET  /v1/asn/{asn}   AS registration data
+//	GET  /healthz        liveness
+//	GET  /readyz         readiness; 503 while shutting down
+//
+// Lookup endpoints return the ASN-grouped JSON shape by default, or the flat
+// per-result shape with ?format=flat. ?enrich=true adds proxycheck data.
+//
+// IP lookups from concurrent requests are coalesced into shared upstream
+// queries; see Config.CoalesceWindow.
+type Server struct {
+	cfg       Config
+	client    Client
+	coalescer *coalescer
+	ready     atomic.Bool
+}
+
+// New returns a Server with defaults filled in for unset Config fields.
+func New(client Client, cfg Config) *Server {
+	cfg = cfg.withDefaults()
+	return &Server{
+		cfg:       cfg,
+		client:    client,
+		coalescer: newCoalescer(client.Lookup, cfg.CoalesceWindow, cfg.MaxBatch, cfg.RequestTimeout, cfg.limiter(), cfg.UpstreamPerIP),
+	}
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = defaultRequestTimeout
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if cfg.MaxIPs <= 0 {
+		cfg.MaxIPs = defaultMaxIPs
+	}
+	if cfg.CoalesceWindow <= 0 {
+		cfg.CoalesceWindow = defaultCoalesceWindow
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = defaultMaxBatch
+	}
+	if cfg.UpstreamBurst <= 0 {
+		cfg.UpstreamBurst = 1
+	}
+	return cfg
+}
+
+// limiter returns the upstream rate limiter, or nil when unlimited.
+func (cfg Config) limiter() *rate.Limiter {
+	if cfg.UpstreamRate <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(cfg.UpstreamRate), cfg.UpstreamBurst)
+}
+
+// Handler returns the API routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/ip/{ip}", s.handleIP)
+	mux.HandleFunc("POST /v1/lookup", s.handleLookup)
+	mux.HandleFunc("GET /v1/asn/{asn}", s.handleASN)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !s.ready.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+	return mux
+}
+
+// ListenAndServe listens on cfg.Addr and serves until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done, then stops accepting requests, marks
+// the server not ready and waits up to ShutdownTimeout for in-flight requests.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      s.cfg.RequestTimeout + 10*time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(ln)
+	}()
+	s.ready.Store(true)
+
+	select {
+	case err := <-errCh:
+		s.ready.Store(false)
+		return err
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleIP(w http.ResponseWriter, r *http.Request) {
+	addr, err := netip.ParseAddr(r.PathValue("ip"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid IP %q", r.PathValue("ip")))
+		return
+	}
+	s.respondLookup(w, r, []string{addr.Unmap().String()})
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", s.cfg.MaxBodyBytes))
+		return
+	}
+
+	ips, err := requestIPs(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(ips) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no IPv4/IPv6 addresses were found in the request"))
+		return
+	}
+	if len(ips) > s.cfg.MaxIPs {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request contains %d IPs; the limit is %d", len(ips), s.cfg.MaxIPs))
+		return
+	}
+	s.respondLookup(w, r, ips)
+}
+
+func (s *Server) handleASN(w http.ResponseWriter, r *http.Request) {
+	asn, err := model.ParseASN(r.PathValue("asn"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.RequestTimeout)
+	defer cancel()
+	if err := s.coalescer.Wait(ctx); err != nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("upstream rate limit: %w", err))
+		return
+	}
+	info, err := s.client.LookupASN(ctx, asn)
+	if err != nil {
+		writeError(w, upstreamStatus(ctx, err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) respondLookup(w http.ResponseWriter, r *http.Request, ips []string) {
+	query := r.URL.Query()
+	flat, err := flatFormat(query.Get("format"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	enrich, err := queryBool(query.Get("enrich"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("enrich: %w", err))
+		return
+	}
+	if enrich && !s.cfg.Enrich {
+		writeError(w, http.StatusBadRequest, errors.New("enrichment is not configured on this server"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.RequestTimeout)
+	defer cancel()
+	results, err := s.coalescer.Lookup(ctx, ips)
+	if err != nil {
+		writeError(w, upstreamStatus(ctx, err), err)
+		return
+	}
+	sortutil.SortResults(results)
+
+	if enrich {
+		warning, err := s.client.Enrich(ctx, results)
+		switch {
+		case err != nil:
+			// Like the CLI, base Cymru data is still returned
+			w.Header().Set("X-Enrichment-Error", err.Error())
+		case warning != "":
+			w.Header().Set("X-Enrichment-Warning", warning)
+		}
+	}
+
+	if flat {
+		if results == nil {
+			results = []model.Result{}
+		}
+		writeJSON(w, http.StatusOK, results)
+		return
+	}
+	grouped := output.GroupResultsByASN(results, enrich)
+	if grouped == nil {
+		grouped = []output.JSONASNGroup{}
+	}
+	writeJSON(w, http.StatusOK, grouped)
+}
+
+// requestIPs extracts IPs from a JSON ({"ips": [...]} or [...]) or text body.
+func requestIPs(contentType string, body []byte) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/json" {
+		return parser.ParseIPsFromString(string(body))
+	}
+
+	var list []string
+	if err := json.Unmarshal(body, &list); err != nil {
+		var wrapped struct {
+			IPs []string `json:"ips"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: want {\"ips\": [...]} or [...]")
+		}
+		list = wrapped.IPs
+	}
+
+	seen := make(map[string]struct{}, len(list))
+	ips := make([]string, 0, len(list))
+	for _, item := range list {
+		addr, err := netip.ParseAddr(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q", item)
+		}
+		canonical := addr.Unmap().String()
+		if _, exists := seen[canonical]; exists {
+			continue
+		}
+		seen[canonical] = struct{}{}
+		ips = append(ips, canonical)
+	}
+	return ips, nil
+}
+
+func flatFormat(value string) (bool, error) {
+	switch value {
+	case "", "grouped":
+		return false, nil
+	case "flat":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown format %q (want grouped or flat)", value)
+	}
+}
+
+func queryBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func upstreamStatus(ctx context.Context, err error) int {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(value)
+}
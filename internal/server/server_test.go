@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+type fakeClient struct {
+	mu      sync.Mutex
+	lookups [][]string
+	delay   time.Duration
+	risk    int
+}
+
+func (f *fakeClient) Lookup(ctx context.Context, ips []string) ([]model.Result, error) {
+	f.mu.Lock()
+	f.lookups = append(f.lookups, ips)
+	f.mu.Unlock()
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	results := make([]model.Result, 0, len(ips))
+	for idx, ip := range ips {
+		results = append(results, model.Result{
+			ASN:    model.ASN(64500 + idx),
+			IP:     netip.MustParseAddr(ip),
+			ASName: "TEST-NET",
+			Method: model.MethodWhois,
+		})
+	}
+	return results, nil
+}
+
+func (f *fakeClient) LookupASN(_ context.Context, asn model.ASN) (model.ASInfo, error) {
+	if asn == 64999 {
+		return model.ASInfo{}, errors.New("no TXT for AS64999.asn.cymru.com")
+	}
+	return model.ASInfo{ASN: asn, CC: "US", Registry: "arin", ASName: "TEST-NET"}, nil
+}
+
+func (f *fakeClient) Enrich(_ context.Context, results []model.Result) (string, error) {
+	for idx := range results {
+		results[idx].ProxyCheck = &model.ProxyCheck{Risk: &f.risk}
+	}
+	return "near daily query limit", nil
+}
+
+func TestGetIPReturnsGroupedAndFlatShapes(t *testing.T) {
+	client := &fakeClient{}
+	handler := New(client, Config{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/ip/198.51.100.7", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var grouped []output.JSONASNGroup
+	if err := json.Unmarshal(rec.Body.Bytes(), &grouped); err != nil {
+		t.Fatalf("decode grouped: %v", err)
+	}
+	if len(grouped) != 1 || grouped[0].ASN != 64500 || grouped[0].IPs[0].IP.String() != "198.51.100.7" {
+		t.Fatalf("unexpected grouped response: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/ip/198.51.100.7?format=flat", nil))
+	var flat []model.Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &flat); err != nil {
+		t.Fatalf("decode flat: %v", err)
+	}
+	if len(flat) != 1 || flat[0].Method != model.MethodWhois {
+		t.Fatalf("unexpected flat response: %s", rec.Body)
+	}
+	// The fake answers without a prefix, like unannounced space
+	if !strings.Contains(rec.Body.String(), `"bgp_prefix": "NA"`) || flat[0].BGPPrefix.IsValid() {
+		t.Fatalf("expected an unannounced prefix as \"NA\": %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/ip/not-an-ip", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid IP") {
+		t.Fatalf("expected 400 for invalid IP, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPostLookupAcceptsTextAndJSONBodies(t *testing.T) {
+	client := &fakeClient{}
+	handler := New(client, Config{MaxIPs: 2}).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/lookup", strings.NewReader("seen 203.0.113.7 and 203.0.113.7, 2001:db8::1"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for text body, got %d: %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/lookup?format=flat", strings.NewReader(`{"ips":["198.51.100.1"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for JSON body, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if len(client.lookups) != 2 || strings.Join(client.lookups[0], ",") != "203.0.113.7,2001:db8::1" || strings.Join(client.lookups[1], ",") != "198.51.100.1" {
+		t.Fatalf("unexpected lookups: %v", client.lookups)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/lookup", strings.NewReader(`["198.51.100.1","198.51.100.2","198.51.100.3"]`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 over the IP limit, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEnrichRequiresConfiguration(t *testing.T) {
+	client := &fakeClient{risk: 81}
+
+	rec := httptest.NewRecorder()
+	New(client, Config{}).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/ip/198.51.100.7?enrich=true", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without enrichment configured, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	New(client, Config{Enrich: true}).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/ip/198.51.100.7?enrich=true", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"risk": 81`) {
+		t.Fatalf("expected enriched response, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("X-Enrichment-Warning"); got != "near daily query limit" {
+		t.Fatalf("expected enrichment warning header, got %q", got)
+	}
+}
+
+func TestGetASN(t *testing.T) {
+	handler := New(&fakeClient{}, Config{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/asn/AS13335", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"asn": 13335`) {
+		t.Fatalf("expected AS info, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/asn/64999", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 on upstream failure, got %d", rec.Code)
+	}
+}
+
+func TestLookupTimeoutReturnsGatewayTimeout(t *testing.T) {
+	handler := New(&fakeClient{delay: time.Second}, Config{RequestTimeout: 20 * time.Millisecond}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/ip/198.51.100.7", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServeReportsReadinessAndShutsDownGracefully(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := New(&fakeClient{delay: 100 * time.Millisecond}, Config{})
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+
+	base := "http://" + ln.Addr().String()
+	waitFor(t, func() bool {
+		resp, err := http.Get(base + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+
+	// An in-flight request must complete despite shutdown starting
+	inFlight := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(base + "/v1/ip/198.51.100.7")
+		if err != nil {
+			inFlight <- 0
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp.StatusCode
+	}()
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+
+	if status := <-inFlight; status != http.StatusOK {
+		t.Fatalf("expected in-flight request to finish with 200, got %d", status)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+	if srv.ready.Load() {
+		t.Fatal("expected server to report not ready after shutdown")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model"
+)
+
+const (
+	whoisReadTimeout = 30 * time.Second
+	whoisMaxLine     = 4096
+)
+
+// WhoisServer speaks the Team Cymru WHOIS protocol on port 43, so scripts
+// written against whois.cymru.com can point at a local mirror instead.
+//
+// A session is either a single query line (" -v 1.1.1.1") answered before the
+// connection closes, or a bulk query:
+//
+//	begin
+//	verbose
+//	1.1.1.1
+//	8.8.8.8
+//	end
+//
+// Bulk options are verbose, header and noheader. Answers keep the query order
+// and use the same pipe-separated rows as Team Cymru, so cymru.LookupWhoisBulk
+// parses a mirror's verbose output unchanged. Lookups from every session share
+// the coalescer and upstream rate limit, like the HTTP API.
+//
+// There is no offline dataset: answers come from the Client, which serves
+// what it can from its lookup cache and queries Team Cymru for the rest.
+type WhoisServer struct {
+	cfg       Config
+	coalescer *coalescer
+}
+
+// whoisQuery is one parsed session.
+type whoisQuery struct {
+	verbose bool
+	header  bool
+	lines   []whoisLine
+}
+
+// whoisLine is a query line: an IP to answer or an error to echo in place.
+type whoisLine struct {
+	ip  string
+	err string
+}
+
+// NewWhois returns a WhoisServer with defaults filled in for unset Config
+// fields. Only the lookup, timeout, limit and upstream settings apply.
+func NewWhois(client Client, cfg Config) *WhoisServer {
+	cfg = cfg.withDefaults()
+	return &WhoisServer{
+		cfg:       cfg,
+		coalescer: newCoalescer(client.Lookup, cfg.CoalesceWindow, cfg.MaxBatch, cfg.RequestTimeout, cfg.limiter(), cfg.UpstreamPerIP),
+	}
+}
+
+// ListenAndServe listens on cfg.Addr and serves until ctx is done.
+func (s *WhoisServer) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts sessions on ln until ctx is done, then stops accepting and
+// waits up to ShutdownTimeout for open sessions before closing them.
+func (s *WhoisServer) Serve(ctx context.Context, ln net.Listener) error {
+	return serveConns(ctx, ln, s.cfg.ShutdownTimeout, s.serveConn)
+}
+
+func (s *WhoisServer) serveConn(ctx context.Context, conn net.Conn) {
+	query, err := s.readQuery(conn)
+	if err != nil {
+		if errors.Is(err, errTooManyIPs) {
+			fmt.Fprintf(conn, "Error: %v\n", err)
+		}
+		return
+	}
+	if len(query.lines) == 0 {
+		return
+	}
+
+	// The session outlives shutdown for up to ShutdownTimeout
+	lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.RequestTimeout)
+	defer cancel()
+
+	ips := make([]string, 0, len(query.lines))
+	seen := make(map[string]struct{}, len(query.lines))
+	for _, line := range query.lines {
+		if line.ip == "" {
+			continue
+		}
+		if _, exists := seen[line.ip]; !exists {
+			seen[line.ip] = struct{}{}
+			ips = append(ips, line.ip)
+		}
+	}
+	var results []model.Result
+	if len(ips) > 0 {
+		results, err = s.coalescer.Lookup(lookupCtx, ips)
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(s.cfg.RequestTimeout))
+	w := bufio.NewWriter(conn)
+	defer w.Flush()
+	if err != nil {
+		fmt.Fprintf(w, "Error: lookup failed: %v\n", err)
+		return
+	}
+	writeWhoisResponse(w, query, results, time.Now().UTC())
+}
+
+var errTooManyIPs = errors.New("too many IPs in query")
+
+// readQuery reads one session's query. A bulk query without "end" is
+// answered when the client closes its side.
+func (s *WhoisServer) readQuery(conn net.Conn) (whoisQuery, error) {
+	query := whoisQuery{header: true}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 256), whoisMaxLine)
+
+	bulk := false
+	ips := 0
+	for lineNo := 1; ; lineNo++ {
+		_ = conn.SetReadDeadline(time.Now().Add(whoisReadTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if !bulk {
+			if strings.EqualFold(text, "begin") {
+				bulk = true
+				continue
+			}
+			// Single query: flags and IPs on one line
+			for _, token := range strings.Fields(text) {
+				if token == "-v" {
+					query.verbose = true
+					continue
+				}
+				query.lines = append(query.lines, parseWhoisLine(token, lineNo))
+			}
+			return query, nil
+		}
+
+		switch strings.ToLower(text) {
+		case "end":
+			return query, nil
+		case "verbose":
+			query.verbose = true
+			continue
+		case "header":
+			query.header = true
+			continue
+		case "noheader":
+			query.header = false
+			continue
+		}
+		// Anything after the IP, such as a timestamp, is ignored
+		line := parseWhoisLine(strings.Fields(text)[0], lineNo)
+		if line.ip != "" {
+			ips++
+			if ips > s.cfg.MaxIPs {
+				return whoisQuery{}, fmt.Errorf("%w; the limit is %d", errTooManyIPs, s.cfg.MaxIPs)
+			}
+		}
+		query.lines = append(query.lines, line)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		if bulk && len(query.lines) > 0 {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return query, nil
+			}
+		}
+		return whoisQuery{}, err
+	}
+	return query, nil
+}
+
+func parseWhoisLine(token string, lineNo int) whoisLine {
+	addr, err := netip.ParseAddr(token)
+	if err != nil {
+		return whoisLine{err: fmt.Sprintf("Error: no ASN or IP match on line %d.", lineNo)}
+	}
+	return whoisLine{ip: addr.Unmap().String()}
+}
+
+// writeWhoisResponse writes results in Team Cymru's layout: one row per
+// origin, so MOAS prefixes produce several rows for an IP.
+func writeWhoisResponse(w io.Writer, query whoisQuery, results []model.Result, now time.Time) {
+	byIP := make(map[string][]model.Result, len(results))
+	for _, result := range results {
+		ip := result.IPString()
+		byIP[ip] = append(byIP[ip], result)
+	}
+
+	fmt.Fprintf(w, "Bulk mode; ip2asn [%s]\n", now.Format("2006-01-02 15:04:05 -0700"))
+	if query.header {
+		if query.verbose {
+			fmt.Fprintf(w, "%-7s | %-16s | %-19s | %-2s | %-8s | %-10s | %s\n", "AS", "IP", "BGP Prefix", "CC", "Registry", "Allocated", "AS Name")
+		} else {
+			fmt.Fprintf(w, "%-7s | %-16s | %s\n", "AS", "IP", "AS Name")
+		}
+	}
+
+	for _, line := range query.lines {
+		if line.err != "" {
+			fmt.Fprintln(w, line.err)
+			continue
+		}
+		ipResults := byIP[line.ip]
+		if len(ipResults) == 0 {
+			// Unrouted: Team Cymru answers with NA rows too
+			ipResults = []model.Result{{ASN: model.ASNUnknown}}
+		}
+		for _, result := range ipResults {
+			prefix := "NA"
+			if result.BGPPrefix.IsValid() {
+				prefix = result.BGPPrefix.String()
+			}
+			for _, origin := range result.AllOrigins() {
+				name := origin.ASName
+				if name == "" {
+					name = "NA"
+				}
+				if query.verbose {
+					fmt.Fprintf(w, "%-7s | %-16s | %-19s | %-2s | %-8s | %-10s | %s\n",
+						origin.ASN, line.ip, prefix, result.CC, result.Registry, result.Allocated, name)
+				} else {
+					fmt.Fprintf(w, "%-7s | %-16s | %s\n", origin.ASN, line.ip, name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/cymru"
+	"github.com/hink/ip2asn/internal/model"
+)
+
+func startWhois(t *testing.T, client Client, cfg Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewWhois(client, cfg).Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned error: %v", err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+func whoisSession(t *testing.T, addr, query string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, query); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWhoisBulkSessionParsesWithLookupWhoisBulk(t *testing.T) {
+	client := &fakeClient{}
+	addr := startWhois(t, client, Config{})
+
+	resp := whoisSession(t, addr, "begin\r\nverbose\r\n198.51.100.7\r\nnot-an-ip\r\n2001:db8::1 2024-01-01 comment\r\n198.51.100.7\r\nend\r\n")
+	lines := strings.Split(strings.TrimSpace(resp), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected banner, header, 3 rows and an error, got:\n%s", resp)
+	}
+	if !strings.HasPrefix(lines[0], "Bulk mode;") || !strings.HasPrefix(lines[1], "AS ") {
+		t.Fatalf("unexpected banner/header:\n%s", resp)
+	}
+	if lines[3] != "Error: no ASN or IP match on line 4." {
+		t.Fatalf("expected error echoed in place, got %q", lines[3])
+	}
+	if len(client.lookups) != 1 || strings.Join(client.lookups[0], ",") != "198.51.100.7,2001:db8::1" {
+		t.Fatalf("expected one de-duplicated upstream lookup, got %v", client.lookups)
+	}
+
+	results := cymru.ParseWhoisBulk(strings.NewReader(resp), time.Time{})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 parsed results, got %+v", results)
+	}
+	if results[0].IPString() != "198.51.100.7" || results[0].ASN != 64500 || results[0].ASName != "TEST-NET" {
+		t.Fatalf("unexpected first result: %+v", results[0])
+	}
+	if results[1].IPString() != "2001:db8::1" || results[1].ASN != 64501 {
+		t.Fatalf("unexpected second result: %+v", results[1])
+	}
+}
+
+func TestWhoisSingleQueryAndOptions(t *testing.T) {
+	addr := startWhois(t, &fakeClient{}, Config{})
+
+	resp := whoisSession(t, addr, " -v 198.51.100.7\n")
+	if !strings.Contains(resp, "64500   | 198.51.100.7     | NA ") {
+		t.Fatalf("unexpected verbose single query response:\n%s", resp)
+	}
+
+	resp = whoisSession(t, addr, "begin\nnoheader\n198.51.100.7\nend\n")
+	lines := strings.Split(strings.TrimSpace(resp), "\n")
+	if len(lines) != 2 || lines[1] != "64500   | 198.51.100.7     | TEST-NET" {
+		t.Fatalf("unexpected short-form response:\n%s", resp)
+	}
+}
+
+func TestWhoisRejectsOversizedQueries(t *testing.T) {
+	client := &fakeClient{}
+	addr := startWhois(t, client, Config{MaxIPs: 1})
+
+	resp := whoisSession(t, addr, "begin\n198.51.100.7\n198.51.100.8\nend\n")
+	if !strings.HasPrefix(resp, "Error: too many IPs in query") {
+		t.Fatalf("expected limit error, got:\n%s", resp)
+	}
+	if len(client.lookups) != 0 {
+		t.Fatalf("expected no upstream lookups, got %v", client.lookups)
+	}
+}
+
+func TestWriteWhoisResponseRoundTripsMOAS(t *testing.T) {
+	result := model.Result{
+		ASN:       64500,
+		IP:        netip.MustParseAddr("192.0.2.1"),
+		BGPPrefix: netip.MustParsePrefix("192.0.2.0/24"),
+		CC:        "US",
+		Registry:  "arin",
+		Allocated: model.MustParseDate("2010-05-01"),
+		ASName:    "FIRST, US",
+		Origins: []model.Origin{
+			{ASN: 64500, ASName: "FIRST, US"},
+			{ASN: 64501, ASName: "SECOND, US"},
+		},
+		MOAS: true,
+	}
+	query := whoisQuery{verbose: true, header: true, lines: []whoisLine{{ip: "192.0.2.1"}, {ip: "192.0.2.99"}}}
+
+	var buf bytes.Buffer
+	writeWhoisResponse(&buf, query, []model.Result{result}, time.Now())
+	if rows := strings.Count(buf.String(), "| 192.0.2.1 "); rows != 2 {
+		t.Fatalf("expected one row per origin, got:\n%s", buf.String())
+	}
+
+	parsed := cymru.ParseWhoisBulk(&buf, time.Time{})
+	if len(parsed) != 2 {
+		t.Fatalf("expected 2 results, got %+v", parsed)
+	}
+	got := parsed[0]
+	if !got.MOAS || len(got.Origins) != 2 || got.Origins[1].ASName != "SECOND, US" {
+		t.Fatalf("expected MOAS origins to survive, got %+v", got)
+	}
+	if got.BGPPrefix != result.BGPPrefix || got.CC != "US" || got.Registry != "arin" || got.Allocated != result.Allocated {
+		t.Fatalf("unexpected fields: %+v", got)
+	}
+	if parsed[1].ASN.Known() || parsed[1].BGPPrefix.IsValid() {
+		t.Fatalf("expected unrouted IP to parse as NA, got %+v", parsed[1])
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"net/netip"
 	"testing"
 
-	"ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/model"
 )
 
 func TestSortResults(t *testing.T) {
@@ -25,36 +25,36 @@ func TestSortResults(t *testing.T) {
 		{
 			name: "sort by asn",
 			input: []model.Result{
-				{ASN: 200, IP: "2.2.2.2", IPAddr: mustIP("2.2.2.2")},
-				{ASN: 100, IP: "1.1.1.1", IPAddr: mustIP("1.1.1.1")},
+				{ASN: 200, IP: mustIP("2.2.2.2")},
+				{ASN: 100, IP: mustIP("1.1.1.1")},
 			},
 			want: []model.Result{
-				{ASN: 100, IP: "1.1.1.1", IPAddr: mustIP("1.1.1.1")},
-				{ASN: 200, IP: "2.2.2.2", IPAddr: mustIP("2.2.2.2")},
+				{ASN: 100, IP: mustIP("1.1.1.1")},
+				{ASN: 200, IP: mustIP("2.2.2.2")},
 			},
 		},
 		{
 			name: "sort by ip when asn same",
 			input: []model.Result{
-				{ASN: 100, IP: "10.0.0.2", IPAddr: mustIP("10.0.0.2")},
-				{ASN: 100, IP: "10.0.0.1", IPAddr: mustIP("10.0.0.1")},
+				{ASN: 100, IP: mustIP("10.0.0.2")},
+				{ASN: 100, IP: mustIP("10.0.0.1")},
 			},
 			want: []model.Result{
-				{ASN: 100, IP: "10.0.0.1", IPAddr: mustIP("10.0.0.1")},
-				{ASN: 100, IP: "10.0.0.2", IPAddr: mustIP("10.0.0.2")},
+				{ASN: 100, IP: mustIP("10.0.0.1")},
+				{ASN: 100, IP: mustIP("10.0.0.2")},
 			},
 		},
         {
 			name: "sort mixed ipv4 ipv6 same asn",
 			input: []model.Result{
-				{ASN: 100, IP: "2001::1", IPAddr: mustIP("2001::1")},
-				{ASN: 100, IP: "1.1.1.1", IPAddr: mustIP("1.1.1.1")},
+				{ASN: 100, IP: mustIP("2001::1")},
+				{ASN: 100, IP: mustIP("1.1.1.1")},
 			},
 			// IPv4 maps to ::ffff:1.1.1.1 or similar in comparison?
             // netip.Addr Compare: IPv4 compares less than IPv6.
 			want: []model.Result{
-				{ASN: 100, IP: "1.1.1.1", IPAddr: mustIP("1.1.1.1")},
-				{ASN: 100, IP: "2001::1", IPAddr: mustIP("2001::1")},
+				{ASN: 100, IP: mustIP("1.1.1.1")},
+				{ASN: 100, IP: mustIP("2001::1")},
 			},
 		},
 	}
@@ -78,3 +78,16 @@ func TestSortResults(t *testing.T) {
 		})
 	}
 }
+
+func TestSortResultsPlacesUnknownASNLast(t *testing.T) {
+	results := []model.Result{
+		{ASN: model.ASNUnknown, IP: netip.MustParseAddr("10.0.0.1")},
+		{ASN: 64500, IP: netip.MustParseAddr("192.0.2.1")},
+	}
+
+	SortResults(results)
+
+	if results[0].ASN != 64500 || results[1].ASN.Known() {
+		t.Fatalf("expected unknown ASN to sort last, got %v then %v", results[0].ASN, results[1].ASN)
+	}
+}
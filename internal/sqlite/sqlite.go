@@ -0,0 +1,281 @@
+// Package sqlite appends lookup runs to a SQLite database for ad-hoc SQL
+// analysis. It uses a pure-Go driver, so builds need no cgo.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	// Registers the "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+// SchemaVersion is stored in the database's user_version; Append refuses
+// databases written with another schema.
+const SchemaVersion = 1
+
+// schema creates the tables on first use. Runs are append-only; asns and
+// prefixes hold the latest Team Cymru data for each AS and prefix, and ips
+// and proxycheck hold each run's results.
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id               INTEGER PRIMARY KEY,
+	version          TEXT    NOT NULL,
+	generated        TEXT    NOT NULL,
+	source           TEXT,
+	enriched         INTEGER NOT NULL,
+	partial          TEXT,
+	enrichment_error TEXT,
+	results          INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS asns (
+	asn  INTEGER PRIMARY KEY,
+	name TEXT
+);
+CREATE TABLE IF NOT EXISTS prefixes (
+	id        INTEGER PRIMARY KEY,
+	prefix    TEXT NOT NULL UNIQUE,
+	cc        TEXT,
+	registry  TEXT,
+	allocated TEXT
+);
+CREATE TABLE IF NOT EXISTS prefix_origins (
+	prefix_id INTEGER NOT NULL REFERENCES prefixes(id),
+	asn       INTEGER NOT NULL REFERENCES asns(asn),
+	PRIMARY KEY (prefix_id, asn)
+);
+CREATE TABLE IF NOT EXISTS ips (
+	run_id     INTEGER NOT NULL REFERENCES runs(id),
+	ip         TEXT    NOT NULL,
+	ip_version INTEGER NOT NULL,
+	asn        INTEGER REFERENCES asns(asn),
+	prefix_id  INTEGER REFERENCES prefixes(id),
+	moas       INTEGER NOT NULL,
+	method     TEXT,
+	retrieved  TEXT,
+	PRIMARY KEY (run_id, ip)
+);
+CREATE INDEX IF NOT EXISTS ips_ip ON ips (ip);
+CREATE INDEX IF NOT EXISTS ips_asn ON ips (asn);
+CREATE TABLE IF NOT EXISTS proxycheck (
+	run_id       INTEGER NOT NULL,
+	ip           TEXT    NOT NULL,
+	proxy        INTEGER,
+	vpn          INTEGER,
+	compromised  INTEGER,
+	hosting      INTEGER,
+	tor          INTEGER,
+	risk         INTEGER,
+	vpn_provider TEXT,
+	city         TEXT,
+	state        TEXT,
+	country      TEXT,
+	PRIMARY KEY (run_id, ip),
+	FOREIGN KEY (run_id, ip) REFERENCES ips (run_id, ip)
+);
+CREATE VIEW IF NOT EXISTS results AS
+SELECT
+	i.run_id, r.generated, i.ip, i.asn, a.name AS as_name, p.prefix, p.cc,
+	p.registry, p.allocated, i.moas, i.method, i.retrieved,
+	c.proxy, c.vpn, c.compromised, c.hosting, c.tor, c.risk,
+	c.vpn_provider, c.city, c.state, c.country
+FROM ips i
+JOIN runs r ON r.id = i.run_id
+LEFT JOIN asns a ON a.asn = i.asn
+LEFT JOIN prefixes p ON p.id = i.prefix_id
+LEFT JOIN proxycheck c ON c.run_id = i.run_id AND c.ip = i.ip;
+`
+
+// Append adds results as a new run to the database at path, creating it and
+// its tables when needed, and returns the run's ID. The whole run is written
+// in one transaction, so a failure leaves the database as it was.
+func Append(ctx context.Context, path string, results []model.Result, run output.RunInfo) (int64, error) {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := migrate(ctx, db); err != nil {
+		return 0, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	runID, err := insertRun(ctx, tx, results, run)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return runID, nil
+}
+
+// migrate creates the schema in a new database and checks the version of an
+// existing one.
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	switch version {
+	case SchemaVersion:
+		return nil
+	case 0:
+		if _, err := db.ExecContext(ctx, schema); err != nil {
+			return fmt.Errorf("create tables: %w", err)
+		}
+		_, err := db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion))
+		return err
+	default:
+		return fmt.Errorf("database has schema version %d; this ip2asn writes version %d", version, SchemaVersion)
+	}
+}
+
+func insertRun(ctx context.Context, tx *sql.Tx, results []model.Result, run output.RunInfo) (int64, error) {
+	generated := run.Generated
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO runs (version, generated, source, enriched, partial, enrichment_error, results) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		run.Version, generated.UTC().Format(time.RFC3339), nullString(run.Source), run.Enriched,
+		nullString(run.Partial), nullString(run.EnrichmentError), len(results))
+	if err != nil {
+		return 0, fmt.Errorf("insert run: %w", err)
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmts, err := prepare(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	defer stmts.close()
+	for _, r := range results {
+		if err := stmts.insertResult(ctx, runID, r); err != nil {
+			return 0, fmt.Errorf("insert %s: %w", r.IPString(), err)
+		}
+	}
+	return runID, nil
+}
+
+type statements struct {
+	asn, prefix, prefixID, origin, ip, proxycheck *sql.Stmt
+}
+
+func prepare(ctx context.Context, tx *sql.Tx) (*statements, error) {
+	s := &statements{}
+	for _, p := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		// Keep the last known name when a result has none
+		{&s.asn, `INSERT INTO asns (asn, name) VALUES (?, ?) ON CONFLICT (asn) DO UPDATE SET name = coalesce(excluded.name, name)`},
+		{&s.prefix, `INSERT INTO prefixes (prefix, cc, registry, allocated) VALUES (?, ?, ?, ?)
+			ON CONFLICT (prefix) DO UPDATE SET cc = excluded.cc, registry = excluded.registry, allocated = excluded.allocated`},
+		{&s.prefixID, `SELECT id FROM prefixes WHERE prefix = ?`},
+		{&s.origin, `INSERT OR IGNORE INTO prefix_origins (prefix_id, asn) VALUES (?, ?)`},
+		{&s.ip, `INSERT OR IGNORE INTO ips (run_id, ip, ip_version, asn, prefix_id, moas, method, retrieved) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`},
+		{&s.proxycheck, `INSERT OR IGNORE INTO proxycheck (run_id, ip, proxy, vpn, compromised, hosting, tor, risk, vpn_provider, city, state, country)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+	} {
+		stmt, err := tx.PrepareContext(ctx, p.query)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		*p.stmt = stmt
+	}
+	return s, nil
+}
+
+func (s *statements) close() {
+	for _, stmt := range []*sql.Stmt{s.asn, s.prefix, s.prefixID, s.origin, s.ip, s.proxycheck} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+func (s *statements) insertResult(ctx context.Context, runID int64, r model.Result) error {
+	for _, origin := range r.AllOrigins() {
+		if !origin.ASN.Known() {
+			continue
+		}
+		if _, err := s.asn.ExecContext(ctx, int64(origin.ASN), nullString(origin.ASName)); err != nil {
+			return err
+		}
+	}
+
+	var prefixID sql.NullInt64
+	if r.BGPPrefix.IsValid() {
+		if _, err := s.prefix.ExecContext(ctx, r.BGPPrefix.String(), nullString(r.CC), nullString(r.Registry), nullString(r.Allocated.String())); err != nil {
+			return err
+		}
+		if err := s.prefixID.QueryRowContext(ctx, r.BGPPrefix.String()).Scan(&prefixID); err != nil {
+			return err
+		}
+		for _, origin := range r.AllOrigins() {
+			if !origin.ASN.Known() {
+				continue
+			}
+			if _, err := s.origin.ExecContext(ctx, prefixID, int64(origin.ASN)); err != nil {
+				return err
+			}
+		}
+	}
+
+	var asn sql.NullInt64
+	if r.ASN.Known() {
+		asn = sql.NullInt64{Int64: int64(r.ASN), Valid: true}
+	}
+	ipVersion := 4
+	if r.IP.Is6() && !r.IP.Is4In6() {
+		ipVersion = 6
+	}
+	var retrieved sql.NullString
+	if !r.Retrieved.IsZero() {
+		retrieved = sql.NullString{String: r.Retrieved.UTC().Format(time.RFC3339), Valid: true}
+	}
+	if _, err := s.ip.ExecContext(ctx, runID, r.IPString(), ipVersion, asn, prefixID, r.MOAS, nullString(r.Method.String()), retrieved); err != nil {
+		return err
+	}
+
+	pc := r.ProxyCheck
+	if pc == nil || pc.IsEmpty() {
+		return nil
+	}
+	var risk sql.NullInt64
+	if pc.Risk != nil {
+		risk = sql.NullInt64{Int64: int64(*pc.Risk), Valid: true}
+	}
+	_, err := s.proxycheck.ExecContext(ctx, runID, r.IPString(),
+		nullBool(pc.Proxy), nullBool(pc.VPN), nullBool(pc.Compromised), nullBool(pc.Hosting), nullBool(pc.TOR),
+		risk, nullString(pc.VPNProvider), nullString(pc.City), nullString(pc.State), nullString(pc.Country))
+	return err
+}
+
+// nullString stores "" as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullBool(b *bool) sql.NullBool {
+	if b == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *b, Valid: true}
+}
@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hink/ip2asn/internal/model/modeltest"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.db")
+	ctx := context.Background()
+	run := output.RunInfo{Version: "9.9", Generated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Enriched: true, Source: "iocs.txt"}
+
+	for want := int64(1); want <= 2; want++ {
+		runID, err := Append(ctx, path, modeltest.Results(), run)
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if runID != want {
+			t.Fatalf("expected run %d, got %d", want, runID)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	counts := []struct {
+		query string
+		want  int
+	}{
+		{"SELECT count(*) FROM runs WHERE source = 'iocs.txt' AND enriched AND results = 3", 2},
+		{"SELECT count(*) FROM ips", 6},
+		{"SELECT count(*) FROM ips WHERE run_id = 2 AND asn IS NULL AND prefix_id IS NULL", 1},
+		{"SELECT count(*) FROM ips WHERE ip_version = 6 AND moas", 2},
+		{"SELECT count(*) FROM asns", 3},
+		{"SELECT count(*) FROM prefixes", 2},
+		{"SELECT count(*) FROM prefix_origins", 3},
+		{"SELECT count(*) FROM proxycheck WHERE vpn AND proxy IS NULL AND risk = 80 AND vpn_provider = 'IVPN'", 2},
+		{"SELECT count(*) FROM results WHERE as_name = 'TEST-NET' AND prefix = '203.0.113.0/24' AND cc = 'US' AND allocated = '2020-01-01' AND risk = 80", 2},
+	}
+	for _, c := range counts {
+		var got int
+		if err := db.QueryRow(c.query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s: got %d, want %d", c.query, got, c.want)
+		}
+	}
+}
+
+func TestAppendRejectsOtherSchemaVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec("PRAGMA user_version = 99"); err != nil {
+		t.Fatalf("set version: %v", err)
+	}
+	db.Close()
+
+	_, err = Append(context.Background(), path, modeltest.Results(), output.RunInfo{Version: "9.9"})
+	if err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Fatalf("expected a schema version error, got %v", err)
+	}
+}
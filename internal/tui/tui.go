@@ -9,8 +9,8 @@ import (
 	tea "charm.land/bubbletea/v2"
 	"github.com/jedib0t/go-pretty/v6/text"
 
-	"ip2asn/internal/model"
-	"ip2asn/internal/output"
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
 )
 
 // Run starts the interactive table TUI.
@@ -1,14 +1,15 @@
 package tui
 
 import (
+	"net/netip"
 	"strings"
 	"testing"
 
 	tea "charm.land/bubbletea/v2"
 	"github.com/jedib0t/go-pretty/v6/text"
 
-	"ip2asn/internal/model"
-	"ip2asn/internal/output"
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
 )
 
 func TestModelResizeRendersTableAndFooter(t *testing.T) {
@@ -18,8 +19,8 @@ func TestModelResizeRendersTableAndFooter(t *testing.T) {
 	m := newModel([]model.Result{
 		{
 			ASN:       64500,
-			IP:        "203.0.113.7",
-			BGPPrefix: "203.0.113.0/24",
+			IP:        netip.MustParseAddr("203.0.113.7"),
+			BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"),
 			ASName:    "TEST-NET",
 			ProxyCheck: &model.ProxyCheck{
 				VPN:         &trueValue,
@@ -0,0 +1,35 @@
+package ip2asn
+
+import "time"
+
+// Default time budgets, used unless WithTimeout or WithEnrichTimeout fixes
+// them. A budget grows with the work a call does: each session (a bulk
+// WHOIS connection, a DNS lookup or a proxycheck.io batch) and each IP in it.
+const (
+	minBudget       = 8 * time.Second
+	sessionBudget   = 4 * time.Second
+	perIPBudget     = 5 * time.Millisecond
+	proxycheckBatch = 1000
+	adaptiveTimeout = time.Duration(-1) // Marks a budget still to be derived
+)
+
+// lookupBudget is the default time allowed to look up n IPs with backend.
+func lookupBudget(backend Backend, n int) time.Duration {
+	sessions := 1 // One bulk WHOIS session
+	switch {
+	case backend == BackendDNS:
+		sessions = n
+	case backend == BackendAuto && n == 1:
+		sessions = 2 // DNS, then the WHOIS fallback
+	}
+	return budget(sessions, n)
+}
+
+// enrichBudget is the default time allowed to enrich n IPs.
+func enrichBudget(n int) time.Duration {
+	return budget((n+proxycheckBatch-1)/proxycheckBatch, n)
+}
+
+func budget(sessions, n int) time.Duration {
+	return max(minBudget, time.Duration(sessions)*sessionBudget+time.Duration(n)*perIPBudget)
+}
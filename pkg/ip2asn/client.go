@@ -0,0 +1,333 @@
+package ip2asn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hink/ip2asn/internal/cymru"
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/proxycheck"
+	"github.com/hink/ip2asn/internal/sortutil"
+)
+
+// Backend selects which Team Cymru interface a Client queries.
+type Backend int
+
+const (
+	// BackendAuto uses DNS for a single IP and one bulk WHOIS session for
+	// two or more, per Team Cymru's guidance.
+	BackendAuto Backend = iota
+	// BackendDNS resolves every IP over the DNS interface.
+	BackendDNS
+	// BackendWhois sends every lookup through one bulk WHOIS session.
+	BackendWhois
+)
+
+// ErrEnrichmentDisabled is returned by Enrich when the client has no
+// proxycheck.io API key.
+var ErrEnrichmentDisabled = errors.New("proxycheck enrichment is not configured")
+
+// Cache stores lookup results between Lookup calls. Get must only report
+// entries that are still fresh at now.
+type Cache interface {
+	Get(ip string, now time.Time) ([]Result, bool)
+	Put(results []Result, now time.Time)
+}
+
+// Client performs IP to ASN lookups and optional enrichment.
+//
+// A Client is safe for concurrent use once constructed.
+type Client struct {
+	backend       Backend
+	timeout       time.Duration
+	enrichTimeout time.Duration
+	proxycheck    *proxycheck.Client
+	httpClient    *http.Client
+	cache         Cache
+	onFallback    func(error)
+	onProgress    func(Progress)
+	cymruOpts     []cymru.Option
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBackend selects the lookup backend. The default is BackendAuto.
+func WithBackend(backend Backend) Option {
+	return func(c *Client) {
+		c.backend = backend
+	}
+}
+
+// WithTimeout bounds each Lookup call. Zero or negative disables the bound,
+// leaving the caller's context; a WHOIS server that sends nothing for 10s
+// still ends its session. By default the bound scales with the
+// work: at least 8s, plus 4s per session (one bulk WHOIS connection, or one
+// per IP over DNS) and 5ms per IP not answered from the cache.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = max(timeout, 0) // Negative must not read as adaptiveTimeout
+	}
+}
+
+// WithEnrichTimeout bounds each Enrich call. Zero or negative disables the
+// bound. By default it is at least 8s, plus 4s per proxycheck.io batch of
+// 1000 IPs and 5ms per IP.
+func WithEnrichTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.enrichTimeout = max(timeout, 0)
+	}
+}
+
+// WithProxycheck enables proxycheck.io enrichment with apiKey.
+func WithProxycheck(apiKey string) Option {
+	return func(c *Client) {
+		c.proxycheck = proxycheck.NewClient(apiKey)
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for enrichment requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithResolver sends DNS interface queries to resolver instead of the
+// system resolver. See NewResolver for DNS-over-TLS and DNS-over-HTTPS.
+func WithResolver(resolver Resolver) Option {
+	return func(c *Client) {
+		c.cymruOpts = append(c.cymruOpts, cymru.WithResolver(resolver))
+	}
+}
+
+// WithDialer opens WHOIS connections with dialer, for example one that
+// tunnels through a SOCKS5 proxy (golang.org/x/net/proxy).
+func WithDialer(dialer Dialer) Option {
+	return func(c *Client) {
+		c.cymruOpts = append(c.cymruOpts, cymru.WithDialer(dialer))
+	}
+}
+
+// WithWhoisServer sends bulk WHOIS sessions to addr (host:port) instead of
+// whois.cymru.com:43, for example a mirror run with `ip2asn serve-whois`.
+func WithWhoisServer(addr string) Option {
+	return func(c *Client) {
+		c.cymruOpts = append(c.cymruOpts, cymru.WithWhoisAddr(addr))
+	}
+}
+
+// WithCache answers lookups from cache where possible and stores fresh
+// results in it. Only cache misses are sent to Team Cymru.
+func WithCache(cache Cache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithFallbackHandler registers fn to be called when a single-IP DNS lookup
+// fails and BackendAuto falls back to WHOIS.
+func WithFallbackHandler(fn func(err error)) Option {
+	return func(c *Client) {
+		c.onFallback = fn
+	}
+}
+
+// New returns a Client configured by opts.
+func New(opts ...Option) *Client {
+	c := &Client{
+		backend:       BackendAuto,
+		timeout:       adaptiveTimeout,
+		enrichTimeout: adaptiveTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.proxycheck != nil && c.httpClient != nil {
+		c.proxycheck.HTTPClient = c.httpClient
+	}
+	return c
+}
+
+// Lookup maps ips to origin ASN metadata. Results are sorted by ASN, then by
+// IP. ips should already be canonical and de-duplicated, as returned by
+// ParseIPs.
+//
+// On error, including cancellation of ctx, Lookup still returns the results
+// that arrived before it; they are complete rows, but some IPs are missing.
+func (c *Client) Lookup(ctx context.Context, ips []string) ([]Result, error) {
+	if len(ips) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	cached, misses := c.fromCache(ips, now)
+
+	timeout := c.timeout
+	if timeout == adaptiveTimeout {
+		timeout = lookupBudget(c.backend, len(misses))
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	progress := lookupProgress{fn: c.onProgress, total: len(ips), cached: len(ips) - len(misses)}
+	results, err := c.lookup(ctx, misses, progress)
+	if c.cache != nil {
+		c.cache.Put(results, now)
+	}
+
+	results = append(results, cached...)
+	sortutil.SortResults(results)
+	if err != nil && len(results) == 0 {
+		return nil, err
+	}
+	return results, err
+}
+
+func (c *Client) fromCache(ips []string, now time.Time) ([]model.Result, []string) {
+	if c.cache == nil {
+		return nil, ips
+	}
+	var cached []model.Result
+	misses := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if hit, ok := c.cache.Get(ip, now); ok {
+			cached = append(cached, hit...)
+			continue
+		}
+		misses = append(misses, ip)
+	}
+	return cached, misses
+}
+
+func (c *Client) lookup(ctx context.Context, ips []string, progress lookupProgress) ([]model.Result, error) {
+	if len(ips) == 0 {
+		progress.report(0, 0, 0)
+		return nil, nil
+	}
+	switch c.backend {
+	case BackendDNS:
+		return c.lookupEachDNS(ctx, ips, progress)
+	case BackendWhois:
+		return c.lookupWhois(ctx, ips, progress)
+	default:
+		return c.lookupAuto(ctx, ips, progress)
+	}
+}
+
+func (c *Client) lookupAuto(ctx context.Context, ips []string, progress lookupProgress) ([]model.Result, error) {
+	if len(ips) > 1 {
+		results, err := c.lookupWhois(ctx, ips, progress)
+		if err != nil {
+			return results, fmt.Errorf("WHOIS bulk lookup failed: %w", err)
+		}
+		return results, nil
+	}
+
+	progress.report(0, 1, 1)
+	results, err := cymru.LookupDNS(ctx, ips[0], c.cymruOpts...)
+	if err == nil {
+		progress.report(1, 1, 1)
+		return results, nil
+	}
+	if ctx.Err() != nil {
+		// Cancelled or out of time; WHOIS would fail the same way
+		return nil, err
+	}
+	// Be robust: if DNS fails, fall back to WHOIS single lookup in one TCP query
+	if c.onFallback != nil {
+		c.onFallback(err)
+	}
+	results, err = c.lookupWhois(ctx, ips, progress)
+	if err != nil {
+		return nil, fmt.Errorf("WHOIS fallback failed: %w", err)
+	}
+	return results, nil
+}
+
+// lookupWhois runs one bulk WHOIS session, reporting rows as they arrive.
+func (c *Client) lookupWhois(ctx context.Context, ips []string, progress lookupProgress) ([]model.Result, error) {
+	progress.report(0, 1, 1)
+	results, err := cymru.LookupWhoisBulk(ctx, ips, c.whoisOpts(progress)...)
+	if err == nil {
+		progress.report(len(ips), 1, 1)
+	}
+	return results, err
+}
+
+func (c *Client) lookupEachDNS(ctx context.Context, ips []string, progress lookupProgress) ([]model.Result, error) {
+	results := make([]model.Result, 0, len(ips))
+	for i, ip := range ips {
+		progress.report(i, i+1, len(ips))
+		ipResults, err := cymru.LookupDNS(ctx, ip, c.cymruOpts...)
+		if err != nil {
+			return results, fmt.Errorf("DNS lookup for %s failed: %w", ip, err)
+		}
+		results = append(results, ipResults...)
+	}
+	progress.report(len(ips), len(ips), len(ips))
+	return results, nil
+}
+
+// Enrich adds proxycheck.io data to results in place. A non-empty warning
+// reports a soft API warning (e.g. approaching the query limit). On error,
+// including cancellation of ctx, the batches that completed are still applied.
+func (c *Client) Enrich(ctx context.Context, results []Result) (warning string, err error) {
+	if c.proxycheck == nil {
+		return "", ErrEnrichmentDisabled
+	}
+	ips := uniqueResultIPs(results)
+	timeout := c.enrichTimeout
+	if timeout == adaptiveTimeout {
+		timeout = enrichBudget(len(ips))
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	pc := *c.proxycheck
+	if c.onProgress != nil {
+		pc.Progress = func(done, total, batch, batches int) {
+			c.onProgress(Progress{Stage: StageEnrich, Done: done, Total: total, Session: batch, Sessions: batches})
+		}
+	}
+	enrichments, warning, err := pc.Lookup(ctx, ips)
+	proxycheck.Apply(results, enrichments)
+	if err != nil {
+		return "", err
+	}
+	return warning, nil
+}
+
+func uniqueResultIPs(results []model.Result) []string {
+	seen := make(map[string]struct{}, len(results))
+	ips := make([]string, 0, len(results))
+	for _, result := range results {
+		ip := result.IPString()
+		if _, exists := seen[ip]; exists {
+			continue
+		}
+		seen[ip] = struct{}{}
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// LookupASN returns the registration data of asn via the DNS interface.
+func (c *Client) LookupASN(ctx context.Context, asn ASN) (ASInfo, error) {
+	timeout := c.timeout
+	if timeout == adaptiveTimeout {
+		timeout = lookupBudget(BackendDNS, 1)
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	return cymru.LookupASN(ctx, asn, c.cymruOpts...)
+}
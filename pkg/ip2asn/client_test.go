@@ -0,0 +1,215 @@
+package ip2asn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/hink/ip2asn/internal/cymru/cymrutest"
+)
+
+func TestEnrichAppliesProxycheckDataOncePerIP(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		if got := r.PostForm.Get("ips"); got != "203.0.113.7" {
+			t.Fatalf("expected de-duplicated IP list, got %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok","203.0.113.7":{"detections":{"vpn":true,"risk":66},"operator":{"name":"IVPN"}}}`))
+	}))
+	defer server.Close()
+
+	client := New(WithProxycheck("test-key"), WithHTTPClient(server.Client()))
+	client.proxycheck.BaseURL = server.URL
+
+	ip := netip.MustParseAddr("203.0.113.7")
+	results := []Result{{ASN: 64500, IP: ip}, {ASN: 64501, IP: ip}}
+	warning, err := client.Enrich(context.Background(), results)
+	if err != nil {
+		t.Fatalf("Enrich returned error: %v", err)
+	}
+	if warning != "" {
+		t.Fatalf("did not expect warning, got %q", warning)
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 proxycheck request, got %d", requests)
+	}
+	for _, result := range results {
+		if result.ProxyCheck == nil || result.ProxyCheck.VPNProvider != "IVPN" || *result.ProxyCheck.Risk != 66 {
+			t.Fatalf("expected enrichment on every row, got %+v", result.ProxyCheck)
+		}
+	}
+}
+
+func TestEnrichWithoutAPIKeyIsDisabled(t *testing.T) {
+	_, err := New().Enrich(context.Background(), []Result{{IP: netip.MustParseAddr("203.0.113.7")}})
+	if !errors.Is(err, ErrEnrichmentDisabled) {
+		t.Fatalf("expected ErrEnrichmentDisabled, got %v", err)
+	}
+}
+
+func TestLookupWithoutIPsReturnsNothing(t *testing.T) {
+	results, err := New().Lookup(context.Background(), nil)
+	if err != nil || results != nil {
+		t.Fatalf("expected no results and no error, got %v, %v", results, err)
+	}
+}
+
+func TestLookupFallsBackToWhoisWhenDNSFails(t *testing.T) {
+	dns := cymrutest.NewDNSServer(t, map[string]cymrutest.TXTReply{
+		"1.2.0.192.origin.asn.cymru.com": {RCode: dnsmessage.RCodeServerFailure},
+	})
+	whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: cymrutest.BulkResponse(
+		cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE, US"},
+	)}))
+
+	var fallback error
+	client := New(
+		WithResolver(dns.Resolver()),
+		WithWhoisServer(whois.Addr),
+		WithFallbackHandler(func(err error) { fallback = err }),
+	)
+	results, err := client.Lookup(context.Background(), []string{"192.0.2.1"})
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if fallback == nil {
+		t.Fatal("expected the DNS failure to be reported to the fallback handler")
+	}
+	if len(results) != 1 || results[0].ASN != 64500 || results[0].Method != MethodWhois {
+		t.Fatalf("expected the WHOIS answer, got %+v", results)
+	}
+	if sessions := whois.Sessions(); len(sessions) != 1 {
+		t.Fatalf("expected one WHOIS session, got %v", sessions)
+	}
+}
+
+func TestLookupReturnsRowsReadBeforeCancellation(t *testing.T) {
+	whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{
+		Body: cymrutest.BulkResponse(cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"}),
+		Hang: true,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	results, err := New(WithWhoisServer(whois.Addr)).Lookup(ctx, []string{"192.0.2.1", "192.0.2.2"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(results) != 1 || results[0].ASN != 64500 {
+		t.Fatalf("expected the row read before cancellation, got %+v", results)
+	}
+}
+
+func TestLookupTimeoutEndsStalledWhoisSession(t *testing.T) {
+	whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{
+		Body: cymrutest.BulkResponse(cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"}),
+		Hang: true,
+	}))
+
+	start := time.Now()
+	results, err := New(WithWhoisServer(whois.Addr), WithTimeout(300*time.Millisecond)).Lookup(context.Background(), []string{"192.0.2.1", "192.0.2.2"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected the lookup timeout to end the session, took %s", elapsed)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected the row read before the deadline, got %+v", results)
+	}
+}
+
+func TestLookupReportsProgress(t *testing.T) {
+	whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: cymrutest.BulkResponse(
+		cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"},
+		cymrutest.Row{ASN: "64500", IP: "192.0.2.2", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"},
+	)}))
+	cache := mapCache{"192.0.2.3": {{ASN: 64501, IP: netip.MustParseAddr("192.0.2.3")}}}
+
+	var updates []Progress
+	client := New(WithWhoisServer(whois.Addr), WithCache(cache), WithProgress(func(p Progress) { updates = append(updates, p) }))
+	if _, err := client.Lookup(context.Background(), []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}); err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+
+	want := []Progress{
+		{Stage: StageLookup, Done: 1, Total: 3, Session: 1, Sessions: 1}, // The cache hit
+		{Stage: StageLookup, Done: 2, Total: 3, Session: 1, Sessions: 1},
+		{Stage: StageLookup, Done: 3, Total: 3, Session: 1, Sessions: 1},
+		{Stage: StageLookup, Done: 3, Total: 3, Session: 1, Sessions: 1},
+	}
+	if len(updates) != len(want) {
+		t.Fatalf("expected %d updates, got %+v", len(want), updates)
+	}
+	for i := range want {
+		if updates[i] != want[i] {
+			t.Fatalf("update %d = %+v, want %+v", i, updates[i], want[i])
+		}
+	}
+}
+
+func TestDefaultBudgetsScaleWithWork(t *testing.T) {
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{name: "single IP over DNS with WHOIS fallback", got: lookupBudget(BackendAuto, 1), want: 8*time.Second + 5*time.Millisecond},
+		{name: "small bulk session", got: lookupBudget(BackendWhois, 10), want: 8 * time.Second},
+		{name: "large bulk session", got: lookupBudget(BackendAuto, 10000), want: 54 * time.Second},
+		{name: "one DNS lookup per IP", got: lookupBudget(BackendDNS, 10), want: 40*time.Second + 50*time.Millisecond},
+		{name: "one proxycheck batch", got: enrichBudget(100), want: 8 * time.Second},
+		{name: "several proxycheck batches", got: enrichBudget(2500), want: 24*time.Second + 500*time.Millisecond},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Fatalf("%s: budget = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+type mapCache map[string][]Result
+
+func (m mapCache) Get(ip string, _ time.Time) ([]Result, bool) {
+	results, ok := m[ip]
+	return results, ok
+}
+
+func (m mapCache) Put(results []Result, _ time.Time) {
+	for _, result := range results {
+		m[result.IPString()] = append(m[result.IPString()], result)
+	}
+}
+
+func TestLookupAnswersFromCacheWithoutNetwork(t *testing.T) {
+	cache := mapCache{
+		"203.0.113.7":  {{ASN: 64501, IP: netip.MustParseAddr("203.0.113.7")}},
+		"198.51.100.1": {{ASN: 64500, IP: netip.MustParseAddr("198.51.100.1")}},
+	}
+
+	results, err := New(WithCache(cache)).Lookup(context.Background(), []string{"203.0.113.7", "198.51.100.1"})
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if len(results) != 2 || results[0].ASN != 64500 || results[1].ASN != 64501 {
+		t.Fatalf("expected sorted cached results, got %+v", results)
+	}
+}
+
+func TestNegativeTimeoutsDisableTheBound(t *testing.T) {
+	c := New(WithTimeout(-time.Nanosecond), WithEnrichTimeout(-time.Second))
+	if c.timeout != 0 || c.enrichTimeout != 0 {
+		t.Fatalf("expected negative timeouts to disable the bound, got %s and %s", c.timeout, c.enrichTimeout)
+	}
+}
@@ -0,0 +1,16 @@
+// Package ip2asn is the importable Go API behind the ip2asn command.
+//
+// It extracts IPv4/IPv6 addresses from text, maps them to origin ASN metadata
+// using Team Cymru's IP-to-ASN service, optionally enriches them with
+// proxycheck.io data, and renders the results as a table, CSV or JSON.
+//
+// A Client follows Team Cymru's usage guidance: a single IP is resolved over
+// the DNS interface, while two or more IPs are sent in one bulk WHOIS session.
+//
+// # Compatibility
+//
+// This package follows semantic versioning. Exported identifiers keep their
+// meaning and signatures within a major version; new options, fields and
+// functions may be added in minor versions. Packages under internal/ carry no
+// such guarantee and must not be relied on directly.
+package ip2asn
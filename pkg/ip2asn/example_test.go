@@ -0,0 +1,76 @@
+package ip2asn_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/hink/ip2asn/pkg/ip2asn"
+)
+
+func ExampleParseIPsFromString() {
+	ips, err := ip2asn.ParseIPsFromString("blocked 203.0.113.7, then 2001:DB8::1 and 203.0.113.7 again")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(ips)
+	// Output: [203.0.113.7 2001:db8::1]
+}
+
+func ExampleWriteCSV() {
+	results := []ip2asn.Result{
+		{
+			ASN:       64500,
+			IP:        netip.MustParseAddr("203.0.113.7"),
+			BGPPrefix: netip.MustParsePrefix("203.0.113.0/24"),
+			CC:        "US",
+			Registry:  "arin",
+			Allocated: ip2asn.Date{Year: 2020, Month: time.January, Day: 1},
+			ASName:    "TEST-NET",
+		},
+	}
+	if err := ip2asn.WriteCSV(os.Stdout, results, false); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// AS,IP,BGP Prefix,CC,Registry,Allocated,AS Name
+	// 64500,203.0.113.7,203.0.113.0/24,US,arin,2020-01-01,TEST-NET
+}
+
+func ExampleClient_Lookup() {
+	client := ip2asn.New(ip2asn.WithTimeout(10 * time.Second))
+
+	ips, err := ip2asn.ParseIPs(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	results, err := client.Lookup(context.Background(), ips)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, result := range results {
+		fmt.Printf("%s AS%s %s\n", result.IP, result.ASN, result.ASName)
+	}
+}
+
+func ExampleClient_Enrich() {
+	client := ip2asn.New(ip2asn.WithProxycheck(os.Getenv("PROXYCHECK_API_KEY")))
+
+	results, err := client.Lookup(context.Background(), []string{"198.51.100.7", "203.0.113.7"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	warning, err := client.Enrich(context.Background(), results)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if warning != "" {
+		log.Printf("proxycheck: %s", warning)
+	}
+	if err := ip2asn.WriteJSON(os.Stdout, results, true); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,150 @@
+package ip2asn
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"text/template"
+	"time"
+
+	"github.com/hink/ip2asn/internal/output"
+	"github.com/hink/ip2asn/internal/parser"
+)
+
+// ParseIPs extracts IPv4/IPv6 addresses from r, de-duplicated in first-seen
+// order and in canonical form.
+func ParseIPs(r io.Reader) ([]string, error) {
+	return parser.ParseIPs(r)
+}
+
+// ParseIPsFromString is ParseIPs for in-memory text.
+func ParseIPsFromString(s string) ([]string, error) {
+	return parser.ParseIPsFromString(s)
+}
+
+// ScanIPs calls fn with each IPv4/IPv6 address in r, de-duplicated and in
+// canonical form, as soon as its line has been read, so input can be looked
+// up while it is still arriving; see LookupStream. Memory stays bounded: it
+// remembers the 100,000 most recently seen addresses, and one seen again
+// after dropping out of them is reported again. It stops at the first error
+// from fn.
+func ScanIPs(r io.Reader, fn func(ip string) error) error {
+	return parser.ScanIPs(r, fn)
+}
+
+// GroupByASN groups results by origin ASN, the shape WriteJSON emits.
+func GroupByASN(results []Result, includeEnrichment bool) []ASNGroup {
+	return output.GroupResultsByASN(results, includeEnrichment)
+}
+
+// WriteJSON writes results grouped by ASN as indented JSON.
+func WriteJSON(w io.Writer, results []Result, includeEnrichment bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(GroupByASN(results, includeEnrichment))
+}
+
+// NewNDJSONWriter returns a writer of one flat JSON object per result to w.
+// Each result reaches w as a single Write.
+func NewNDJSONWriter(w io.Writer, includeEnrichment bool) *NDJSONWriter {
+	return output.NewNDJSONWriter(w, includeEnrichment)
+}
+
+// WriteCSV writes a CSV header and one record per result.
+func WriteCSV(w io.Writer, results []Result, includeEnrichment bool) error {
+	cw := csv.NewWriter(w)
+	output.WriteCSV(cw, results, includeEnrichment, false)
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteCSVFields writes a CSV header and one record per result with the
+// columns given by fields.
+func WriteCSVFields(w io.Writer, results []Result, fields []Field) error {
+	cw := csv.NewWriter(w)
+	output.WriteCSVFields(cw, results, fields, false)
+	cw.Flush()
+	return cw.Error()
+}
+
+// Fields returns every output field in its canonical order.
+func Fields() []Field {
+	return output.Fields()
+}
+
+// ParseFields parses a comma-separated field list such as "asn,ip,risk",
+// as --fields does.
+func ParseFields(spec string) ([]Field, error) {
+	return output.ParseFields(spec)
+}
+
+// ParseTemplate parses a text/template for WriteTemplate, with the helper
+// functions join, pad, default, risk and status.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return output.ParseTemplate(name, text)
+}
+
+// WriteTemplate executes tmpl once per result, and its "header" and
+// "footer" templates, if defined, once around them.
+func WriteTemplate(w io.Writer, tmpl *template.Template, results []Result, run RunInfo) error {
+	return output.WriteTemplate(w, tmpl, results, run)
+}
+
+// WriteTable writes a styled table sized to w when it is a terminal.
+func WriteTable(w io.Writer, results []Result, opts TableOptions) {
+	output.PrintTable(w, results, opts)
+}
+
+// RenderTable renders a table for a fixed width; zero width disables wrapping.
+func RenderTable(results []Result, opts TableOptions, width int, enableColor bool) string {
+	return output.RenderTable(results, opts, width, enableColor)
+}
+
+// RenderMarkup renders the table as Markdown, HTML or AsciiDoc, for pasting
+// into issues and reports.
+func RenderMarkup(results []Result, opts TableOptions, format MarkupFormat) string {
+	return output.RenderMarkup(results, opts, format)
+}
+
+// WriteHTMLReport writes a self-contained HTML investigation report with
+// summary numbers, per-ASN sections and sortable, filterable tables.
+func WriteHTMLReport(w io.Writer, results []Result, opts ReportOptions) error {
+	return output.WriteHTMLReport(w, results, opts)
+}
+
+// SummarizeResults counts the distinct IPs, ASNs, countries and prefixes in
+// results, and their risk levels when enriched.
+func SummarizeResults(results []Result, enriched bool) ReportSummary {
+	return output.SummarizeResults(results, enriched)
+}
+
+// WriteSTIX writes results as a STIX 2.1 bundle of address and
+// autonomous-system observables linked by belongs-to relationships, with
+// proxycheck.io findings as notes when includeEnrichment is set. IDs are
+// deterministic, so re-runs deduplicate on ingestion.
+func WriteSTIX(w io.Writer, results []Result, includeEnrichment bool, run RunInfo) error {
+	return output.WriteSTIX(w, results, includeEnrichment, run)
+}
+
+// NewMISPEvent builds a MISP event with an attribute per IP, an "asn" object
+// per origin AS and, when includeEnrichment is set, proxycheck.io tags.
+func NewMISPEvent(results []Result, includeEnrichment bool, run RunInfo, opts MISPOptions) MISPEvent {
+	return output.NewMISPEvent(results, includeEnrichment, run, opts)
+}
+
+// NewECSDocument maps a result to ECS source.*, threat.* and event fields.
+// now stands in for results without a retrieval time.
+func NewECSDocument(r Result, includeEnrichment bool, now time.Time) ECSDocument {
+	return output.NewECSDocument(r, includeEnrichment, now)
+}
+
+// NewCIMEvent maps a result to Splunk CIM field names such as src and
+// risk_score. now stands in for results without a retrieval time.
+func NewCIMEvent(r Result, includeEnrichment bool, now time.Time) CIMEvent {
+	return output.NewCIMEvent(r, includeEnrichment, now)
+}
+
+// WriteMISP writes NewMISPEvent's event as JSON for import into MISP.
+func WriteMISP(w io.Writer, results []Result, includeEnrichment bool, run RunInfo, opts MISPOptions) error {
+	return output.WriteMISP(w, results, includeEnrichment, run, opts)
+}
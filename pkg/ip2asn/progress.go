@@ -0,0 +1,55 @@
+package ip2asn
+
+import "github.com/hink/ip2asn/internal/cymru"
+
+// Stage names the part of a run a Progress update is about.
+type Stage string
+
+const (
+	StageLookup Stage = "lookup"
+	StageEnrich Stage = "enrich"
+)
+
+// Progress reports how far a Lookup or Enrich call has got.
+type Progress struct {
+	Stage Stage
+	// Done and Total count IPs; cache hits are done from the start.
+	Done  int
+	Total int
+	// Session is the current one of Sessions, counting from 1: the bulk
+	// WHOIS connection, a DNS lookup or a proxycheck.io batch.
+	Session  int
+	Sessions int
+}
+
+// WithProgress calls fn as Lookup and Enrich make progress, from the
+// goroutine running the call. Updates can be frequent; fn should be cheap.
+func WithProgress(fn func(Progress)) Option {
+	return func(c *Client) {
+		c.onProgress = fn
+	}
+}
+
+// lookupProgress reports the progress of one Lookup call, whose first cached
+// IPs were answered from the cache.
+type lookupProgress struct {
+	fn     func(Progress)
+	total  int
+	cached int
+}
+
+func (p lookupProgress) report(done, session, sessions int) {
+	if p.fn != nil {
+		p.fn(Progress{Stage: StageLookup, Done: p.cached + done, Total: p.total, Session: session, Sessions: sessions})
+	}
+}
+
+// whoisOpts returns the client's cymru options plus one reporting the rows
+// of the bulk WHOIS session as they arrive.
+func (c *Client) whoisOpts(p lookupProgress) []cymru.Option {
+	if p.fn == nil {
+		return c.cymruOpts
+	}
+	opts := append([]cymru.Option(nil), c.cymruOpts...)
+	return append(opts, cymru.WithProgress(func(done int) { p.report(done, 1, 1) }))
+}
@@ -0,0 +1,171 @@
+package ip2asn
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hink/ip2asn/internal/cymru"
+	"github.com/hink/ip2asn/internal/model"
+)
+
+const (
+	// streamSessionSize caps the IPs sent in one streamed session; it matches
+	// a proxycheck.io batch, so each session is enriched in one request.
+	streamSessionSize = 1000
+	// streamLinger is how long a session waits for more IPs after the first
+	// before it starts.
+	streamLinger = 250 * time.Millisecond
+)
+
+// StreamOptions tunes LookupStream.
+type StreamOptions struct {
+	// Enrich adds proxycheck.io data to each session's results before they
+	// are emitted. It needs WithProxycheck.
+	Enrich bool
+	// OnEnrichError is called when enriching a session fails; its results
+	// are still emitted, without proxycheck data.
+	OnEnrichError func(error)
+}
+
+// LookupStream looks up the IPs received on ips until it is closed, calling
+// emit with each result as soon as it is available rather than once every IP
+// is done. Results are not sorted.
+//
+// IPs are grouped into sessions of up to 1000, each starting at most 250ms
+// after its first IP arrives; a bulk WHOIS session emits its rows as they
+// are read. WithTimeout bounds each session. With opts.Enrich, a session's
+// results are emitted together once enriched.
+//
+// LookupStream returns the first lookup or emit error, or ctx's error if it
+// ends first; results emitted before then stand.
+func (c *Client) LookupStream(ctx context.Context, ips <-chan string, opts StreamOptions, emit func(Result) error) error {
+	var received, done, session int
+	report := func() {
+		if c.onProgress != nil {
+			// The total grows as IPs arrive
+			c.onProgress(Progress{Stage: StageLookup, Done: done, Total: received, Session: session, Sessions: session})
+		}
+	}
+	counted := func(result Result) error {
+		done++
+		report()
+		return emit(result)
+	}
+
+	for {
+		batch, more := nextBatch(ctx, ips)
+		if len(batch) > 0 {
+			received += len(batch)
+			session++
+			report()
+			if err := c.streamSession(ctx, batch, opts, counted); err != nil {
+				return err
+			}
+		}
+		if !more {
+			return ctx.Err()
+		}
+	}
+}
+
+// nextBatch waits for the next IPs on ips. more is false once ips is closed
+// or ctx has ended.
+func nextBatch(ctx context.Context, ips <-chan string) (batch []string, more bool) {
+	select {
+	case ip, ok := <-ips:
+		if !ok {
+			return nil, false
+		}
+		batch = append(batch, ip)
+	case <-ctx.Done():
+		return nil, false
+	}
+
+	linger := time.NewTimer(streamLinger)
+	defer linger.Stop()
+	for len(batch) < streamSessionSize {
+		select {
+		case ip, ok := <-ips:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, ip)
+		case <-linger.C:
+			return batch, true
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	return batch, true
+}
+
+// streamSession looks up one session's IPs, emitting each result.
+func (c *Client) streamSession(ctx context.Context, ips []string, opts StreamOptions, emit func(Result) error) error {
+	now := time.Now()
+	cached, misses := c.fromCache(ips, now)
+
+	timeout := c.timeout
+	if timeout == adaptiveTimeout {
+		timeout = lookupBudget(c.backend, len(misses))
+	}
+	sessionCtx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	if opts.Enrich && c.proxycheck != nil {
+		results, err := c.lookup(sessionCtx, misses, lookupProgress{})
+		if c.cache != nil {
+			c.cache.Put(results, now)
+		}
+		results = append(cached, results...)
+		if _, enrichErr := c.Enrich(ctx, results); enrichErr != nil && opts.OnEnrichError != nil {
+			opts.OnEnrichError(enrichErr)
+		}
+		for _, result := range results {
+			if err := emit(result); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	for _, result := range cached {
+		if err := emit(result); err != nil {
+			return err
+		}
+	}
+	if c.backend == BackendDNS || (c.backend == BackendAuto && len(misses) == 1) {
+		results, err := c.lookup(sessionCtx, misses, lookupProgress{})
+		if c.cache != nil {
+			c.cache.Put(results, now)
+		}
+		for _, result := range results {
+			if err := emit(result); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	// Bulk WHOIS: emit rows as they are read, ending the session early if
+	// emit fails
+	var emitErr error
+	err := cymru.StreamWhoisBulk(sessionCtx, misses, func(result model.Result) {
+		if emitErr != nil {
+			return
+		}
+		if c.cache != nil {
+			c.cache.Put([]model.Result{result}, now)
+		}
+		if emitErr = emit(result); emitErr != nil {
+			cancel()
+		}
+	}, c.cymruOpts...)
+	if emitErr != nil {
+		return emitErr
+	}
+	if err != nil {
+		return fmt.Errorf("WHOIS bulk lookup failed: %w", err)
+	}
+	return nil
+}
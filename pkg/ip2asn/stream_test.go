@@ -0,0 +1,108 @@
+package ip2asn
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/hink/ip2asn/internal/cymru/cymrutest"
+)
+
+func TestLookupStreamEmitsRowsBeforeTheSessionEnds(t *testing.T) {
+	whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{
+		Body: cymrutest.BulkResponse(
+			cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"},
+			cymrutest.Row{ASN: "64500", IP: "192.0.2.2", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"},
+		),
+		Hang: true,
+	}))
+
+	ips := make(chan string, 3)
+	ips <- "192.0.2.1"
+	ips <- "192.0.2.2"
+	ips <- "192.0.2.3"
+	// ips stays open, and the server stalls after the second row
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []Result
+	err := New(WithWhoisServer(whois.Addr)).LookupStream(ctx, ips, StreamOptions{}, func(r Result) error {
+		got = append(got, r)
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	// Cancelling from emit proves the first IP came while the session was
+	// open; the rows read by then are still emitted
+	if len(got) != 2 || got[0].IPString() != "192.0.2.1" {
+		t.Fatalf("expected both rows read before cancellation, got %+v", got)
+	}
+}
+
+func TestLookupStreamAnswersCacheHitsAndSendsMissesInOneSession(t *testing.T) {
+	whois := cymrutest.NewWhoisServer(t, cymrutest.StaticWhois(cymrutest.WhoisReply{Body: cymrutest.BulkResponse(
+		cymrutest.Row{ASN: "64500", IP: "192.0.2.1", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"},
+		cymrutest.Row{ASN: "64500", IP: "192.0.2.2", Prefix: "192.0.2.0/24", CC: "US", Registry: "arin", Allocated: "2010-05-01", ASName: "EXAMPLE"},
+	)}))
+	cache := mapCache{"203.0.113.7": {{ASN: 64501, IP: netip.MustParseAddr("203.0.113.7")}}}
+
+	ips := make(chan string, 3)
+	for _, ip := range []string{"192.0.2.1", "203.0.113.7", "192.0.2.2"} {
+		ips <- ip
+	}
+	close(ips)
+
+	var got []string
+	var updates []Progress
+	client := New(WithWhoisServer(whois.Addr), WithCache(cache), WithProgress(func(p Progress) { updates = append(updates, p) }))
+	err := client.LookupStream(context.Background(), ips, StreamOptions{}, func(r Result) error {
+		got = append(got, r.IPString())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LookupStream: %v", err)
+	}
+
+	want := []string{"203.0.113.7", "192.0.2.1", "192.0.2.2"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("result %d = %s, want %s (got %v)", i, got[i], want[i], got)
+		}
+	}
+	if sessions := whois.Sessions(); len(sessions) != 1 || len(sessions[0]) != 2 {
+		t.Fatalf("expected one WHOIS session for the two misses, got %v", sessions)
+	}
+	if _, ok := cache["192.0.2.1"]; !ok {
+		t.Fatal("expected streamed rows to be cached")
+	}
+	if last := updates[len(updates)-1]; last != (Progress{Stage: StageLookup, Done: 3, Total: 3, Session: 1, Sessions: 1}) {
+		t.Fatalf("unexpected final progress %+v", last)
+	}
+}
+
+func TestLookupStreamStopsOnEmitError(t *testing.T) {
+	cache := mapCache{
+		"192.0.2.1": {{ASN: 64500, IP: netip.MustParseAddr("192.0.2.1")}},
+		"192.0.2.2": {{ASN: 64500, IP: netip.MustParseAddr("192.0.2.2")}},
+	}
+	ips := make(chan string, 2)
+	ips <- "192.0.2.1"
+	ips <- "192.0.2.2"
+	close(ips)
+
+	errWrite := errors.New("broken pipe")
+	var calls int
+	err := New(WithCache(cache)).LookupStream(context.Background(), ips, StreamOptions{}, func(Result) error {
+		calls++
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) || calls != 1 {
+		t.Fatalf("expected to stop after the first emit error, got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,138 @@
+package ip2asn
+
+import (
+	"github.com/hink/ip2asn/internal/cymru"
+	"github.com/hink/ip2asn/internal/model"
+	"github.com/hink/ip2asn/internal/output"
+)
+
+// Result is one IP to ASN mapping. See the field docs for encoding details.
+type Result = model.Result
+
+// Origin is one announcing AS of a (possibly multi-origin) BGP prefix.
+type Origin = model.Origin
+
+// ASInfo is the registration data of an autonomous system.
+type ASInfo = model.ASInfo
+
+// ProxyCheck holds optional proxycheck.io enrichment for a Result.
+type ProxyCheck = model.ProxyCheck
+
+// ASN is a 32-bit autonomous system number.
+type ASN = model.ASN
+
+// ASNUnknown marks a result whose origin AS is not known.
+const ASNUnknown = model.ASNUnknown
+
+// Date is a civil calendar date, such as a registry allocation date.
+type Date = model.Date
+
+// Method identifies the Team Cymru interface a result came from.
+type Method = model.Method
+
+const (
+	MethodUnknown = model.MethodUnknown
+	MethodDNS     = model.MethodDNS
+	MethodWhois   = model.MethodWhois
+)
+
+// ParseASN parses a decimal ASN with an optional "AS" prefix.
+func ParseASN(s string) (ASN, error) {
+	return model.ParseASN(s)
+}
+
+// ParseDate parses a YYYY-MM-DD date.
+func ParseDate(s string) (Date, error) {
+	return model.ParseDate(s)
+}
+
+// Resolver answers the TXT queries of the DNS interface. *net.Resolver
+// satisfies it.
+type Resolver = cymru.Resolver
+
+// Dialer opens WHOIS connections. *net.Dialer satisfies it.
+type Dialer = cymru.Dialer
+
+// ResolverConfig sets the per-query timeout, retries and transport of a
+// resolver built by NewResolver.
+type ResolverConfig = cymru.ResolverConfig
+
+// NewResolver returns a resolver for spec: "" for the system resolver,
+// "1.2.3.4:53" (or udp://) for plain DNS, "tcp://host:port" for DNS over TCP,
+// "tls://host:853" for DNS-over-TLS or an "https://" URL for DNS-over-HTTPS.
+func NewResolver(spec string, cfg ResolverConfig) (Resolver, error) {
+	return cymru.NewResolver(spec, cfg)
+}
+
+// ASNGroup is the JSON output structure grouping results by ASN.
+type ASNGroup = output.JSONASNGroup
+
+// IPEntry is a per-IP entry nested under an ASNGroup.
+type IPEntry = output.JSONIPEntry
+
+// FlatResult is one result as a flat JSON object, the shape NDJSONWriter
+// emits.
+type FlatResult = output.FlatResult
+
+// NDJSONWriter writes results as newline-delimited JSON, one per line.
+type NDJSONWriter = output.NDJSONWriter
+
+// Schemas of NDJSONWriter.SetSchema.
+const (
+	SchemaFlat = output.SchemaFlat
+	SchemaECS  = output.SchemaECS
+	SchemaCIM  = output.SchemaCIM
+)
+
+// ECSDocument is a result mapped to the Elastic Common Schema.
+type ECSDocument = output.ECSDocument
+
+// CIMEvent is a result with Splunk CIM field names.
+type CIMEvent = output.CIMEvent
+
+// Field is one output column, selectable by name for tables, CSV and
+// NDJSON.
+type Field = output.Field
+
+// RunInfo describes the run that produced a set of results, for templates
+// and reports.
+type RunInfo = output.RunInfo
+
+// MISPOptions configures NewMISPEvent.
+type MISPOptions = output.MISPOptions
+
+// MISPEvent is a MISP event as MISP imports it.
+type MISPEvent = output.MISPEvent
+
+// MISP attribute types for the IPs of a MISP event.
+const (
+	MISPIPSource      = output.MISPIPSource
+	MISPIPDestination = output.MISPIPDestination
+)
+
+// ReportOptions configures WriteHTMLReport.
+type ReportOptions = output.ReportOptions
+
+// ReportSummary holds the distinct IP, ASN, country and prefix counts of a
+// report, and its risk distribution.
+type ReportSummary = output.ReportSummary
+
+// TableOptions controls how table output is rendered.
+type TableOptions = output.TableOptions
+
+// MarkupFormat selects the document markup of RenderMarkup.
+type MarkupFormat = output.MarkupFormat
+
+const (
+	MarkupMarkdown = output.MarkupMarkdown
+	MarkupHTML     = output.MarkupHTML
+	MarkupAsciiDoc = output.MarkupAsciiDoc
+)
+
+// TableMode selects the table schema.
+type TableMode = output.TableMode
+
+const (
+	TableModeBasic      = output.TableModeBasic
+	TableModeProxycheck = output.TableModeProxycheck
+)